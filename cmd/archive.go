@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/archive"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export feeds as an NDJSON or Atom archive",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		feeds, _ := cmd.Flags().GetStringSlice("feed")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		if len(feeds) == 0 {
+			feeds, err = archive.AllFeeds(cmd.Context(), t.Store())
+			if err != nil {
+				return err
+			}
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" && output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		err = archive.Export(cmd.Context(), w, t.Store(), feeds, format)
+		if err != nil {
+			return fmt.Errorf("failed to export: %w", err)
+		}
+
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import an NDJSON archive, or an RSS, Atom or JSON Feed file, use - to read stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		feed, _ := cmd.Flags().GetString("feed")
+
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open import file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		result, err := archive.Import(cmd.Context(), r, t.Store(), archive.ImportOptions{Feed: feed})
+		if err != nil {
+			return fmt.Errorf("failed to import: %w", err)
+		}
+
+		return json.NewEncoder(os.Stdout).Encode(result)
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringSlice("feed", nil, "feed to export, can be repeated, all feeds are exported when not set")
+	exportCmd.Flags().String("format", archive.FormatNDJSON, "archive format, ndjson or atom")
+	exportCmd.Flags().StringP("output", "o", "", "file to write the archive to, defaults to stdout")
+
+	importCmd.Flags().String("feed", "", "feed to import into, required for RSS, Atom and JSON Feed files")
+
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	webhookTool "github.com/charlieegan3/tool-webhook-rss/pkg/tool"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the config file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the tool's config without connecting to storage or starting anything",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		toolCfg, err := loadToolConfig()
+		if err != nil {
+			return err
+		}
+
+		err = webhookTool.ValidateConfig(toolCfg)
+		if err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+
+		fmt.Printf("%s is valid\n", configPath)
+
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var feedsCmd = &cobra.Command{
+	Use:   "feeds",
+	Short: "Inspect and manage feeds",
+}
+
+var feedsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List feeds with their item counts and when they were last updated",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		stats, err := t.Store().FeedStats(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list feeds: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FEED\tITEMS\tLATEST")
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", s.Feed, s.Count, s.LatestAt.UTC().Format(time.RFC3339))
+		}
+
+		return w.Flush()
+	},
+}
+
+var feedsPurgeCmd = &cobra.Command{
+	Use:   "purge <feed>",
+	Short: "Remove every item from a feed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes {
+			return fmt.Errorf("purging removes every item in %s, pass --yes to confirm", args[0])
+		}
+
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		removed, err := t.PurgeFeed(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to purge feed: %w", err)
+		}
+
+		fmt.Printf("removed %d items from %s\n", removed, args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	feedsPurgeCmd.Flags().Bool("yes", false, "confirm that every item should be removed")
+
+	feedsCmd.AddCommand(feedsListCmd)
+	feedsCmd.AddCommand(feedsPurgeCmd)
+
+	rootCmd.AddCommand(feedsCmd)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+var itemsCmd = &cobra.Command{
+	Use:   "items",
+	Short: "Post and delete items",
+}
+
+var itemsPostCmd = &cobra.Command{
+	Use:   "post <feed>",
+	Short: "Post an item to a feed, as if it had been sent to the item create endpoint",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		feed := args[0]
+		if !handlers.ValidFeed(feed) {
+			return fmt.Errorf("invalid feed name %q", feed)
+		}
+
+		title, _ := cmd.Flags().GetString("title")
+		body, _ := cmd.Flags().GetString("body")
+		url, _ := cmd.Flags().GetString("url")
+		guid, _ := cmd.Flags().GetString("guid")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+
+		if guid == "" {
+			var err error
+			guid, err = handlers.NewGUID()
+			if err != nil {
+				return fmt.Errorf("failed to generate guid: %w", err)
+			}
+		}
+
+		item := store.NewItem{
+			GUID:  guid,
+			Title: title,
+			Body:  body,
+			URL:   url,
+		}
+		for _, tag := range tags {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" && !item.Tags.Has(tag) {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		created, err := t.PostItems(cmd.Context(), feed, []store.NewItem{item})
+		if err != nil {
+			return fmt.Errorf("failed to post item: %w", err)
+		}
+
+		for _, c := range created {
+			fmt.Printf("created item %d in %s with guid %s\n", c.ID, feed, c.GUID)
+		}
+
+		return nil
+	},
+}
+
+var itemsDeleteCmd = &cobra.Command{
+	Use:   "delete <feed> <id>...",
+	Short: "Delete items from a feed by id",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		feed := args[0]
+
+		var ids []int64
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid item id %q", arg)
+			}
+			ids = append(ids, id)
+		}
+
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		removed, err := t.Store().DeleteItems(cmd.Context(), feed, ids)
+		if err != nil {
+			return fmt.Errorf("failed to delete items: %w", err)
+		}
+
+		fmt.Printf("removed %d items from %s\n", removed, feed)
+
+		return nil
+	},
+}
+
+func init() {
+	itemsPostCmd.Flags().String("title", "", "title of the item")
+	itemsPostCmd.Flags().String("body", "", "body of the item, text or HTML")
+	itemsPostCmd.Flags().String("url", "", "link for the item")
+	itemsPostCmd.Flags().String("guid", "", "guid of the item, generated when not set")
+	itemsPostCmd.Flags().StringSlice("tag", nil, "tag for the item, can be repeated")
+	_ = itemsPostCmd.MarkFlagRequired("title")
+
+	itemsCmd.AddCommand(itemsPostCmd)
+	itemsCmd.AddCommand(itemsDeleteCmd)
+
+	rootCmd.AddCommand(itemsCmd)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "List and run the tool's jobs",
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List jobs and their schedules",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		jobs, err := t.Jobs()
+		if err != nil {
+			return fmt.Errorf("failed to load jobs: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCHEDULE\tTIMEOUT")
+		for _, job := range jobs {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", job.Name(), job.Schedule(), job.Timeout())
+		}
+
+		return w.Flush()
+	},
+}
+
+var jobsRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a job once, outside of its schedule",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := loadTool()
+		if err != nil {
+			return err
+		}
+		defer closeTool(t)
+
+		jobs, err := t.Jobs()
+		if err != nil {
+			return fmt.Errorf("failed to load jobs: %w", err)
+		}
+
+		for _, job := range jobs {
+			if job.Name() != args[0] {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), job.Timeout())
+			defer cancel()
+
+			err = job.Run(ctx)
+			if err != nil {
+				return fmt.Errorf("job %s failed: %w", job.Name(), err)
+			}
+
+			fmt.Printf("job %s completed\n", job.Name())
+
+			return nil
+		}
+
+		return fmt.Errorf("no job named %q, use jobs list to see the available jobs", args[0])
+	},
+}
+
+func init() {
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsRunCmd)
+
+	rootCmd.AddCommand(jobsCmd)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charlieegan3/toolbelt/pkg/database"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	webhookTool "github.com/charlieegan3/tool-webhook-rss/pkg/tool"
+)
+
+// configPath is the config file used by all commands, it has the same format as config.test.yaml
+var configPath string
+
+var rootCmd = &cobra.Command{
+	Use:   "webhook-rss",
+	Short: "Run and operate the webhook-rss tool",
+	// usage is only useful for mistakes in the command line, not when a command fails
+	SilenceUsage: true,
+}
+
+func main() {
+	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "config.yaml", "path to the config file")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := rootCmd.ExecuteContext(ctx)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// loadConfig reads the config file, returning the config for all tools in the belt
+func loadConfig() (map[string]any, error) {
+	viper.SetConfigFile(configPath)
+	err := viper.ReadInConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	toolsConfig, ok := viper.Get("tools").(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("missing required config path: tools")
+	}
+
+	return toolsConfig, nil
+}
+
+// loadToolConfig reads the config file, returning the config for this tool
+func loadToolConfig() (map[string]any, error) {
+	toolsConfig, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	name := (&webhookTool.WebhookRSS{}).Name()
+	toolCfg, ok := toolsConfig[name].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("missing required config path: tools.%s", name)
+	}
+
+	return toolCfg, nil
+}
+
+// openDatabase connects to the database configured in the config file's database block
+func openDatabase() (*sql.DB, error) {
+	params := viper.GetStringMapString("database.params")
+	connectionString := viper.GetString("database.connectionString")
+
+	db, err := database.Init(connectionString, params, params["dbname"], false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init DB: %w", err)
+	}
+
+	return db, nil
+}
+
+// loadTool returns the tool with its storage ready to use. Unlike serve, the HTTP handlers, SMTP server and jobs
+// aren't started.
+func loadTool() (*webhookTool.WebhookRSS, error) {
+	toolCfg, err := loadToolConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &webhookTool.WebhookRSS{}
+	err = t.SetConfig(toolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set config: %w", err)
+	}
+
+	if t.FeatureSet().Database {
+		db, err := openDatabase()
+		if err != nil {
+			return nil, err
+		}
+		t.DatabaseSet(db)
+	}
+
+	return t, nil
+}
+
+// closeTool releases the tool's storage, there's nothing to do about errors at exit so they're only logged
+func closeTool(t *webhookTool.WebhookRSS) {
+	if t.Store() == nil {
+		return
+	}
+
+	err := t.Store().Close()
+	if err != nil {
+		log.Printf("failed to close store: %s", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Run the storage migrations",
+	Long: `Run the storage migrations. Migrations are run up automatically by serve, so these commands
+are mostly useful for rolling back a release.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Run all pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMigrations(func(m *migrate.Migrate) error {
+			return m.Up()
+		})
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Roll back migrations, one by default",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, _ := cmd.Flags().GetInt("steps")
+		all, _ := cmd.Flags().GetBool("all")
+
+		return runMigrations(func(m *migrate.Migrate) error {
+			if all {
+				return m.Down()
+			}
+			if steps < 1 {
+				return fmt.Errorf("steps must be at least 1")
+			}
+			return m.Steps(-steps)
+		})
+	},
+}
+
+// runMigrations runs fn with the tool's migrator and prints the resulting version
+func runMigrations(fn func(m *migrate.Migrate) error) error {
+	t, err := loadTool()
+	if err != nil {
+		return err
+	}
+	defer closeTool(t)
+
+	m, err := t.Migrator()
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	err = fn(m)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get migration version: %w", err)
+	}
+
+	fmt.Printf("at migration version %d", version)
+	if dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func init() {
+	migrateDownCmd.Flags().Int("steps", 1, "number of migrations to roll back")
+	migrateDownCmd.Flags().Bool("all", false, "roll back every migration")
+
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+
+	rootCmd.AddCommand(migrateCmd)
+}
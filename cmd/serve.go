@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	webhookTool "github.com/charlieegan3/tool-webhook-rss/pkg/tool"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the HTTP server and scheduled jobs until interrupted",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetString("port")
+		noJobs, _ := cmd.Flags().GetBool("no-jobs")
+
+		toolsConfig, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		tb := tool.NewBelt()
+		tb.SetConfig(toolsConfig)
+
+		// the database is only needed by the postgres storage backend, configs for other backends can leave it out
+		if viper.IsSet("database") {
+			db, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			tb.SetDatabase(db)
+		}
+
+		t := &webhookTool.WebhookRSS{}
+		err = tb.AddTool(t)
+		if err != nil {
+			return fmt.Errorf("failed to add tool: %w", err)
+		}
+		defer closeTool(t)
+
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tb.RunServer(cmd.Context(), host, port)
+		}()
+
+		if !noJobs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tb.RunJobs(cmd.Context())
+			}()
+		}
+
+		wg.Wait()
+
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("host", "0.0.0.0", "address to listen on")
+	serveCmd.Flags().String("port", "3000", "port to listen on")
+	serveCmd.Flags().Bool("no-jobs", false, "don't run scheduled jobs, e.g. on replicas other than the one running them")
+
+	rootCmd.AddCommand(serveCmd)
+}
@@ -1,8 +1,97 @@
 package apis
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
 type PayloadNewItem struct {
 	Title string `json:"title"`
 	Body  string `json:"body"`
 	URL   string `json:"url"`
-	Date  string `json:"date"`
+	// Date may be any of the common date formats, or a Unix epoch in seconds or milliseconds. Epoch values may be
+	// sent as either JSON strings or numbers.
+	Date string `json:"date"`
+	// Tags are optional labels for the item, used to filter items which are forwarded to other services
+	Tags []string `json:"tags"`
+	// PublishAt schedules the item to appear in its feed at a future time, it's used as the item's date so it can't
+	// be sent with Date. ExpiresAt removes the item from its feed at that time. They accept the same formats as
+	// Date.
+	PublishAt string `json:"publish_at"`
+	ExpiresAt string `json:"expires_at"`
+	// Priority is one of low, normal (the default), high or urgent. Pinned items are listed at the top of their
+	// feed until they're unpinned.
+	Priority string `json:"priority"`
+	Pinned   bool   `json:"pinned"`
+}
+
+// UnmarshalJSON allows the date fields to be sent as JSON numbers, as is common for epoch timestamps
+func (p *PayloadNewItem) UnmarshalJSON(data []byte) error {
+	type payloadNewItem PayloadNewItem
+
+	aux := struct {
+		*payloadNewItem
+		Date      json.RawMessage `json:"date"`
+		PublishAt json.RawMessage `json:"publish_at"`
+		ExpiresAt json.RawMessage `json:"expires_at"`
+	}{
+		payloadNewItem: (*payloadNewItem)(p),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	p.Date, err = dateString(aux.Date)
+	if err != nil {
+		return fmt.Errorf("failed to parse date: %w", err)
+	}
+	p.PublishAt, err = dateString(aux.PublishAt)
+	if err != nil {
+		return fmt.Errorf("failed to parse publish_at: %w", err)
+	}
+	p.ExpiresAt, err = dateString(aux.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to parse expires_at: %w", err)
+	}
+
+	return nil
+}
+
+// dateString returns a date field sent as a JSON string or number as a string
+func dateString(raw json.RawMessage) (string, error) {
+	date := bytes.TrimSpace(raw)
+	switch {
+	case len(date) == 0 || bytes.Equal(date, []byte("null")):
+		return "", nil
+	case date[0] == '"':
+		var s string
+		err := json.Unmarshal(date, &s)
+		if err != nil {
+			return "", err
+		}
+		return s, nil
+	default:
+		var n json.Number
+		err := json.Unmarshal(date, &n)
+		if err != nil {
+			return "", fmt.Errorf("must be a string or number: %w", err)
+		}
+		return n.String(), nil
+	}
+}
+
+// PayloadNewSecret is used to issue a new read secret for a feed
+type PayloadNewSecret struct {
+	// Label describes who or what the secret was issued to
+	Label string `json:"label"`
+}
+
+// PayloadItemState changes the requesting user's state for an item, fields which aren't sent are left unchanged
+type PayloadItemState struct {
+	Read     *bool `json:"read"`
+	Starred  *bool `json:"starred"`
+	Archived *bool `json:"archived"`
 }
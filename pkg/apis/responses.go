@@ -0,0 +1,179 @@
+package apis
+
+import "time"
+
+// ResponseItemsCreated is returned when one or more items have been created in a feed.
+// In partial mode, Errors lists the items which were rejected.
+type ResponseItemsCreated struct {
+	Items  []ResponseCreatedItem `json:"items"`
+	Errors []ResponseError       `json:"errors,omitempty"`
+}
+
+// ResponseCreatedItem identifies an item created from the request payload
+type ResponseCreatedItem struct {
+	// Index is the position of the item in the request payload
+	Index int    `json:"index"`
+	ID    int64  `json:"id"`
+	GUID  string `json:"guid"`
+}
+
+// ResponseErrors is returned when a request is rejected
+type ResponseErrors struct {
+	Errors []ResponseError `json:"errors"`
+}
+
+// ResponseError describes a single problem with a request. Index and Field are only set when the error relates
+// to a particular item in the request payload.
+type ResponseError struct {
+	Index  *int   `json:"index,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ResponseSecret describes a read secret for a feed. The secret itself is only returned when it's issued.
+type ResponseSecret struct {
+	ID        int64      `json:"id"`
+	Feed      string     `json:"feed"`
+	Label     string     `json:"label"`
+	Secret    string     `json:"secret,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ResponseItem describes an item in a feed
+type ResponseItem struct {
+	ID        int64     `json:"id"`
+	GUID      string    `json:"guid"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Priority  string     `json:"priority,omitempty"`
+	Pinned    bool       `json:"pinned,omitempty"`
+}
+
+// ResponseDelivery describes an item in the fan-out outbox
+type ResponseDelivery struct {
+	ID            int64     `json:"id"`
+	Target        string    `json:"target"`
+	Feed          string    `json:"feed"`
+	ItemID        int64     `json:"item_id"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ResponseHealth is returned by the health and readiness checks. Checks lists the result of each readiness check,
+// which is "ok" when it passed.
+type ResponseHealth struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// ResponseStatus summarises the state of the tool's dead man pulses, jobs and feeds
+type ResponseStatus struct {
+	Deadman    ResponseDeadman     `json:"deadman"`
+	Heartbeats []ResponseHeartbeat `json:"heartbeats"`
+	Jobs       []ResponseJobStatus `json:"jobs"`
+	StaleFeeds []ResponseStaleFeed `json:"stale_feeds"`
+}
+
+// ResponseDeadman describes the dead man pulses. Healthy is false when the deadman heartbeat has been missed.
+type ResponseDeadman struct {
+	LastPulseAt *time.Time `json:"last_pulse_at"`
+	Healthy     bool       `json:"healthy"`
+}
+
+// ResponseHeartbeat describes a heartbeat checked by the deadman-check job. Healthy is false when the next item is
+// later than the grace period allows, or when the last run reported a failure. Heartbeats have an interval or a cron
+// schedule.
+type ResponseHeartbeat struct {
+	Name            string     `json:"name"`
+	Feed            string     `json:"feed"`
+	IntervalSeconds float64    `json:"interval_seconds,omitempty"`
+	Schedule        string     `json:"schedule,omitempty"`
+	GraceSeconds    float64    `json:"grace_seconds"`
+	LastBeatAt      *time.Time `json:"last_beat_at"`
+	Healthy         bool       `json:"healthy"`
+}
+
+// ResponseJobStatus describes the last run of a job on the replica responding
+type ResponseJobStatus struct {
+	Name                string     `json:"name"`
+	Schedule            string     `json:"schedule"`
+	LastRunAt           *time.Time `json:"last_run_at"`
+	LastSuccessAt       *time.Time `json:"last_success_at"`
+	LastDurationSeconds float64    `json:"last_duration_seconds"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// ResponseStaleFeed describes a feed whose newest item is older than the max age checked by the feed-check job
+type ResponseStaleFeed struct {
+	Feed          string    `json:"feed"`
+	LatestAt      time.Time `json:"latest_at"`
+	MaxAgeSeconds float64   `json:"max_age_seconds"`
+}
+
+// ResponseJobRuns lists the recent runs of each job
+type ResponseJobRuns struct {
+	Jobs []ResponseJobHistory `json:"jobs"`
+}
+
+// ResponseJobHistory lists the recent runs of a job, newest first
+type ResponseJobHistory struct {
+	Name     string           `json:"name"`
+	Schedule string           `json:"schedule"`
+	Runs     []ResponseJobRun `json:"runs"`
+}
+
+// ResponseJobRun describes a recorded run of a job. Stats are the counters reported by the job, such as the number
+// of rows removed.
+type ResponseJobRun struct {
+	ID              int64            `json:"id"`
+	StartedAt       time.Time        `json:"started_at"`
+	FinishedAt      time.Time        `json:"finished_at"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	Outcome         string           `json:"outcome"`
+	Error           string           `json:"error,omitempty"`
+	Stats           map[string]int64 `json:"stats"`
+}
+
+// ResponseItemState describes a user's state for an item
+type ResponseItemState struct {
+	ItemID    int64      `json:"item_id"`
+	Feed      string     `json:"feed"`
+	Read      bool       `json:"read"`
+	Starred   bool       `json:"starred"`
+	Archived  bool       `json:"archived"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ResponseUserItems lists items in a feed along with the requesting user's state for each
+type ResponseUserItems struct {
+	Items []ResponseUserItem `json:"items"`
+}
+
+// ResponseUserItem describes an item in a feed and the requesting user's state for it
+type ResponseUserItem struct {
+	ResponseItem
+
+	Read     bool `json:"read"`
+	Starred  bool `json:"starred"`
+	Archived bool `json:"archived"`
+}
+
+// ResponseUnreadCounts lists the number of items the requesting user hasn't read in each feed
+type ResponseUnreadCounts struct {
+	Feeds []ResponseUnreadCount `json:"feeds"`
+}
+
+// ResponseUnreadCount is the number of items the requesting user hasn't read or archived in a feed
+type ResponseUnreadCount struct {
+	Feed   string `json:"feed"`
+	Unread int64  `json:"unread"`
+}
@@ -0,0 +1,171 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/golang-migrate/migrate/v4"
+	migratePostgres "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/events"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// These are used by the admin CLI to operate the tool outside of the toolbelt's server and job runner
+
+// Store returns the tool's store, it's set once the config, and the database when using postgres, have been set
+func (d *WebhookRSS) Store() store.Store {
+	return d.store
+}
+
+// PostItems inserts items into a feed and runs the same hooks as the item create handler, so new items are
+// fanned out and announced to other replicas
+func (d *WebhookRSS) PostItems(ctx context.Context, feed string, items []store.NewItem) ([]store.Item, error) {
+	if d.store == nil {
+		return nil, fmt.Errorf("storage not set")
+	}
+
+	hooks, err := d.itemCreatedHooks()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := d.store.InsertItems(ctx, feed, items, store.InsertOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fn := range hooks {
+		fn(ctx, feed, created)
+	}
+
+	return created, nil
+}
+
+// PurgeFeed removes every item from a feed, including those scheduled for the future, returning the number removed
+func (d *WebhookRSS) PurgeFeed(ctx context.Context, feed string) (int64, error) {
+	if d.store == nil {
+		return 0, fmt.Errorf("storage not set")
+	}
+
+	return d.store.DeleteFeed(ctx, feed)
+}
+
+// Migrator returns a migrate instance for the tool's storage. The toolbelt runs the postgres migrations up when the
+// tool is added, and the sqlite migrations are run up when the database is opened.
+func (d *WebhookRSS) Migrator() (*migrate.Migrate, error) {
+	switch backend := d.storageBackend(); backend {
+	case "postgres":
+		if d.db == nil {
+			return nil, fmt.Errorf("database not set")
+		}
+
+		// this matches the migrations table used by the toolbelt
+		driver, err := migratePostgres.WithInstance(d.db, &migratePostgres.Config{
+			MigrationsTable: "schema_migrations_webhook_rss",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create postgres migration driver: %w", err)
+		}
+
+		source, err := iofs.New(webhookRSSToolMigrations, "migrations")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load postgres migrations: %w", err)
+		}
+
+		return migrate.NewWithInstance("iofs", source, "postgres", driver)
+	case "sqlite":
+		s, ok := d.store.(*store.SQL)
+		if !ok {
+			return nil, fmt.Errorf("storage not set")
+		}
+
+		return s.SQLiteMigrator()
+	default:
+		return nil, fmt.Errorf("the %s storage backend doesn't have migrations", backend)
+	}
+}
+
+// ValidateConfig loads each part of the tool's config, returning the first error found. Storage isn't opened and
+// nothing is started.
+func ValidateConfig(config map[string]any) error {
+	d := &WebhookRSS{
+		config: gabs.Wrap(config),
+		// the store and broadcaster are stand ins, so that loading config which refers to them doesn't connect to
+		// anything
+		store:       store.NewMemory(),
+		broadcaster: events.NewBroadcaster(),
+	}
+
+	switch backend := d.storageBackend(); backend {
+	case "postgres", "memory":
+	case "sqlite":
+		path, err := d.optionalString("storage.sqlite.path", "")
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("missing required config path: storage.sqlite.path")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
+
+	listenDSN, err := d.optionalString("events.postgres.dsn", "")
+	if err != nil {
+		return err
+	}
+	if listenDSN != "" && d.storageBackend() != "postgres" {
+		return fmt.Errorf("events.postgres.dsn requires the postgres storage backend")
+	}
+
+	for _, path := range []string{"limits.max_request_bytes", "limits.max_items_per_request", "limits.max_feed_bytes_per_day", "limits.max_import_bytes"} {
+		_, err = d.optionalInt(path, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = d.feedLocations()
+	if err != nil {
+		return fmt.Errorf("failed to load feed config: %w", err)
+	}
+	_, err = d.feedInfo()
+	if err != nil {
+		return fmt.Errorf("failed to load feed config: %w", err)
+	}
+	_, err = d.rateLimitOptions()
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit config: %w", err)
+	}
+	_, err = d.newMailServer(store.InsertOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to load smtp config: %w", err)
+	}
+	_, err = d.optionalString("admin.token", "")
+	if err != nil {
+		return err
+	}
+	_, err = d.optionalBool("metrics.enabled", true)
+	if err != nil {
+		return err
+	}
+	_, err = d.optionalString("metrics.token", "")
+	if err != nil {
+		return err
+	}
+	_, err = d.optionalString("status.token", "")
+	if err != nil {
+		return err
+	}
+
+	// jobs load the remaining config, including the websub, fanout, poll and digest blocks
+	_, err = d.Jobs()
+	if err != nil {
+		return fmt.Errorf("failed to load jobs: %w", err)
+	}
+
+	return nil
+}
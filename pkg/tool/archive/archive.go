@@ -0,0 +1,239 @@
+// Package archive exports feeds to portable files and imports them again. Feeds are exported as NDJSON, one item per
+// line, or as a standalone Atom document. Imports accept the same NDJSON, or any RSS, Atom or JSON Feed file.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Export formats
+const (
+	FormatNDJSON = "ndjson"
+	FormatAtom   = "atom"
+)
+
+// batchSize is the number of items loaded or inserted at a time
+const batchSize = 500
+
+// Record is a line in an NDJSON archive
+type Record struct {
+	Feed      string     `json:"feed"`
+	GUID      string     `json:"guid"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	Tags      store.Tags `json:"tags,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Export writes every item in each of the feeds to w in the given format, feeds are written in the order given and
+// items in the order they were inserted. Items scheduled for the future are included.
+func Export(ctx context.Context, w io.Writer, items store.ItemStore, feeds []string, format string) error {
+	switch format {
+	case FormatNDJSON:
+		return exportNDJSON(ctx, w, items, feeds)
+	case FormatAtom:
+		return exportAtom(ctx, w, items, feeds)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// AllFeeds returns the names of the feeds with items, it's used to export everything
+func AllFeeds(ctx context.Context, items store.ItemStore) ([]string, error) {
+	stats, err := items.FeedStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feeds: %w", err)
+	}
+
+	var feeds []string
+	for _, s := range stats {
+		feeds = append(feeds, s.Feed)
+	}
+
+	return feeds, nil
+}
+
+// eachItem calls fn with each item in the feeds, loading them in batches so large feeds aren't held in memory
+func eachItem(ctx context.Context, items store.ItemStore, feeds []string, fn func(item store.Item) error) error {
+	for _, feed := range feeds {
+		var afterID int64
+		for {
+			batch, err := items.ListAllItemsAfter(ctx, feed, afterID, batchSize)
+			if err != nil {
+				return fmt.Errorf("failed to list items in %s: %w", feed, err)
+			}
+
+			for _, item := range batch {
+				err = fn(item)
+				if err != nil {
+					return err
+				}
+				afterID = item.ID
+			}
+
+			if len(batch) < batchSize {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func exportNDJSON(ctx context.Context, w io.Writer, items store.ItemStore, feeds []string) error {
+	enc := json.NewEncoder(w)
+	// bodies are often HTML, which is easier to read in an archive unescaped
+	enc.SetEscapeHTML(false)
+
+	return eachItem(ctx, items, feeds, func(item store.Item) error {
+		err := enc.Encode(Record{
+			Feed:      item.Feed,
+			GUID:      item.GUID,
+			Title:     item.Title,
+			Body:      item.Body,
+			URL:       item.URL,
+			Tags:      item.Tags,
+			CreatedAt: item.CreatedAt.UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write item: %w", err)
+		}
+
+		return nil
+	})
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomSource records the feed an entry was exported from, as described for entries copied from other feeds
+type atomSource struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+}
+
+type atomEntry struct {
+	XMLName    xml.Name       `xml:"entry"`
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    *atomText      `xml:"content"`
+	Source     atomSource     `xml:"source"`
+}
+
+// exportAtom writes the items as entries of a single Atom feed. Entry ids are the items' guids, so an archive can be
+// imported again without creating duplicates.
+func exportAtom(ctx context.Context, w io.Writer, items store.ItemStore, feeds []string) error {
+	title := "webhook-rss archive"
+	id := "urn:webhook-rss:archive"
+	if len(feeds) == 1 {
+		title = feeds[0]
+		id = feedID(feeds[0])
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	feedStart := xml.StartElement{
+		Name: xml.Name{Local: "feed"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "http://www.w3.org/2005/Atom"}},
+	}
+	header := []struct {
+		name  string
+		value string
+	}{
+		{"id", id},
+		{"title", title},
+		{"updated", time.Now().UTC().Format(time.RFC3339)},
+	}
+
+	err = enc.EncodeToken(feedStart)
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	for _, h := range header {
+		err = enc.EncodeElement(h.value, xml.StartElement{Name: xml.Name{Local: h.name}})
+		if err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+
+	err = eachItem(ctx, items, feeds, func(item store.Item) error {
+		date := item.CreatedAt.UTC().Format(time.RFC3339Nano)
+
+		entry := atomEntry{
+			ID:        item.GUID,
+			Title:     atomText{Type: "text", Value: item.Title},
+			Published: date,
+			Updated:   date,
+			Source:    atomSource{ID: feedID(item.Feed), Title: item.Feed},
+		}
+		if item.URL != "" {
+			entry.Links = append(entry.Links, atomLink{Href: item.URL, Rel: "alternate"})
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if item.Body != "" {
+			entry.Content = &atomText{Type: "html", Value: item.Body}
+		}
+
+		err := enc.Encode(entry)
+		if err != nil {
+			return fmt.Errorf("failed to write item: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = enc.EncodeToken(feedStart.End())
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	err = enc.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return nil
+}
+
+func feedID(feed string) string {
+	return "urn:webhook-rss:feed:" + feed
+}
@@ -0,0 +1,216 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func seedStore(t *testing.T) store.Store {
+	s := store.NewMemory()
+
+	_, err := s.InsertItems(context.Background(), "alerts", []store.NewItem{
+		{
+			GUID:      "a",
+			Title:     "Disk full",
+			Body:      "<p>95% used</p>",
+			URL:       "https://example.com/a",
+			Tags:      store.Tags{"ops"},
+			CreatedAt: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{GUID: "b", Title: "Disk ok", CreatedAt: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	_, err = s.InsertItems(context.Background(), "builds", []store.NewItem{
+		{GUID: "c", Title: "Build passed", CreatedAt: time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestExportImportNDJSON(t *testing.T) {
+	ctx := context.Background()
+	source := seedStore(t)
+
+	feeds, err := AllFeeds(ctx, source)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"alerts", "builds"}, feeds)
+
+	var buf bytes.Buffer
+	err = Export(ctx, &buf, source, feeds, FormatNDJSON)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 3)
+	assert.Contains(t, buf.String(), `"body":"<p>95% used</p>"`)
+
+	target := store.NewMemory()
+
+	result, err := Import(ctx, bytes.NewReader(buf.Bytes()), target, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"alerts": 2, "builds": 1}, result.Created)
+	assert.Zero(t, result.Duplicates)
+
+	items, err := target.ListItemsAfter(ctx, "alerts", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "a", items[0].GUID)
+	assert.Equal(t, "<p>95% used</p>", items[0].Body)
+	assert.Equal(t, store.Tags{"ops"}, items[0].Tags)
+	assert.True(t, items[0].CreatedAt.Equal(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)), "dates should be kept")
+
+	// a second import of the same archive is skipped
+	result, err = Import(ctx, bytes.NewReader(buf.Bytes()), target, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"alerts": 0, "builds": 0}, result.Created)
+	assert.Equal(t, 3, result.Duplicates)
+
+	// the feed option moves every record into one feed
+	result, err = Import(ctx, bytes.NewReader(buf.Bytes()), target, ImportOptions{Feed: "restored"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"restored": 3}, result.Created)
+}
+
+func TestExportImportAtom(t *testing.T) {
+	ctx := context.Background()
+	source := seedStore(t)
+
+	var buf bytes.Buffer
+	err := Export(ctx, &buf, source, []string{"alerts"}, FormatAtom)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, buf.String(), `<category term="ops"></category>`)
+
+	_, err = Import(ctx, bytes.NewReader(buf.Bytes()), source, ImportOptions{})
+	require.Error(t, err)
+	assert.True(t, IsInvalid(err), "feed files need a feed to import into")
+
+	result, err := Import(ctx, bytes.NewReader(buf.Bytes()), source, ImportOptions{Feed: "alerts"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Duplicates, "entry ids should be the item guids")
+
+	result, err = Import(ctx, bytes.NewReader(buf.Bytes()), source, ImportOptions{Feed: "restored"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"restored": 2}, result.Created)
+
+	items, err := source.ListItemsAfter(ctx, "restored", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "Disk full", items[0].Title)
+	assert.Equal(t, "<p>95% used</p>", items[0].Body)
+	assert.Equal(t, "https://example.com/a", items[0].URL)
+	assert.Equal(t, store.Tags{"ops"}, items[0].Tags)
+	assert.True(t, items[0].CreatedAt.Equal(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+func TestImportFeeds(t *testing.T) {
+	testCases := map[string]string{
+		"rss": `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title>
+<item><title>Second</title><link>https://example.com/2</link><category>Release Notes</category>
+<pubDate>Tue, 03 Jan 2023 00:00:00 GMT</pubDate></item>
+<item><title>First</title><guid>first</guid><description>hello</description>
+<pubDate>Mon, 02 Jan 2023 00:00:00 GMT</pubDate></item>
+</channel></rss>`,
+		"json feed": `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Example",
+  "items": [
+    {"id": "https://example.com/2", "url": "https://example.com/2", "title": "Second",
+     "tags": ["Release Notes"], "date_published": "2023-01-03T00:00:00Z"},
+    {"id": "first", "title": "First", "content_html": "hello", "date_published": "2023-01-02T00:00:00Z"}
+  ]
+}`,
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemory()
+
+			result, err := Import(ctx, strings.NewReader(data), s, ImportOptions{Feed: "example"})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]int{"example": 2}, result.Created)
+
+			items, err := s.ListItemsAfter(ctx, "example", 0, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+
+			// items are inserted oldest first
+			assert.Equal(t, "First", items[0].Title)
+			assert.Equal(t, "first", items[0].GUID)
+			assert.Equal(t, "hello", items[0].Body)
+			assert.Equal(t, "Second", items[1].Title)
+			assert.Equal(t, "https://example.com/2", items[1].GUID, "entries without a guid should use their link")
+			assert.Equal(t, store.Tags{"release-notes"}, items[1].Tags)
+			assert.True(t, items[1].CreatedAt.Equal(time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)))
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	data := `{"feed":"alerts","guid":"a","title":"ok"}
+{"feed":"alerts","guid":"b","title":""}
+`
+	_, err := Import(ctx, strings.NewReader(data), s, ImportOptions{})
+	require.Error(t, err)
+	assert.True(t, IsInvalid(err))
+	assert.Equal(t, "record 2: missing title", err.Error())
+
+	items, err := s.ListItemsAfter(ctx, "alerts", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, items, "no items should be imported from an invalid file")
+
+	_, err = Import(ctx, strings.NewReader("not a feed"), s, ImportOptions{Feed: "alerts"})
+	require.Error(t, err)
+	assert.True(t, IsInvalid(err))
+}
+
+func TestArchiveHandlers(t *testing.T) {
+	source := seedStore(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export?feed=builds", nil)
+	rr := httptest.NewRecorder()
+	BuildExportHandler(source)(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), `filename="builds-`)
+	assert.Contains(t, rr.Body.String(), `"title":"Build passed"`)
+	assert.NotContains(t, rr.Body.String(), "Disk full")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/export?format=csv", nil)
+	rr = httptest.NewRecorder()
+	BuildExportHandler(source)(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	target := store.NewMemory()
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/import?feed=restored", strings.NewReader(`{"guid":"a","title":"x"}`))
+	rr = httptest.NewRecorder()
+	BuildImportHandler(target, 0)(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"created":{"restored":1},"duplicates":0}`, rr.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader(`{"guid":"a","title":"x"}`))
+	rr = httptest.NewRecorder()
+	BuildImportHandler(target, 0)(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/import?feed=restored", strings.NewReader(`{"guid":"b","title":"x"}`))
+	rr = httptest.NewRecorder()
+	BuildImportHandler(target, 10)(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// BuildExportHandler returns a handler which downloads an archive. The format query param is ndjson (the default)
+// or atom, and feeds are chosen with the feed query param, which can be repeated. All feeds are exported when no
+// feed is given.
+func BuildExportHandler(items store.ItemStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatNDJSON
+		}
+
+		contentType := "application/x-ndjson"
+		extension := "ndjson"
+		switch format {
+		case FormatNDJSON:
+		case FormatAtom:
+			contentType = "application/atom+xml; charset=utf-8"
+			extension = "atom"
+		default:
+			handlers.WriteError(w, http.StatusBadRequest, "format must be ndjson or atom")
+			return
+		}
+
+		feeds := r.URL.Query()["feed"]
+		for _, feed := range feeds {
+			if !handlers.ValidFeed(feed) {
+				handlers.WriteError(w, http.StatusBadRequest, "invalid feed name %q", feed)
+				return
+			}
+		}
+
+		if len(feeds) == 0 {
+			var err error
+			feeds, err = AllFeeds(r.Context(), items)
+			if err != nil {
+				handlers.WriteError(w, http.StatusInternalServerError, "%s", err)
+				return
+			}
+		}
+
+		name := "webhook-rss"
+		if len(feeds) == 1 {
+			name = feeds[0]
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().UTC().Format("20060102"), extension),
+		)
+
+		// the response has started by the time items are written, so failures can only be logged
+		err := Export(r.Context(), w, items, feeds, format)
+		if err != nil {
+			log.Printf("failed to export feeds: %s", err)
+		}
+	}
+}
+
+// BuildImportHandler returns a handler which imports the request body, an NDJSON archive or a feed file. The feed
+// query param sets the feed items are imported into.
+func BuildImportHandler(items store.ItemStore, maxBytes int64) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := r.Body
+		if maxBytes > 0 {
+			body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+
+		result, err := Import(r.Context(), body, items, ImportOptions{Feed: r.URL.Query().Get("feed")})
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				handlers.WriteError(w, http.StatusRequestEntityTooLarge, "import larger than %d bytes", maxBytes)
+			case IsInvalid(err):
+				handlers.WriteError(w, http.StatusBadRequest, "%s", err)
+			default:
+				handlers.WriteError(w, http.StatusInternalServerError, "failed to import: %s", err)
+			}
+			return
+		}
+
+		handlers.WriteJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,326 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// ImportOptions configures where imported items are inserted
+type ImportOptions struct {
+	// Feed is the feed items are imported into. It's required for RSS, Atom and JSON Feed files. For NDJSON archives
+	// it's optional, and replaces the feed of every record when set.
+	Feed string
+}
+
+// ImportResult counts the items imported into each feed
+type ImportResult struct {
+	// Created is the number of items created in each feed
+	Created map[string]int `json:"created"`
+	// Duplicates is the number of items skipped as their guid was already used in the feed, or earlier in the file
+	Duplicates int `json:"duplicates"`
+}
+
+// InvalidError is returned when the file being imported can't be parsed, or contains an invalid item. No items are
+// imported when it's returned.
+type InvalidError struct {
+	// Record is the position of the invalid item in the file, starting at 1, it's 0 for problems with the whole file
+	Record int
+	Reason string
+}
+
+func (e *InvalidError) Error() string {
+	if e.Record == 0 {
+		return e.Reason
+	}
+
+	return fmt.Sprintf("record %d: %s", e.Record, e.Reason)
+}
+
+// IsInvalid returns true when err is caused by the file being imported, rather than by storage
+func IsInvalid(err error) bool {
+	var invalid *InvalidError
+	return errors.As(err, &invalid)
+}
+
+// importedItem is an item read from a file, along with the feed it's imported into
+type importedItem struct {
+	feed string
+	item store.NewItem
+}
+
+// Import reads an NDJSON archive, or an RSS, Atom or JSON Feed file, and inserts its items. Items keep their dates,
+// and items with a guid already used in their feed are skipped, so importing the same file twice is safe. The
+// format is detected from the content.
+//
+// Imported items are inserted directly, they aren't fanned out or sent to subscribers as new items are.
+func Import(ctx context.Context, r io.Reader, items store.ItemStore, opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{Created: make(map[string]int)}
+
+	if opts.Feed != "" && !handlers.ValidFeed(opts.Feed) {
+		return result, &InvalidError{Reason: fmt.Sprintf("invalid feed name %q", opts.Feed)}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return result, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	var imported []importedItem
+	if isNDJSON(data) {
+		imported, err = parseNDJSON(data, opts)
+	} else {
+		imported, err = parseFeed(data, opts)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// items are grouped by feed, keeping the order in which feeds first appear
+	var feeds []string
+	byFeed := make(map[string][]store.NewItem)
+	seen := make(map[string]map[string]bool)
+	for _, i := range imported {
+		if seen[i.feed] == nil {
+			feeds = append(feeds, i.feed)
+			seen[i.feed] = make(map[string]bool)
+		}
+		if seen[i.feed][i.item.GUID] {
+			result.Duplicates++
+			continue
+		}
+		seen[i.feed][i.item.GUID] = true
+		byFeed[i.feed] = append(byFeed[i.feed], i.item)
+	}
+
+	for _, feed := range feeds {
+		created, duplicates, err := importFeed(ctx, items, feed, byFeed[feed])
+		if err != nil {
+			return result, err
+		}
+
+		result.Created[feed] += created
+		result.Duplicates += duplicates
+	}
+
+	return result, nil
+}
+
+// importFeed inserts the items which aren't already in the feed, oldest first so that item ids follow their dates
+func importFeed(ctx context.Context, items store.ItemStore, feed string, newItems []store.NewItem) (int, int, error) {
+	var guids []string
+	for _, item := range newItems {
+		guids = append(guids, item.GUID)
+	}
+
+	existing := make(map[string]bool)
+	for start := 0; start < len(guids); start += batchSize {
+		end := start + batchSize
+		if end > len(guids) {
+			end = len(guids)
+		}
+
+		found, err := items.ExistingGUIDs(ctx, feed, guids[start:end])
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, guid := range found {
+			existing[guid] = true
+		}
+	}
+
+	var toInsert []store.NewItem
+	for _, item := range newItems {
+		if !existing[item.GUID] {
+			toInsert = append(toInsert, item)
+		}
+	}
+
+	sort.SliceStable(toInsert, func(i, j int) bool {
+		return toInsert[i].CreatedAt.Before(toInsert[j].CreatedAt)
+	})
+
+	for start := 0; start < len(toInsert); start += batchSize {
+		end := start + batchSize
+		if end > len(toInsert) {
+			end = len(toInsert)
+		}
+
+		_, err := items.InsertItems(ctx, feed, toInsert[start:end], store.InsertOptions{})
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to insert items into %s: %w", feed, err)
+		}
+	}
+
+	return len(toInsert), len(newItems) - len(toInsert), nil
+}
+
+// isNDJSON returns true when data looks like an NDJSON archive rather than a feed. JSON Feeds are also JSON objects,
+// but are identified by their version.
+func isNDJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+
+	var first struct {
+		Version string `json:"version"`
+	}
+	err := json.NewDecoder(bytes.NewReader(trimmed)).Decode(&first)
+	if err != nil {
+		// a JSON Feed would have parsed, so leave the error to be reported with its record number
+		return true
+	}
+
+	return !strings.Contains(first.Version, "jsonfeed.org")
+}
+
+func parseNDJSON(data []byte, opts ImportOptions) ([]importedItem, error) {
+	var imported []importedItem
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for n := 1; dec.More(); n++ {
+		var record Record
+		err := dec.Decode(&record)
+		if err != nil {
+			return nil, &InvalidError{Record: n, Reason: fmt.Sprintf("failed to parse JSON: %s", err)}
+		}
+
+		feed := record.Feed
+		if opts.Feed != "" {
+			feed = opts.Feed
+		}
+
+		invalid := func(format string, a ...any) error {
+			return &InvalidError{Record: n, Reason: fmt.Sprintf(format, a...)}
+		}
+
+		switch {
+		case feed == "":
+			return nil, invalid("missing feed, set one for the whole import instead")
+		case !handlers.ValidFeed(feed):
+			return nil, invalid("invalid feed name %q", feed)
+		case record.GUID == "":
+			return nil, invalid("missing guid")
+		case record.Title == "":
+			return nil, invalid("missing title")
+		case len(record.Title) > store.MaxTitleBytes:
+			return nil, invalid("title too long")
+		case len(record.Body) > store.MaxBodyBytes:
+			return nil, invalid("body too long")
+		case len(record.Tags) > store.MaxItemTags:
+			return nil, invalid("too many tags")
+		}
+
+		item := store.NewItem{
+			GUID:      record.GUID,
+			Title:     record.Title,
+			Body:      record.Body,
+			URL:       record.URL,
+			CreatedAt: record.CreatedAt,
+		}
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = time.Now()
+		}
+
+		for _, tag := range record.Tags {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if !handlers.ValidTag(tag) {
+				return nil, invalid("invalid tag %q", tag)
+			}
+			if !item.Tags.Has(tag) {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+
+		imported = append(imported, importedItem{feed: feed, item: item})
+	}
+
+	return imported, nil
+}
+
+// parseFeed reads the entries of an RSS, Atom or JSON Feed file, these are converted in the same way as polled
+// entries, but keep their guids so that the file can be imported again
+func parseFeed(data []byte, opts ImportOptions) ([]importedItem, error) {
+	parsed, err := gofeed.NewParser().Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, &InvalidError{Reason: fmt.Sprintf("failed to parse file as NDJSON, RSS, Atom or JSON Feed: %s", err)}
+	}
+
+	if opts.Feed == "" {
+		return nil, &InvalidError{Reason: "a feed to import into must be set for RSS, Atom and JSON Feed files"}
+	}
+
+	var imported []importedItem
+	for _, entry := range parsed.Items {
+		imported = append(imported, importedItem{feed: opts.Feed, item: entryItem(entry)})
+	}
+
+	return imported, nil
+}
+
+func entryItem(entry *gofeed.Item) store.NewItem {
+	title := strings.TrimSpace(entry.Title)
+	if title == "" {
+		title = entry.Link
+	}
+	if title == "" {
+		title = "Untitled"
+	}
+
+	body := entry.Content
+	if body == "" {
+		body = entry.Description
+	}
+
+	item := store.NewItem{
+		GUID:      entryGUID(entry),
+		Title:     store.Truncate(title, store.MaxTitleBytes),
+		Body:      store.Truncate(body, store.MaxBodyBytes),
+		URL:       entry.Link,
+		CreatedAt: time.Now(),
+	}
+
+	if entry.PublishedParsed != nil {
+		item.CreatedAt = *entry.PublishedParsed
+	} else if entry.UpdatedParsed != nil {
+		item.CreatedAt = *entry.UpdatedParsed
+	}
+
+	// categories are kept as tags when they can be, others are dropped rather than failing the import
+	for _, category := range entry.Categories {
+		tag := strings.ToLower(strings.Join(strings.Fields(category), "-"))
+		if handlers.ValidTag(tag) && !item.Tags.Has(tag) && len(item.Tags) < 20 {
+			item.Tags = append(item.Tags, tag)
+		}
+	}
+
+	return item
+}
+
+// entryGUID identifies an entry by its guid, falling back to its link, and finally a hash of its title and date
+func entryGUID(entry *gofeed.Item) string {
+	if entry.GUID != "" {
+		return entry.GUID
+	}
+	if entry.Link != "" {
+		return entry.Link
+	}
+
+	sum := sha256.Sum256([]byte(entry.Title + "\n" + entry.Published + entry.Updated))
+
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,876 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/emersion/go-smtp"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/digest"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/fanout"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/mailin"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poll"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poster"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/ratelimit"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/websub"
+)
+
+// feedLocations loads the optional default timezone for each feed from the feeds config block, e.g.
+//
+//	feeds:
+//	  backups:
+//	    timezone: Europe/London
+func (d *WebhookRSS) feedLocations() (map[string]*time.Location, error) {
+	locations := make(map[string]*time.Location)
+
+	for feed, feedConfig := range d.config.Path("feeds").ChildrenMap() {
+		name, ok := feedConfig.Path("timezone").Data().(string)
+		if !ok || name == "" {
+			continue
+		}
+
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone for feed %s: %w", feed, err)
+		}
+
+		locations[feed] = loc
+	}
+
+	return locations, nil
+}
+
+// feedInfo loads the descriptions of feeds listed in the index from the feeds config block, e.g.
+//
+//	feeds:
+//	  backups:
+//	    title: Nightly backups
+//	    description: Results of the nightly backup jobs
+//	    owner: platform
+//	    tags: [ops]
+func (d *WebhookRSS) feedInfo() (map[string]handlers.FeedInfo, error) {
+	info := make(map[string]handlers.FeedInfo)
+
+	for feed, feedConfig := range d.config.Path("feeds").ChildrenMap() {
+		tags, err := stringList(feedConfig, "tags")
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", feed, err)
+		}
+
+		f := handlers.FeedInfo{Tags: tags}
+		f.Title, _ = feedConfig.Path("title").Data().(string)
+		f.Description, _ = feedConfig.Path("description").Data().(string)
+		f.Owner, _ = feedConfig.Path("owner").Data().(string)
+
+		info[feed] = f
+	}
+
+	return info, nil
+}
+
+// optionalInt returns the integer at the given config path, or def when it's not set
+func (d *WebhookRSS) optionalInt(path string, def int64) (int64, error) {
+	if !d.config.ExistsP(path) {
+		return def, nil
+	}
+
+	switch v := d.config.Path(path).Data().(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("config path %s must be an integer", path)
+	}
+}
+
+// optionalFloat returns the number at the given config path, or def when it's not set
+func (d *WebhookRSS) optionalFloat(path string, def float64) (float64, error) {
+	if !d.config.ExistsP(path) {
+		return def, nil
+	}
+
+	switch v := d.config.Path(path).Data().(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("config path %s must be a number", path)
+	}
+}
+
+// optionalString returns the string at the given config path, or def when it's not set
+func (d *WebhookRSS) optionalString(path string, def string) (string, error) {
+	if !d.config.ExistsP(path) {
+		return def, nil
+	}
+
+	v, ok := d.config.Path(path).Data().(string)
+	if !ok {
+		return "", fmt.Errorf("config path %s must be a string", path)
+	}
+
+	return v, nil
+}
+
+// jobRunRetention returns how long job runs are kept before the clean job removes them, 0 keeps them forever
+func (d *WebhookRSS) jobRunRetention() (time.Duration, error) {
+	retention, err := d.optionalString("job_runs.retention", "168h")
+	if err != nil {
+		return 0, err
+	}
+
+	duration, err := time.ParseDuration(retention)
+	if err != nil {
+		return 0, fmt.Errorf("config path job_runs.retention must be a duration: %w", err)
+	}
+
+	return duration, nil
+}
+
+// jobPoster returns the poster used by the jobs which post pulses and alerts to feeds
+func (d *WebhookRSS) jobPoster() (*poster.Poster, error) {
+	timeout, err := d.optionalString("job_requests.timeout", "10s")
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, err := d.optionalInt("job_requests.max_attempts", 3)
+	if err != nil {
+		return nil, err
+	}
+	token, err := d.optionalString("job_requests.token", "")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &poster.Poster{MaxAttempts: int(maxAttempts), Token: token}
+	p.Timeout, err = time.ParseDuration(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("config path job_requests.timeout must be a duration: %w", err)
+	}
+	if p.Timeout <= 0 || p.MaxAttempts < 1 {
+		return nil, fmt.Errorf("job_requests.timeout and job_requests.max_attempts must be positive")
+	}
+
+	return p, nil
+}
+
+// optionalBool returns the bool at the given config path, or def when it's not set
+func (d *WebhookRSS) optionalBool(path string, def bool) (bool, error) {
+	if !d.config.ExistsP(path) {
+		return def, nil
+	}
+
+	v, ok := d.config.Path(path).Data().(bool)
+	if !ok {
+		return false, fmt.Errorf("config path %s must be a bool", path)
+	}
+
+	return v, nil
+}
+
+// rateLimitOptions loads the optional rate limits for item creation, e.g.
+//
+//	rate_limits:
+//	  backend: postgres
+//	  trust_forwarded_for: true
+//	  feed:
+//	    per_minute: 60
+//	    burst: 120
+//	  client:
+//	    per_minute: 30
+//	    burst: 60
+func (d *WebhookRSS) rateLimitOptions() (handlers.RateLimitOptions, error) {
+	var opts handlers.RateLimitOptions
+
+	backend, err := d.optionalString("rate_limits.backend", "memory")
+	if err != nil {
+		return opts, err
+	}
+	if backend != "memory" && backend != "postgres" {
+		return opts, fmt.Errorf("unknown rate limit backend %q", backend)
+	}
+
+	opts.TrustForwardedFor, err = d.optionalBool("rate_limits.trust_forwarded_for", false)
+	if err != nil {
+		return opts, err
+	}
+
+	for _, scope := range []string{"feed", "client"} {
+		if !d.config.ExistsP("rate_limits." + scope) {
+			continue
+		}
+
+		perMinute, err := d.optionalFloat(fmt.Sprintf("rate_limits.%s.per_minute", scope), 0)
+		if err != nil {
+			return opts, err
+		}
+		if perMinute <= 0 {
+			return opts, fmt.Errorf("rate_limits.%s.per_minute must be greater than 0", scope)
+		}
+		burst, err := d.optionalFloat(fmt.Sprintf("rate_limits.%s.burst", scope), perMinute)
+		if err != nil {
+			return opts, err
+		}
+
+		rate := ratelimit.Rate{PerMinute: perMinute, Burst: burst}
+
+		var limiter ratelimit.Limiter = ratelimit.NewMemory(rate)
+		if backend == "postgres" {
+			if d.db == nil {
+				return opts, fmt.Errorf("the postgres rate limit backend requires the postgres storage backend")
+			}
+			limiter = ratelimit.NewPostgres(d.db, rate)
+		}
+
+		if scope == "feed" {
+			opts.Feed = limiter
+		} else {
+			opts.Client = limiter
+		}
+	}
+
+	return opts, nil
+}
+
+// storageBackend returns the configured storage backend, one of postgres (the default), sqlite or memory
+func (d *WebhookRSS) storageBackend() string {
+	if d.config == nil {
+		return "postgres"
+	}
+
+	backend, ok := d.config.Path("storage.backend").Data().(string)
+	if !ok || backend == "" {
+		return "postgres"
+	}
+
+	return backend
+}
+
+// baseURL returns the configured external URL of the tool, without a trailing slash
+func (d *WebhookRSS) baseURL() (string, error) {
+	baseURL, err := d.optionalString("base_url", "")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(baseURL, "/"), nil
+}
+
+// webSubHub returns the WebSub hub, or nil when it's not enabled, e.g.
+//
+//	base_url: https://example.com/webhook-rss
+//	websub:
+//	  enabled: true
+//	  default_lease_seconds: 864000
+//	  max_lease_seconds: 2592000
+//	  allow_private_callbacks: false
+func (d *WebhookRSS) webSubHub() (*websub.Hub, error) {
+	if d.hub != nil {
+		return d.hub, nil
+	}
+
+	enabled, err := d.optionalBool("websub.enabled", false)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	baseURL, err := d.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("websub requires base_url to be set")
+	}
+
+	defaultLease, err := d.optionalInt("websub.default_lease_seconds", 10*24*60*60)
+	if err != nil {
+		return nil, err
+	}
+	maxLease, err := d.optionalInt("websub.max_lease_seconds", 30*24*60*60)
+	if err != nil {
+		return nil, err
+	}
+	allowPrivateCallbacks, err := d.optionalBool("websub.allow_private_callbacks", false)
+	if err != nil {
+		return nil, err
+	}
+
+	selfURL := func(feed string) string {
+		return fmt.Sprintf("%s/feeds/%s.rss", baseURL, feed)
+	}
+
+	d.hub = &websub.Hub{
+		Subscriptions: d.store,
+		HubURL:        baseURL + "/hub",
+		SelfURL:       selfURL,
+		Render: func(ctx context.Context, feed string) (string, error) {
+			return handlers.RenderFeed(ctx, d.store, feed, selfURL(feed), handlers.FeedLinks{
+				Hub:  baseURL + "/hub",
+				Self: selfURL(feed),
+			})
+		},
+		Authorize:             handlers.BuildTopicAuthorizer(d.store, handlers.HubOptions{BaseURL: baseURL}),
+		DefaultLeaseSeconds:   defaultLease,
+		MaxLeaseSeconds:       maxLease,
+		AllowPrivateCallbacks: allowPrivateCallbacks,
+		Client:                websub.NewClient(allowPrivateCallbacks),
+	}
+
+	return d.hub, nil
+}
+
+// fanoutTargets loads the services new items are forwarded to, e.g.
+//
+//	fanout:
+//	  targets:
+//	  - name: slack-alerts
+//	    url: https://hooks.slack.com/services/...
+//	    format: slack
+//	    feeds: [alerts]
+//	    tags: [critical]
+//	    title_regex: "^(FAILED|ERROR)"
+//	  - name: pager
+//	    url: https://pager.example.com/events
+//	    format: template
+//	    template: '{"summary": {{ .Title | json }}}'
+func (d *WebhookRSS) fanoutTargets() ([]*fanout.Target, error) {
+	var targets []*fanout.Target
+	names := make(map[string]bool)
+
+	for i, c := range d.config.Path("fanout.targets").Children() {
+		target := &fanout.Target{}
+
+		var ok bool
+		target.Name, ok = c.Path("name").Data().(string)
+		if !ok || target.Name == "" {
+			return nil, fmt.Errorf("fanout target %d is missing a name", i)
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("fanout target %s is configured more than once", target.Name)
+		}
+		names[target.Name] = true
+
+		target.URL, ok = c.Path("url").Data().(string)
+		if !ok || target.URL == "" {
+			return nil, fmt.Errorf("fanout target %s is missing a url", target.Name)
+		}
+
+		var err error
+		target.Feeds, err = stringList(c, "feeds")
+		if err != nil {
+			return nil, fmt.Errorf("fanout target %s: %w", target.Name, err)
+		}
+		target.Tags, err = stringList(c, "tags")
+		if err != nil {
+			return nil, fmt.Errorf("fanout target %s: %w", target.Name, err)
+		}
+
+		if pattern, ok := c.Path("title_regex").Data().(string); ok && pattern != "" {
+			target.TitleRegex, err = regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("fanout target %s has an invalid title_regex: %w", target.Name, err)
+			}
+		}
+
+		target.Format, _ = c.Path("format").Data().(string)
+		switch target.Format {
+		case "":
+			target.Format = fanout.FormatJSON
+		case fanout.FormatJSON, fanout.FormatSlack, fanout.FormatDiscord:
+		case fanout.FormatTemplate:
+			text, ok := c.Path("template").Data().(string)
+			if !ok || text == "" {
+				return nil, fmt.Errorf("fanout target %s uses the template format without a template", target.Name)
+			}
+			target.Template, err = template.New(target.Name).Funcs(fanout.TemplateFuncs).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("fanout target %s has an invalid template: %w", target.Name, err)
+			}
+			target.ContentType, _ = c.Path("content_type").Data().(string)
+		default:
+			return nil, fmt.Errorf("fanout target %s has an unknown format %q", target.Name, target.Format)
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// stringList returns the list of strings at the given path in c, or nil when it's not set
+func stringList(c *gabs.Container, path string) ([]string, error) {
+	if !c.ExistsP(path) {
+		return nil, nil
+	}
+
+	var list []string
+	for _, v := range c.Path(path).Children() {
+		s, ok := v.Data().(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", path)
+		}
+		list = append(list, s)
+	}
+
+	return list, nil
+}
+
+// pollSources loads the external feeds which are polled into local feeds, e.g.
+//
+//	poll:
+//	  sources:
+//	  - name: go-blog
+//	    url: https://go.dev/blog/feed.atom
+//	    feed: go-blog
+//	    interval: 30m
+//	    tags: [golang]
+func (d *WebhookRSS) pollSources() ([]*poll.Source, error) {
+	var sources []*poll.Source
+	names := make(map[string]bool)
+
+	for i, c := range d.config.Path("poll.sources").Children() {
+		src := &poll.Source{}
+
+		var ok bool
+		src.Name, ok = c.Path("name").Data().(string)
+		if !ok || src.Name == "" {
+			return nil, fmt.Errorf("poll source %d is missing a name", i)
+		}
+		if names[src.Name] {
+			return nil, fmt.Errorf("poll source %s is configured more than once", src.Name)
+		}
+		names[src.Name] = true
+
+		src.URL, ok = c.Path("url").Data().(string)
+		if !ok || src.URL == "" {
+			return nil, fmt.Errorf("poll source %s is missing a url", src.Name)
+		}
+
+		src.Feed, ok = c.Path("feed").Data().(string)
+		if !ok || src.Feed == "" {
+			return nil, fmt.Errorf("poll source %s is missing a feed", src.Name)
+		}
+
+		if interval, ok := c.Path("interval").Data().(string); ok && interval != "" {
+			var err error
+			src.Interval, err = time.ParseDuration(interval)
+			if err != nil {
+				return nil, fmt.Errorf("poll source %s has an invalid interval: %w", src.Name, err)
+			}
+		}
+
+		var err error
+		src.Tags, err = stringList(c, "tags")
+		if err != nil {
+			return nil, fmt.Errorf("poll source %s: %w", src.Name, err)
+		}
+
+		sources = append(sources, src)
+	}
+
+	return sources, nil
+}
+
+// heartbeats loads the feeds checked by the deadman-check job, e.g.
+//
+//	jobs:
+//	  deadman-check:
+//	    pushover_app: xxx
+//	    pushover_token: xxx
+//	    heartbeats:
+//	    - name: backups
+//	      feed: backups-heartbeat
+//	      interval: 24h
+//	      grace: 1h
+//	      notify:
+//	        endpoint: http://localhost:3000/webhook-rss/feeds/alerts/items
+//	    - name: nightly-build
+//	      schedule: "0 3 * * *"
+//	      grace: 30m
+//
+// Heartbeats need an interval or a cron schedule. When no heartbeats are configured, the deadman feed is checked.
+// The heartbeats are returned without notifiers, which are only needed by the job and are set by
+// heartbeatsWithNotifiers.
+func (d *WebhookRSS) heartbeats() ([]jobs.Heartbeat, error) {
+	var err error
+
+	if !d.config.ExistsP("jobs.deadman-check.heartbeats") {
+		return []jobs.Heartbeat{
+			{Name: jobs.DeadmanFeed, Feed: jobs.DeadmanFeed, Interval: jobs.DeadmanMaxAge},
+		}, nil
+	}
+
+	var heartbeats []jobs.Heartbeat
+	names := make(map[string]bool)
+
+	for i, c := range d.config.Path("jobs.deadman-check.heartbeats").Children() {
+		var h jobs.Heartbeat
+
+		var ok bool
+		h.Name, ok = c.Path("name").Data().(string)
+		if !ok || h.Name == "" {
+			return nil, fmt.Errorf("heartbeat %d is missing a name", i)
+		}
+		// the name is used to tag the heartbeat's status changes
+		if !handlers.ValidTag(h.Name) {
+			return nil, fmt.Errorf("heartbeat name %q must be lowercase letters, numbers, dots, dashes or underscores", h.Name)
+		}
+		if jobs.ReservedHeartbeatName(h.Name) {
+			return nil, fmt.Errorf("heartbeat name %q is reserved for tagging pings", h.Name)
+		}
+		if names[h.Name] {
+			return nil, fmt.Errorf("heartbeat %s is configured more than once", h.Name)
+		}
+		names[h.Name] = true
+
+		h.Feed, _ = c.Path("feed").Data().(string)
+		if h.Feed == "" {
+			h.Feed = h.Name
+		}
+		if !handlers.ValidFeed(h.Feed) {
+			return nil, fmt.Errorf("heartbeat %s has an invalid feed name: %q", h.Name, h.Feed)
+		}
+
+		interval, _ := c.Path("interval").Data().(string)
+		h.Schedule, _ = c.Path("schedule").Data().(string)
+		switch {
+		case interval != "" && h.Schedule != "":
+			return nil, fmt.Errorf("heartbeat %s must have an interval or a schedule, not both", h.Name)
+		case h.Schedule != "":
+			_, err = jobs.ParseHeartbeatSchedule(h.Schedule)
+			if err != nil {
+				return nil, fmt.Errorf("heartbeat %s has an invalid schedule: %w", h.Name, err)
+			}
+		case interval != "":
+			h.Interval, err = time.ParseDuration(interval)
+			if err != nil || h.Interval <= 0 {
+				return nil, fmt.Errorf("heartbeat %s has an invalid interval: %q", h.Name, interval)
+			}
+		default:
+			return nil, fmt.Errorf("heartbeat %s is missing an interval or schedule", h.Name)
+		}
+
+		if grace, ok := c.Path("grace").Data().(string); ok && grace != "" {
+			h.Grace, err = time.ParseDuration(grace)
+			if err != nil || h.Grace < 0 {
+				return nil, fmt.Errorf("heartbeat %s has an invalid grace: %q", h.Name, grace)
+			}
+		}
+
+		heartbeats = append(heartbeats, h)
+	}
+
+	return heartbeats, nil
+}
+
+// heartbeatsWithNotifiers loads the heartbeats along with where they alert when they go down. Those without a
+// notify block are sent to pushover, using jobs.deadman-check.pushover_app and pushover_token.
+func (d *WebhookRSS) heartbeatsWithNotifiers() ([]jobs.Heartbeat, error) {
+	heartbeats, err := d.heartbeats()
+	if err != nil {
+		return nil, err
+	}
+
+	pushoverApp, err := d.optionalString("jobs.deadman-check.pushover_app", "")
+	if err != nil {
+		return nil, err
+	}
+	pushoverToken, err := d.optionalString("jobs.deadman-check.pushover_token", "")
+	if err != nil {
+		return nil, err
+	}
+	jobPoster, err := d.jobPoster()
+	if err != nil {
+		return nil, err
+	}
+
+	// configs is empty when the default deadman heartbeat is used, which has no notify block
+	configs := d.config.Path("jobs.deadman-check.heartbeats").Children()
+
+	for i, h := range heartbeats {
+		var c *gabs.Container
+		if i < len(configs) {
+			c = configs[i]
+		}
+
+		switch {
+		case c == nil || !c.ExistsP("notify"):
+			if pushoverApp == "" || pushoverToken == "" {
+				return nil, fmt.Errorf(
+					"heartbeat %s has no notify block and pushover_app and pushover_token are not set", h.Name,
+				)
+			}
+			heartbeats[i].Notifier = &jobs.PushoverNotifier{App: pushoverApp, Token: pushoverToken}
+		case c.ExistsP("notify.endpoint"):
+			endpoint, ok := c.Path("notify.endpoint").Data().(string)
+			if !ok || endpoint == "" {
+				return nil, fmt.Errorf("heartbeat %s has an invalid notify endpoint", h.Name)
+			}
+			heartbeats[i].Notifier = &jobs.FeedNotifier{Endpoint: endpoint, Poster: jobPoster}
+		default:
+			app, _ := c.Path("notify.pushover_app").Data().(string)
+			token, _ := c.Path("notify.pushover_token").Data().(string)
+			if app == "" || token == "" {
+				return nil, fmt.Errorf("heartbeat %s must notify an endpoint or set pushover_app and pushover_token", h.Name)
+			}
+			heartbeats[i].Notifier = &jobs.PushoverNotifier{App: app, Token: token}
+		}
+	}
+
+	return heartbeats, nil
+}
+
+// users loads the people who keep read, starred and archived state for items, e.g.
+//
+//	users:
+//	- name: alice
+//	  token: xxx
+//
+// The users are returned as a map of each token to the user's name.
+func (d *WebhookRSS) users() (map[string]string, error) {
+	users := make(map[string]string)
+	names := make(map[string]bool)
+
+	for i, c := range d.config.Path("users").Children() {
+		name, _ := c.Path("name").Data().(string)
+		if name == "" {
+			return nil, fmt.Errorf("user %d is missing a name", i)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("user %s is configured more than once", name)
+		}
+		names[name] = true
+
+		token, _ := c.Path("token").Data().(string)
+		if token == "" {
+			return nil, fmt.Errorf("user %s is missing a token", name)
+		}
+		if _, ok := users[token]; ok {
+			return nil, fmt.Errorf("user %s has the same token as another user", name)
+		}
+		users[token] = name
+	}
+
+	return users, nil
+}
+
+// priorityNotifier loads where items with a high priority are sent as soon as they're created, e.g.
+//
+//	priority_notifications:
+//	  min_priority: high
+//	  endpoint: http://localhost:3000/webhook-rss/feeds/alerts/items
+//
+// pushover_app and pushover_token can be set instead of an endpoint. The notifier is nil when neither is set.
+func (d *WebhookRSS) priorityNotifier() (jobs.Notifier, store.Priority, error) {
+	name, err := d.optionalString("priority_notifications.min_priority", string(store.PriorityHigh))
+	if err != nil {
+		return nil, "", err
+	}
+	minPriority, ok := store.ParsePriority(name)
+	if !ok {
+		return nil, "", fmt.Errorf("priority_notifications.min_priority must be low, normal, high or urgent: %q", name)
+	}
+
+	endpoint, err := d.optionalString("priority_notifications.endpoint", "")
+	if err != nil {
+		return nil, "", err
+	}
+	if endpoint != "" {
+		jobPoster, err := d.jobPoster()
+		if err != nil {
+			return nil, "", err
+		}
+		return &jobs.FeedNotifier{Endpoint: endpoint, Poster: jobPoster}, minPriority, nil
+	}
+
+	app, err := d.optionalString("priority_notifications.pushover_app", "")
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := d.optionalString("priority_notifications.pushover_token", "")
+	if err != nil {
+		return nil, "", err
+	}
+	if app != "" && token != "" {
+		return &jobs.PushoverNotifier{App: app, Token: token}, minPriority, nil
+	}
+
+	return nil, "", nil
+}
+
+// heartbeatMonitor returns the monitor which records heartbeats going up and down in the status feed, which is
+// set with jobs.deadman-check.status_feed
+func (d *WebhookRSS) heartbeatMonitor() (*jobs.HeartbeatMonitor, error) {
+	feed, err := d.optionalString("jobs.deadman-check.status_feed", "heartbeats")
+	if err != nil {
+		return nil, err
+	}
+	if !handlers.ValidFeed(feed) {
+		return nil, fmt.Errorf("jobs.deadman-check.status_feed is not a valid feed name: %q", feed)
+	}
+
+	hooks, err := d.itemCreatedHooks()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobs.HeartbeatMonitor{Items: d.store, States: d.store, Feed: feed, OnCreated: hooks}, nil
+}
+
+// newMailServer returns the SMTP server accepting items by email, or nil when it's not enabled, e.g.
+//
+//	smtp:
+//	  enabled: true
+//	  listen: ":2525"
+//	  domain: feeds.example.com
+//	  allowed_senders: [alerts@example.com, "@ci.example.com"]
+//	  max_message_bytes: 10485760
+func (d *WebhookRSS) newMailServer(opts store.InsertOptions) (*smtp.Server, error) {
+	enabled, err := d.optionalBool("smtp.enabled", false)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	listen, err := d.optionalString("smtp.listen", ":2525")
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := d.optionalString("smtp.domain", "")
+	if err != nil {
+		return nil, err
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("missing required config path: smtp.domain")
+	}
+
+	allowedSenders, err := stringList(d.config, "smtp.allowed_senders")
+	if err != nil {
+		return nil, err
+	}
+	// mail from anyone would allow anyone to write to any feed
+	if len(allowedSenders) == 0 {
+		return nil, fmt.Errorf("smtp.allowed_senders must list at least one sender")
+	}
+
+	maxMessageBytes, err := d.optionalInt("smtp.max_message_bytes", 10*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks, err := d.itemCreatedHooks()
+	if err != nil {
+		return nil, err
+	}
+
+	server := mailin.NewServer(&mailin.Backend{
+		Domain:         domain,
+		AllowedSenders: allowedSenders,
+		Items:          d.store,
+		Attachments:    d.store,
+		InsertOptions:  opts,
+		OnCreated:      hooks,
+	}, maxMessageBytes)
+	server.Addr = listen
+
+	return server, nil
+}
+
+// digestJobs loads the digests which summarise chatty feeds, each is run by its own job, e.g.
+//
+//	digests:
+//	- name: alerts-daily
+//	  source: alerts
+//	  feed: alerts-digest
+//	  schedule: "0 0 8 * * *"
+//	  group_by: tag
+//	  title: "{{ len .Items }} alerts"
+//	  template: "{{ range .Items }}<p>{{ .Title }}</p>{{ end }}"
+//	  tags: [digest]
+func (d *WebhookRSS) digestJobs() ([]*jobs.Digest, error) {
+	var digestJobs []*jobs.Digest
+	names := make(map[string]bool)
+
+	for i, c := range d.config.Path("digests").Children() {
+		dg := &digest.Digest{}
+
+		var ok bool
+		dg.Name, ok = c.Path("name").Data().(string)
+		if !ok || dg.Name == "" {
+			return nil, fmt.Errorf("digest %d is missing a name", i)
+		}
+		if names[dg.Name] {
+			return nil, fmt.Errorf("digest %s is configured more than once", dg.Name)
+		}
+		names[dg.Name] = true
+
+		dg.Source, ok = c.Path("source").Data().(string)
+		if !ok || dg.Source == "" {
+			return nil, fmt.Errorf("digest %s is missing a source", dg.Name)
+		}
+		dg.Feed, ok = c.Path("feed").Data().(string)
+		if !ok || dg.Feed == "" {
+			return nil, fmt.Errorf("digest %s is missing a feed", dg.Name)
+		}
+		// a digest posted to its own source would be included in the next digest
+		if dg.Feed == dg.Source {
+			return nil, fmt.Errorf("digest %s must post to a different feed than its source", dg.Name)
+		}
+
+		dg.GroupBy, _ = c.Path("group_by").Data().(string)
+		switch dg.GroupBy {
+		case "":
+			dg.GroupBy = digest.GroupByNone
+		case digest.GroupByNone, digest.GroupByTag, digest.GroupByTitle:
+		default:
+			return nil, fmt.Errorf("digest %s has an unknown group_by %q", dg.Name, dg.GroupBy)
+		}
+
+		var err error
+		if text, ok := c.Path("title").Data().(string); ok && text != "" {
+			dg.Title, err = template.New(dg.Name).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("digest %s has an invalid title: %w", dg.Name, err)
+			}
+		}
+		if text, ok := c.Path("template").Data().(string); ok && text != "" {
+			dg.Body, err = htmltemplate.New(dg.Name).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("digest %s has an invalid template: %w", dg.Name, err)
+			}
+		}
+
+		if maxItems, ok := c.Path("max_items").Data().(float64); ok {
+			dg.MaxItems = int(maxItems)
+		}
+
+		dg.Tags, err = stringList(c, "tags")
+		if err != nil {
+			return nil, fmt.Errorf("digest %s: %w", dg.Name, err)
+		}
+
+		schedule, _ := c.Path("schedule").Data().(string)
+
+		digestJobs = append(digestJobs, &jobs.Digest{
+			Digest:           dg,
+			ScheduleOverride: schedule,
+		})
+	}
+
+	return digestJobs, nil
+}
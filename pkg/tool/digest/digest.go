@@ -0,0 +1,265 @@
+// Package digest summarises chatty feeds. A digest collects the items posted to a source feed since it last ran
+// and posts a single item listing them into another feed.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Ways of grouping items in a digest
+const (
+	GroupByNone  = "none"
+	GroupByTag   = "tag"
+	GroupByTitle = "title"
+)
+
+// DefaultTitle is used to render digest titles when a digest doesn't set its own
+var DefaultTitle = template.Must(template.New("title").Parse(
+	`{{ .Source }} digest: {{ len .Items }} item{{ if ne (len .Items) 1 }}s{{ end }}`,
+))
+
+// DefaultBody is used to render digest bodies when a digest doesn't set its own
+var DefaultBody = htmltemplate.Must(htmltemplate.New("body").Parse(
+	`{{ range .Groups }}{{ if .Name }}<h3>{{ .Name }}</h3>{{ end }}<ul>` +
+		`{{ range .Items }}<li>{{ if .URL }}<a href="{{ .URL }}">{{ .Title }}</a>{{ else }}{{ .Title }}{{ end }}</li>{{ end }}` +
+		`</ul>{{ end }}` +
+		`{{ if .Omitted }}<p>and {{ .Omitted }} more</p>{{ end }}`,
+))
+
+// Digest summarises the items in a source feed into another feed
+type Digest struct {
+	Name string
+	// Source is the feed summarised by the digest
+	Source string
+	// Feed is the feed digests are posted to
+	Feed string
+
+	// GroupBy is one of none, tag or title
+	GroupBy string
+	// Title and Body render the digest item, they default to DefaultTitle and DefaultBody
+	Title *template.Template
+	Body  *htmltemplate.Template
+	// MaxItems limits the items listed in a digest, the rest are counted as omitted. Defaults to 200.
+	MaxItems int
+	// Tags are added to each digest item
+	Tags []string
+}
+
+// Data is passed to the title and body templates
+type Data struct {
+	Name   string
+	Source string
+	// From is the time of the previous digest, it's zero for the first digest
+	From time.Time
+	To   time.Time
+
+	// Items are the items included, in the order they were posted
+	Items []store.Item
+	// Groups are the items grouped as configured, there's a single unnamed group when items aren't grouped
+	Groups []Group
+	// Omitted is the number of items not included as the digest was too long
+	Omitted int
+}
+
+// Group is a set of items in a digest with the same tag or title
+type Group struct {
+	Name  string
+	Items []store.Item
+}
+
+// Digester creates digests
+type Digester struct {
+	Items store.ItemStore
+	State store.DigestStore
+
+	// OnCreated is called with each digest item created
+	OnCreated []func(ctx context.Context, feed string, items []store.Item)
+
+	// now is used in tests to set the time digests are run at
+	now func() time.Time
+}
+
+// Run posts a digest of the items in the source feed since the last digest. No digest is posted when there are no
+// new items. Items are followed by id so that none are counted twice. Items which are scheduled for the future are
+// left until they're published, and the items after them which were included are recorded so that they aren't
+// included again.
+func (d *Digester) Run(ctx context.Context, digest *Digest) error {
+	state, found, err := d.State.GetDigestState(ctx, digest.Name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		state.Name = digest.Name
+	}
+
+	// scheduled items are listed so that the digest doesn't move past them before they're published
+	items, err := d.Items.ListAllItemsAfter(ctx, digest.Source, state.LastItemID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list items: %w", err)
+	}
+
+	now := time.Now()
+	if d.now != nil {
+		now = d.now()
+	}
+
+	// lastItemID only moves up to the first scheduled item, later items are tracked in included instead
+	lastItemID := state.LastItemID
+	var included store.ItemIDs
+	scheduled := false
+
+	var published []store.Item
+	for _, item := range items {
+		if item.CreatedAt.After(now) {
+			scheduled = true
+			continue
+		}
+
+		if scheduled {
+			included = append(included, item.ID)
+		} else {
+			lastItemID = item.ID
+		}
+
+		if !state.IncludedIDs.Has(item.ID) {
+			published = append(published, item)
+		}
+	}
+
+	if len(published) == 0 {
+		return nil
+	}
+
+	data := digest.data(published, state.LastDigestAt, now)
+
+	item, err := digest.render(data)
+	if err != nil {
+		return err
+	}
+
+	item.GUID = fmt.Sprintf("digest-%s-%d-%d", digest.Name, published[0].ID, published[len(published)-1].ID)
+
+	state.LastItemID = lastItemID
+	state.IncludedIDs = included
+	state.LastDigestAt = now
+
+	// the state is saved with the digest, so a digest isn't posted twice or lost if either fails
+	created, err := d.State.InsertDigestItem(ctx, digest.Feed, item, state)
+	if err != nil {
+		return fmt.Errorf("failed to insert digest: %w", err)
+	}
+
+	for _, fn := range d.OnCreated {
+		fn(ctx, digest.Feed, []store.Item{created})
+	}
+
+	return nil
+}
+
+// data returns the template data for a digest of the items
+func (d *Digest) data(items []store.Item, from, to time.Time) Data {
+	maxItems := d.MaxItems
+	if maxItems == 0 {
+		maxItems = 200
+	}
+
+	data := Data{
+		Name:   d.Name,
+		Source: d.Source,
+		From:   from,
+		To:     to,
+		Items:  items,
+	}
+
+	// the newest items are listed when there are too many
+	if len(items) > maxItems {
+		data.Omitted = len(items) - maxItems
+		data.Items = items[len(items)-maxItems:]
+	}
+
+	data.Groups = group(data.Items, d.GroupBy)
+
+	return data
+}
+
+// render returns the digest item for the data
+func (d *Digest) render(data Data) (store.NewItem, error) {
+	titleTemplate := d.Title
+	if titleTemplate == nil {
+		titleTemplate = DefaultTitle
+	}
+	bodyTemplate := d.Body
+	if bodyTemplate == nil {
+		bodyTemplate = DefaultBody
+	}
+
+	var title, body bytes.Buffer
+
+	err := titleTemplate.Execute(&title, data)
+	if err != nil {
+		return store.NewItem{}, fmt.Errorf("failed to render digest title: %w", err)
+	}
+	if title.Len() == 0 {
+		return store.NewItem{}, fmt.Errorf("digest title rendered empty")
+	}
+
+	err = bodyTemplate.Execute(&body, data)
+	if err != nil {
+		return store.NewItem{}, fmt.Errorf("failed to render digest body: %w", err)
+	}
+
+	return store.NewItem{
+		Title:     title.String(),
+		Body:      body.String(),
+		Tags:      append(store.Tags{}, d.Tags...),
+		CreatedAt: data.To,
+	}, nil
+}
+
+// group returns the items grouped by tag or title. Groups are sorted by name, items with more than one tag are in
+// each of their groups and untagged items are grouped last.
+func group(items []store.Item, by string) []Group {
+	if by != GroupByTag && by != GroupByTitle {
+		return []Group{{Items: items}}
+	}
+
+	groups := make(map[string][]store.Item)
+	var untagged []store.Item
+
+	for _, item := range items {
+		switch {
+		case by == GroupByTitle:
+			groups[item.Title] = append(groups[item.Title], item)
+		case len(item.Tags) == 0:
+			untagged = append(untagged, item)
+		default:
+			for _, tag := range item.Tags {
+				groups[tag] = append(groups[tag], item)
+			}
+		}
+	}
+
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []Group
+	for _, name := range names {
+		result = append(result, Group{Name: name, Items: groups[name]})
+	}
+	if len(untagged) > 0 {
+		result = append(result, Group{Name: "untagged", Items: untagged})
+	}
+
+	return result
+}
@@ -0,0 +1,116 @@
+package digest
+
+import (
+	"context"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestDigester(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	var notified int
+	d := &Digester{
+		Items: s,
+		State: s,
+		OnCreated: []func(ctx context.Context, feed string, items []store.Item){
+			func(ctx context.Context, feed string, items []store.Item) { notified += len(items) },
+		},
+	}
+	digest := &Digest{
+		Name:    "alerts-daily",
+		Source:  "alerts",
+		Feed:    "alerts-digest",
+		GroupBy: GroupByTag,
+		Tags:    []string{"digest"},
+	}
+
+	require.NoError(t, d.Run(ctx, digest))
+	items, err := s.ListItems(ctx, "alerts-digest", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, items, "no digest should be posted without items")
+
+	_, err = s.InsertItems(ctx, "alerts", []store.NewItem{
+		{GUID: "a", Title: "disk full", Tags: store.Tags{"critical", "storage"}, CreatedAt: time.Now().Add(-time.Minute)},
+		{GUID: "b", Title: "backup ok", URL: "https://example.com/b", CreatedAt: time.Now().Add(-time.Minute)},
+		{GUID: "c", Title: "scheduled", CreatedAt: time.Now().Add(time.Hour)},
+		{GUID: "d", Title: "after scheduled", CreatedAt: time.Now().Add(-time.Minute)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, d.Run(ctx, digest))
+
+	items, err = s.ListItems(ctx, "alerts-digest", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "alerts digest: 3 items", items[0].Title, "scheduled items shouldn't hold up later items")
+	assert.Equal(t,
+		`<h3>critical</h3><ul><li>disk full</li></ul>`+
+			`<h3>storage</h3><ul><li>disk full</li></ul>`+
+			`<h3>untagged</h3><ul><li><a href="https://example.com/b">backup ok</a></li><li>after scheduled</li></ul>`,
+		items[0].Body,
+	)
+	assert.Equal(t, store.Tags{"digest"}, items[0].Tags)
+	assert.Equal(t, 1, notified)
+
+	// a missed or repeated run doesn't post the same items again
+	require.NoError(t, d.Run(ctx, digest))
+	items, err = s.ListItems(ctx, "alerts-digest", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	// the state is saved with the digest, up to the scheduled item
+	state, _, err := s.GetDigestState(ctx, "alerts-daily")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), state.LastItemID)
+	assert.Equal(t, store.ItemIDs{4}, state.IncludedIDs)
+	assert.Equal(t, 1, notified)
+
+	// once the scheduled item is published, it's included in the next digest without the items around it
+	d.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	require.NoError(t, d.Run(ctx, digest))
+	// the digest is dated at the run time, so it's listed along with scheduled items
+	items, err = s.ListAllItemsAfter(ctx, "alerts-digest", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "alerts digest: 1 item", items[1].Title)
+	assert.Equal(t, `<h3>untagged</h3><ul><li>scheduled</li></ul>`, items[1].Body)
+
+	state, _, err = s.GetDigestState(ctx, "alerts-daily")
+	require.NoError(t, err)
+	assert.Empty(t, state.IncludedIDs, "included items should be forgotten once the scheduled item is published")
+
+	require.NoError(t, d.Run(ctx, digest))
+	items, err = s.ListAllItemsAfter(ctx, "alerts-digest", 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestDigestData(t *testing.T) {
+	items := []store.Item{
+		{ID: 1, Title: "build failed"},
+		{ID: 2, Title: "build passed"},
+		{ID: 3, Title: "build failed"},
+	}
+
+	digest := &Digest{Source: "ci", GroupBy: GroupByTitle, MaxItems: 2}
+	data := digest.data(items, time.Time{}, time.Now())
+
+	assert.Equal(t, 1, data.Omitted)
+	require.Len(t, data.Groups, 2)
+	assert.Equal(t, "build failed", data.Groups[0].Name)
+	assert.Len(t, data.Groups[0].Items, 1)
+
+	digest.Title = template.Must(template.New("title").Parse(`{{ .Source }}: {{ len .Groups }} kinds`))
+	item, err := digest.render(data)
+	require.NoError(t, err)
+	assert.Equal(t, "ci: 2 kinds", item.Title)
+	assert.Contains(t, item.Body, "<p>and 1 more</p>")
+}
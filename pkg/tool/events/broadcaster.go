@@ -0,0 +1,73 @@
+package events
+
+import (
+	"sync"
+)
+
+// Broadcaster wakes subscribers to a feed when new items have been inserted. Notifications don't carry the items,
+// subscribers load any items they haven't seen from the store, so missed or repeated notifications are harmless.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]bool
+}
+
+// NewBroadcaster returns a Broadcaster for notifications within the current process
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscribers: make(map[string]map[chan struct{}]bool),
+	}
+}
+
+// Subscribe returns a channel which receives a value after items are inserted into the feed. Notifications received
+// while a previous one is still pending are combined. The returned function must be called to unsubscribe.
+func (b *Broadcaster) Subscribe(feed string) (<-chan struct{}, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+
+	if b.subscribers[feed] == nil {
+		b.subscribers[feed] = make(map[chan struct{}]bool)
+	}
+	b.subscribers[feed][ch] = true
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers[feed], ch)
+		if len(b.subscribers[feed]) == 0 {
+			delete(b.subscribers, feed)
+		}
+	}
+}
+
+// Notify wakes the subscribers to a feed
+func (b *Broadcaster) Notify(feed string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[feed] {
+		wake(ch)
+	}
+}
+
+// NotifyAll wakes the subscribers to every feed, this is used when notifications may have been missed
+func (b *Broadcaster) NotifyAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			wake(ch)
+		}
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+		// a notification is already pending
+	}
+}
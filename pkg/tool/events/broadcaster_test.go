@@ -0,0 +1,33 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+
+	example, unsubscribeExample := b.Subscribe("example")
+	other, unsubscribeOther := b.Subscribe("other")
+	defer unsubscribeOther()
+
+	// pending notifications are combined
+	b.Notify("example")
+	b.Notify("example")
+
+	assert.Len(t, example, 1)
+	assert.Len(t, other, 0)
+	<-example
+
+	b.NotifyAll()
+	assert.Len(t, example, 1)
+	assert.Len(t, other, 1)
+	<-example
+	<-other
+
+	unsubscribeExample()
+	b.Notify("example")
+	assert.Len(t, example, 0, "unsubscribed channels should not be notified")
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// ListenPostgres forwards notifications of new items sent by other replicas, via Postgres LISTEN/NOTIFY, to the
+// broadcaster. database/sql doesn't expose notifications, so a dedicated connection is opened using dsn. It returns
+// once listening has started, and stops listening when ctx is done.
+func ListenPostgres(ctx context.Context, dsn string, broadcaster *Broadcaster) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("postgres item listener error: %s", err)
+		}
+	})
+
+	err := listener.Listen(store.ItemsChannel)
+	if err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to listen for new items: %w", err)
+	}
+
+	go func() {
+		defer listener.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				// a nil notification is sent after reconnecting, when notifications may have been missed
+				if n == nil {
+					broadcaster.NotifyAll()
+					continue
+				}
+				broadcaster.Notify(n.Extra)
+			case <-time.After(time.Minute):
+				// check the connection is still alive, it's re-established if not
+				go listener.Ping()
+			}
+		}
+	}()
+
+	return nil
+}
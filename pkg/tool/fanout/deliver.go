@@ -0,0 +1,134 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Deliverer sends deliveries from the outbox to their targets
+type Deliverer struct {
+	Targets []*Target
+	Outbox  store.OutboxStore
+
+	// MaxAttempts is the number of attempts made before a delivery is dead lettered, defaults to 8
+	MaxAttempts int
+	// BatchSize is the number of deliveries claimed at a time, defaults to 50
+	BatchSize int
+
+	Client *http.Client
+}
+
+// Deliver attempts the deliveries which are due, until none are left or ctx is done. It returns the number of
+// deliveries which were sent successfully.
+func (d *Deliverer) Deliver(ctx context.Context) (int, error) {
+	batchSize := d.BatchSize
+	if batchSize == 0 {
+		batchSize = 50
+	}
+
+	targets := make(map[string]*Target)
+	for _, t := range d.Targets {
+		targets[t.Name] = t
+	}
+
+	var delivered int
+	for ctx.Err() == nil {
+		// deliveries are claimed for long enough to attempt each of them, even if every one times out
+		deliveries, err := d.Outbox.ClaimDeliveries(ctx, batchSize, time.Duration(batchSize)*d.timeout()+time.Minute)
+		if err != nil {
+			return delivered, fmt.Errorf("failed to claim deliveries: %w", err)
+		}
+
+		if len(deliveries) == 0 {
+			return delivered, nil
+		}
+
+		for _, delivery := range deliveries {
+			target, ok := targets[delivery.Target]
+			if !ok {
+				err = d.Outbox.DeadLetterDelivery(ctx, delivery.ID, "target is no longer configured")
+				if err != nil {
+					return delivered, err
+				}
+				continue
+			}
+
+			sendErr := d.send(ctx, target, delivery)
+			switch {
+			case sendErr == nil:
+				err = d.Outbox.CompleteDelivery(ctx, delivery.ID)
+				delivered++
+			case delivery.Attempts >= d.maxAttempts():
+				err = d.Outbox.DeadLetterDelivery(ctx, delivery.ID, sendErr.Error())
+			default:
+				err = d.Outbox.RetryDelivery(ctx, delivery.ID, sendErr.Error(), time.Now().Add(Backoff(delivery.Attempts)))
+			}
+			if err != nil {
+				return delivered, err
+			}
+		}
+	}
+
+	return delivered, ctx.Err()
+}
+
+func (d *Deliverer) send(ctx context.Context, target *Target, delivery store.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, strings.NewReader(delivery.Body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", delivery.ContentType)
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("unexpected response status: %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func (d *Deliverer) timeout() time.Duration {
+	return 10 * time.Second
+}
+
+func (d *Deliverer) maxAttempts() int {
+	if d.MaxAttempts > 0 {
+		return d.MaxAttempts
+	}
+	return 8
+}
+
+// Backoff returns the delay before retrying a delivery after the given number of attempts. It doubles from 30
+// seconds up to 6 hours, with up to 10% added at random so that retries to the same target are spread out.
+func Backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
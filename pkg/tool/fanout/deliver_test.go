@@ -0,0 +1,73 @@
+package fanout
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestDeliver(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	var received []string
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Content-Type")+" "+string(body))
+	}))
+	defer ok.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	deliverer := &Deliverer{
+		Outbox: s,
+		Targets: []*Target{
+			{Name: "ok", URL: ok.URL},
+			{Name: "failing", URL: failing.URL},
+		},
+		MaxAttempts: 2,
+	}
+
+	err := s.EnqueueDeliveries(ctx, []store.NewDelivery{
+		{Target: "ok", Feed: "alerts", ItemID: 1, ContentType: "application/json", Body: `{"title":"a"}`},
+		{Target: "failing", Feed: "alerts", ItemID: 1, ContentType: "application/json", Body: `{"title":"a"}`},
+		{Target: "removed", Feed: "alerts", ItemID: 1, ContentType: "application/json", Body: `{"title":"a"}`},
+	})
+	require.NoError(t, err)
+
+	delivered, err := deliverer.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{`application/json {"title":"a"}`}, received)
+
+	pending, err := s.ListDeliveries(ctx, store.DeliveryPending, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "failed deliveries should be retried")
+	assert.Contains(t, pending[0].LastError, "503")
+	assert.True(t, pending[0].NextAttemptAt.After(time.Now().Add(25*time.Second)), "retries should back off")
+
+	// make the retry due now
+	require.NoError(t, s.RetryDelivery(ctx, pending[0].ID, pending[0].LastError, time.Now()))
+
+	delivered, err = deliverer.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	dead, err := s.ListDeliveries(ctx, store.DeliveryDead, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 2, "deliveries should be dead lettered after the last attempt or when the target is removed")
+	assert.Equal(t, "removed", dead[0].Target)
+	assert.Equal(t, "failing", dead[1].Target)
+	assert.Equal(t, 2, dead[1].Attempts)
+}
@@ -0,0 +1,183 @@
+// Package fanout forwards new items to other services. Items are rendered for each matching target and added to
+// a persisted outbox, which is delivered by a job so that slow targets don't delay item creation.
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Formats supported by targets
+const (
+	FormatJSON     = "json"
+	FormatSlack    = "slack"
+	FormatDiscord  = "discord"
+	FormatTemplate = "template"
+)
+
+// Target is a service which items are forwarded to
+type Target struct {
+	Name string
+	URL  string
+
+	// Feeds limits the target to items from these feeds, all feeds are forwarded when empty
+	Feeds []string
+	// Tags limits the target to items with any of these tags
+	Tags []string
+	// TitleRegex limits the target to items with matching titles
+	TitleRegex *regexp.Regexp
+
+	// Format is one of json, slack, discord or template
+	Format string
+	// Template renders the body when the format is template
+	Template *template.Template
+	// ContentType is sent with templated bodies, defaults to application/json
+	ContentType string
+}
+
+// Payload is the data rendered for each item, it's the request body for the json format and the data passed to
+// templates
+type Payload struct {
+	Feed      string    `json:"feed"`
+	ID        int64     `json:"id"`
+	GUID      string    `json:"guid"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TemplateFuncs are available in target templates, json is useful for embedding strings in JSON bodies
+var TemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Matches returns true when an item in the feed should be forwarded to the target
+func (t *Target) Matches(feed string, item store.Item) bool {
+	if len(t.Feeds) > 0 {
+		found := false
+		for _, f := range t.Feeds {
+			if f == feed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(t.Tags) > 0 && !item.Tags.Has(t.Tags...) {
+		return false
+	}
+
+	if t.TitleRegex != nil && !t.TitleRegex.MatchString(item.Title) {
+		return false
+	}
+
+	return true
+}
+
+// Render returns the request body and content type to send to the target for an item
+func (t *Target) Render(feed string, item store.Item) (string, string, error) {
+	payload := Payload{
+		Feed:      feed,
+		ID:        item.ID,
+		GUID:      item.GUID,
+		Title:     item.Title,
+		Body:      item.Body,
+		URL:       item.URL,
+		Tags:      item.Tags,
+		CreatedAt: item.CreatedAt,
+	}
+
+	text := item.Title
+	if item.Body != "" {
+		text += "\n" + item.Body
+	}
+	if item.URL != "" {
+		text += "\n" + item.URL
+	}
+
+	var body any
+	switch t.Format {
+	case FormatJSON, "":
+		body = payload
+	case FormatSlack:
+		body = map[string]string{"text": text}
+	case FormatDiscord:
+		// discord rejects messages longer than 2000 characters
+		runes := []rune(text)
+		if len(runes) > 2000 {
+			text = string(runes[:1999]) + "…"
+		}
+		body = map[string]string{"content": text}
+	case FormatTemplate:
+		var buf bytes.Buffer
+		err := t.Template.Execute(&buf, payload)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to render template: %w", err)
+		}
+
+		contentType := t.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		return buf.String(), contentType, nil
+	default:
+		return "", "", fmt.Errorf("unknown format %q", t.Format)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal body: %w", err)
+	}
+
+	return string(b), "application/json", nil
+}
+
+// Fanout adds deliveries to the outbox for new items
+type Fanout struct {
+	Targets []*Target
+	Outbox  store.OutboxStore
+}
+
+// Enqueue adds a delivery to the outbox for each target matching each item
+func (f *Fanout) Enqueue(ctx context.Context, feed string, items []store.Item) error {
+	var deliveries []store.NewDelivery
+
+	for _, item := range items {
+		for _, target := range f.Targets {
+			if !target.Matches(feed, item) {
+				continue
+			}
+
+			body, contentType, err := target.Render(feed, item)
+			if err != nil {
+				return fmt.Errorf("failed to render item %d for %s: %w", item.ID, target.Name, err)
+			}
+
+			deliveries = append(deliveries, store.NewDelivery{
+				Target:      target.Name,
+				Feed:        feed,
+				ItemID:      item.ID,
+				ContentType: contentType,
+				Body:        body,
+			})
+		}
+	}
+
+	return f.Outbox.EnqueueDeliveries(ctx, deliveries)
+}
@@ -0,0 +1,102 @@
+package fanout
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestTargetMatches(t *testing.T) {
+	target := &Target{
+		Feeds:      []string{"alerts"},
+		Tags:       []string{"critical"},
+		TitleRegex: regexp.MustCompile(`^FAILED`),
+	}
+
+	assert.True(t, target.Matches("alerts", store.Item{Title: "FAILED backup", Tags: store.Tags{"critical"}}))
+	assert.False(t, target.Matches("other", store.Item{Title: "FAILED backup", Tags: store.Tags{"critical"}}))
+	assert.False(t, target.Matches("alerts", store.Item{Title: "FAILED backup", Tags: store.Tags{"info"}}))
+	assert.False(t, target.Matches("alerts", store.Item{Title: "backup ok", Tags: store.Tags{"critical"}}))
+
+	assert.True(t, (&Target{}).Matches("any", store.Item{Title: "anything"}), "targets without filters match all items")
+}
+
+func TestTargetRender(t *testing.T) {
+	item := store.Item{ID: 1, GUID: "abc", Title: "FAILED backup", Body: "disk full", URL: "https://example.com"}
+
+	for _, tc := range []struct {
+		target      *Target
+		body        string
+		contentType string
+	}{
+		{
+			target:      &Target{Format: FormatSlack},
+			body:        `{"text":"FAILED backup\ndisk full\nhttps://example.com"}`,
+			contentType: "application/json",
+		},
+		{
+			target:      &Target{Format: FormatDiscord},
+			body:        `{"content":"FAILED backup\ndisk full\nhttps://example.com"}`,
+			contentType: "application/json",
+		},
+		{
+			target: &Target{
+				Format:      FormatTemplate,
+				Template:    template.Must(template.New("").Funcs(TemplateFuncs).Parse(`{{ .Feed }}: {{ .Title | json }}`)),
+				ContentType: "text/plain",
+			},
+			body:        `alerts: "FAILED backup"`,
+			contentType: "text/plain",
+		},
+	} {
+		body, contentType, err := tc.target.Render("alerts", item)
+		require.NoError(t, err)
+		assert.Equal(t, tc.body, body)
+		assert.Equal(t, tc.contentType, contentType)
+	}
+
+	body, _, err := (&Target{Format: FormatJSON}).Render("alerts", item)
+	require.NoError(t, err)
+	assert.Contains(t, body, `"feed":"alerts"`)
+	assert.Contains(t, body, `"guid":"abc"`)
+}
+
+func TestFanoutEnqueue(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	f := &Fanout{
+		Outbox: s,
+		Targets: []*Target{
+			{Name: "all", URL: "https://example.com/all"},
+			{Name: "critical", URL: "https://example.com/critical", Tags: []string{"critical"}},
+		},
+	}
+
+	err := f.Enqueue(ctx, "alerts", []store.Item{
+		{ID: 1, Title: "info"},
+		{ID: 2, Title: "critical", Tags: store.Tags{"critical"}},
+	})
+	require.NoError(t, err)
+
+	deliveries, err := s.ClaimDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, "critical", deliveries[2].Target)
+	assert.Equal(t, int64(2), deliveries[2].ItemID)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.GreaterOrEqual(t, Backoff(1), 30*time.Second)
+	assert.Less(t, Backoff(1), 34*time.Second)
+	assert.GreaterOrEqual(t, Backoff(3), 2*time.Minute)
+	assert.GreaterOrEqual(t, Backoff(50), 6*time.Hour)
+	assert.Less(t, Backoff(50), 7*time.Hour)
+}
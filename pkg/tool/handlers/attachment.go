@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// BuildAttachmentGetHandler returns a handler serving a file attached to an item, attachments of private feeds
+// need a read secret like the feed itself
+func BuildAttachmentGetHandler(
+	attachments store.AttachmentStore,
+	secrets store.SecretStore,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		feed := vars["feed"]
+		if !feedRegex.MatchString(feed) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		itemID, err := strconv.ParseInt(vars["item"], 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.ParseInt(vars["attachment"], 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		readable, err := feedReadable(r.Context(), secrets, feed, r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !readable {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		attachment, found, err := attachments.GetAttachment(r.Context(), feed, itemID, id)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		// attachments are served as downloads so that html attachments can't run scripts on this origin
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", disposition)
+		w.Header().Set("Content-Length", fmt.Sprint(len(attachment.Data)))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(attachment.Data)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestAttachmentGet(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	created, err := s.InsertItems(ctx, "example", []store.NewItem{{GUID: "a", Title: "with attachment"}}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	_, err = s.AddAttachments(ctx, "example", created[0].ID, []store.NewAttachment{
+		{Filename: "notes.txt", ContentType: "text/plain", Data: []byte("hello")},
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}.rss", BuildFeedGetHandler(s, s, FeedGetOptions{})).Methods("GET")
+	router.HandleFunc(
+		"/feeds/{feed}/items/{item}/attachments/{attachment}",
+		BuildAttachmentGetHandler(s, s),
+	).Methods("GET")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/feeds/example.rss")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<link href="/feeds/example/items/1/attachments/1" rel="enclosure" type="text/plain" length="5"></link>`)
+
+	rec = get("/feeds/example/items/1/attachments/1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello", rec.Body.String())
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=notes.txt`, rec.Header().Get("Content-Disposition"))
+
+	assert.Equal(t, http.StatusNotFound, get("/feeds/other/items/1/attachments/1").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/feeds/example/items/x/attachments/1").Code)
+
+	// attachments of private feeds need a secret, which is included in the enclosure links
+	_, err = s.CreateSecret(ctx, "example", "reader", hashSecret("s3cret"))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, get("/feeds/example/items/1/attachments/1").Code)
+	assert.Equal(t, http.StatusOK, get("/feeds/example/items/1/attachments/1?key=s3cret").Code)
+
+	rec = get("/feeds/example.rss?key=s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `href="/feeds/example/items/1/attachments/1?key=s3cret"`)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// WithBearerToken wraps a handler so that it's only called for requests with the given bearer token
+func WithBearerToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || !bearerTokenMatches(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="webhook-rss"`)
+			WriteError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func bearerTokenMatches(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	provided := strings.TrimPrefix(header, "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+type userContextKey struct{}
+
+// WithUser wraps a handler so that it's only called for requests with the bearer token of a configured user. users
+// maps each token to the name of the user it identifies, the handler gets the user with RequestUser.
+func WithUser(users map[string]string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		user, ok := lookupUser(users, strings.TrimPrefix(header, "Bearer "))
+		if !strings.HasPrefix(header, "Bearer ") || !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="webhook-rss"`)
+			WriteError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)))
+	}
+}
+
+// RequestUser returns the user set by WithUser, or a blank string for requests without one
+func RequestUser(r *http.Request) string {
+	user, _ := r.Context().Value(userContextKey{}).(string)
+
+	return user
+}
+
+// lookupUser returns the user identified by a token, every token is compared so that the time taken doesn't depend
+// on which one matched
+func lookupUser(users map[string]string, token string) (string, bool) {
+	var user string
+	found := false
+	for t, u := range users {
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			user = u
+			found = true
+		}
+	}
+
+	return user, found
+}
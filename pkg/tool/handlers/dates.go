@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zonedDateLayouts are layouts which carry their own offset or zone name
+var zonedDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -0700 MST",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	time.UnixDate,
+	time.RubyDate,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+// localDateLayouts are layouts without an offset, these are interpreted in the feed's default location
+var localDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"20060102",
+	time.ANSIC,
+}
+
+// epochRegex matches epoch seconds (9 or 10 digits, optionally fractional) and milliseconds (12 or 13 digits),
+// which covers 1973 to 2286. Other runs of digits, such as YYYYMMDD dates, fall through to the layouts.
+var epochRegex = regexp.MustCompile(`^-?(\d{9,10}(\.\d+)?|\d{12,13})$`)
+
+// epochMillisecondsThreshold is the point above which integer epoch values are assumed to be in milliseconds
+// rather than seconds. 1e11 seconds is in the year 5138, while 1e11 milliseconds is in 1973.
+const epochMillisecondsThreshold = 1e11
+
+// parseDate parses an item date in any of the supported formats. Values without an offset are interpreted in loc,
+// which defaults to UTC when nil.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("date is blank")
+	}
+
+	if epochRegex.MatchString(value) {
+		return parseEpoch(value)
+	}
+
+	for _, layout := range zonedDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	for _, layout := range localDateLayouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported date format %q", value)
+}
+
+func parseEpoch(value string) (time.Time, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid epoch value %q: %w", value, err)
+	}
+
+	if f >= epochMillisecondsThreshold || f <= -epochMillisecondsThreshold {
+		return time.UnixMilli(int64(f)).UTC(), nil
+	}
+
+	sec := int64(f)
+	nsec := int64((f - float64(sec)) * float64(time.Second))
+
+	return time.Unix(sec, nsec).UTC(), nil
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		value    string
+		loc      *time.Location
+		expected time.Time
+	}{
+		"date only": {
+			value:    "2022-10-12",
+			expected: time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC),
+		},
+		"date only in feed timezone": {
+			value:    "2022-10-12",
+			loc:      london,
+			expected: time.Date(2022, 10, 11, 23, 0, 0, 0, time.UTC),
+		},
+		"original offset layout": {
+			value:    "2022-10-12T15:04:05+02:00",
+			expected: time.Date(2022, 10, 12, 13, 4, 5, 0, time.UTC),
+		},
+		"rfc3339 zulu": {
+			value:    "2022-10-12T15:04:05Z",
+			expected: time.Date(2022, 10, 12, 15, 4, 5, 0, time.UTC),
+		},
+		"rfc3339 fractional seconds": {
+			value:    "2022-10-12T15:04:05.123Z",
+			expected: time.Date(2022, 10, 12, 15, 4, 5, 123000000, time.UTC),
+		},
+		"rfc1123": {
+			value:    "Wed, 12 Oct 2022 15:04:05 GMT",
+			expected: time.Date(2022, 10, 12, 15, 4, 5, 0, time.UTC),
+		},
+		"rfc1123z": {
+			value:    "Wed, 12 Oct 2022 15:04:05 +0100",
+			expected: time.Date(2022, 10, 12, 14, 4, 5, 0, time.UTC),
+		},
+		"no offset uses feed timezone": {
+			value:    "2022-10-12 15:04:05",
+			loc:      london,
+			expected: time.Date(2022, 10, 12, 14, 4, 5, 0, time.UTC),
+		},
+		"epoch seconds": {
+			value:    "1665587045",
+			expected: time.Date(2022, 10, 12, 15, 4, 5, 0, time.UTC),
+		},
+		"epoch milliseconds": {
+			value:    "1665587045123",
+			expected: time.Date(2022, 10, 12, 15, 4, 5, 123000000, time.UTC),
+		},
+		"compact date": {
+			value:    "20221012",
+			expected: time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC),
+		},
+		"compact date in feed timezone": {
+			value:    "20221012",
+			loc:      london,
+			expected: time.Date(2022, 10, 11, 23, 0, 0, 0, time.UTC),
+		},
+		"epoch fractional seconds": {
+			value:    "1665587045.5",
+			expected: time.Date(2022, 10, 12, 15, 4, 5, 500000000, time.UTC),
+		},
+		"surrounding whitespace": {
+			value:    "  2022-10-12  ",
+			expected: time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := parseDate(tc.value, tc.loc)
+			require.NoError(t, err)
+
+			assert.True(t, tc.expected.Equal(result), "expected %s, got %s", tc.expected, result)
+		})
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, value := range []string{"", "yesterday", "12/10/2022", "2022-13-45", "20221345", "12345"} {
+		_, err := parseDate(value, nil)
+		assert.Error(t, err, "expected error for %q", value)
+	}
+}
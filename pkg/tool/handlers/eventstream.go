@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// eventStream is an open server-sent events response. Events written to it are sent to the client when it's flushed.
+type eventStream struct {
+	io.Writer
+
+	// ctx is done when the client disconnects
+	ctx   context.Context
+	flush func() error
+	close func()
+}
+
+// openEventStream writes the headers for a server-sent events response and returns the stream to write events to.
+// Streams are long-lived, so on HTTP/1 connections the connection is hijacked to clear the server's read and write
+// deadlines, which would otherwise end the stream after the server's timeout.
+func openEventStream(w http.ResponseWriter, r *http.Request) (*eventStream, error) {
+	// the headers are also set on the wrapping writers, so middleware can tell the response is a stream
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	hijacker, ok := findResponseWriter(w, func(w http.ResponseWriter) bool {
+		_, ok := w.(http.Hijacker)
+		return ok
+	}).(http.Hijacker)
+	if ok && r.ProtoMajor == 1 {
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			return nil, fmt.Errorf("failed to hijack connection: %w", err)
+		}
+
+		return hijackedEventStream(r.Context(), conn, rw, w.Header().Clone())
+	}
+
+	flusher, ok := findResponseWriter(w, func(w http.ResponseWriter) bool {
+		_, ok := w.(http.Flusher)
+		return ok
+	}).(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return &eventStream{
+		Writer: w,
+		ctx:    r.Context(),
+		flush: func() error {
+			flusher.Flush()
+			return nil
+		},
+		close: func() {},
+	}, nil
+}
+
+// hijackedEventStream writes the response headers to a hijacked connection. The body is ended by closing the
+// connection, so no content length or chunked encoding is needed.
+func hijackedEventStream(
+	ctx context.Context,
+	conn net.Conn,
+	rw *bufio.ReadWriter,
+	header http.Header,
+) (*eventStream, error) {
+	err := conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to clear connection deadline: %w", err)
+	}
+
+	header.Set("Connection", "close")
+	header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	fmt.Fprint(rw, "HTTP/1.1 200 OK\r\n")
+	err = header.Write(rw)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to write headers: %w", err)
+	}
+	fmt.Fprint(rw, "\r\n")
+
+	// the server no longer cancels the request context when the client disconnects, so the connection is read
+	// until it's closed instead
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		_, _ = io.Copy(io.Discard, rw)
+	}()
+
+	return &eventStream{
+		Writer: rw,
+		ctx:    ctx,
+		flush:  rw.Flush,
+		close: func() {
+			cancel()
+			conn.Close()
+		},
+	}, nil
+}
+
+// findResponseWriter returns the first of w and the writers it wraps for which match returns true, or nil. Writers
+// are unwrapped using an Unwrap method, or an embedded ResponseWriter field as used by the toolbelt's logging
+// middleware, which doesn't pass through flushes or hijacking.
+func findResponseWriter(w http.ResponseWriter, match func(w http.ResponseWriter) bool) http.ResponseWriter {
+	for w != nil {
+		if match(w) {
+			return w
+		}
+
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+			w = u.Unwrap()
+			continue
+		}
+
+		v := reflect.ValueOf(w)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		field := v.Elem().FieldByName("ResponseWriter")
+		if !field.IsValid() || !field.CanInterface() {
+			return nil
+		}
+		w, _ = field.Interface().(http.ResponseWriter)
+	}
+
+	return nil
+}
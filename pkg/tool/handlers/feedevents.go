@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/events"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// FeedEventsOptions configures the feed events handler
+type FeedEventsOptions struct {
+	// KeepAlive is the interval between comments sent to keep idle connections open, defaults to 15 seconds
+	KeepAlive time.Duration
+}
+
+// BuildFeedEventsHandler returns a handler which streams new items in a feed as server-sent events. Each event's id
+// is the item id, clients resume from an item by sending it in the Last-Event-ID header or last_event_id query
+// param. Streams without either start from the newest item in the feed. Scheduled items are sent once they're
+// published, those published after newer items have been sent have no id, so clients which reconnect before then
+// won't receive them.
+func BuildFeedEventsHandler(
+	items store.ItemStore,
+	secrets store.SecretStore,
+	broadcaster *events.Broadcaster,
+	opts FeedEventsOptions,
+) func(http.ResponseWriter, *http.Request) {
+	keepAlive := opts.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = 15 * time.Second
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed, ok := mux.Vars(r)["feed"]
+		if !ok || feed == "" {
+			WriteError(w, http.StatusBadRequest, "feed var missing")
+			return
+		}
+
+		if !feedRegex.MatchString(feed) {
+			WriteError(w, http.StatusBadRequest, "feed didn't match regex")
+			return
+		}
+
+		readable, err := feedReadable(r.Context(), secrets, feed, r)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to check feed secrets")
+			return
+		}
+		if !readable {
+			WriteError(w, http.StatusNotFound, "feed %s not found", feed)
+			return
+		}
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+
+		// subscribe before loading the starting point, so that no items are missed in between
+		notifications, unsubscribe := broadcaster.Subscribe(feed)
+		defer unsubscribe()
+
+		var lastID int64
+		if lastEventID != "" {
+			lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "last event id must be an item id")
+				return
+			}
+		} else {
+			lastID, err = items.LatestItemID(r.Context(), feed)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to load latest item")
+				return
+			}
+		}
+
+		stream, err := openEventStream(w, r)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to open stream: %s", err)
+			return
+		}
+		defer stream.close()
+
+		// clients should reconnect promptly and resume if the stream is closed
+		fmt.Fprint(stream, "retry: 1000\n\n")
+		err = stream.flush()
+		if err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+
+		// pending holds the scheduled items which newer items have been sent before, by id
+		pending := make(map[int64]store.Item)
+
+		for {
+			lastID, err = writeItemEvents(stream.ctx, stream, items, feed, lastID, pending)
+			if err != nil {
+				return
+			}
+			err = stream.flush()
+			if err != nil {
+				return
+			}
+
+			select {
+			case <-stream.ctx.Done():
+				return
+			case <-notifications:
+			case <-ticker.C:
+				_, err = fmt.Fprint(stream, ": keep-alive\n\n")
+				if err != nil {
+					return
+				}
+				err = stream.flush()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeItemEvents writes an event for each item after lastID, and returns the id of the last item written.
+// Scheduled items which are passed over are added to pending, and written once they're published.
+func writeItemEvents(
+	ctx context.Context,
+	w io.Writer,
+	items store.ItemStore,
+	feed string,
+	lastID int64,
+	pending map[int64]store.Item,
+) (int64, error) {
+	// scheduled items are loaded first, so that any published in between are listed below instead
+	scheduled, err := items.ListScheduledItems(ctx, feed)
+	if err != nil {
+		return lastID, fmt.Errorf("failed to list scheduled items: %w", err)
+	}
+
+	written := make(map[int64]bool)
+	for {
+		newItems, err := items.ListItemsAfter(ctx, feed, lastID, 100)
+		if err != nil {
+			return lastID, fmt.Errorf("failed to list items: %w", err)
+		}
+
+		for _, item := range newItems {
+			err = writeItemEvent(w, item, true)
+			if err != nil {
+				return lastID, err
+			}
+
+			written[item.ID] = true
+			lastID = item.ID
+		}
+
+		if len(newItems) < 100 {
+			break
+		}
+	}
+
+	for _, item := range scheduled {
+		if item.ID <= lastID && !written[item.ID] {
+			pending[item.ID] = item
+		}
+	}
+
+	return lastID, writePendingEvents(ctx, w, items, feed, pending)
+}
+
+// writePendingEvents writes an event for each pending item which has been published, and removes it from pending.
+// Pending items which have since been cancelled or have expired are dropped.
+func writePendingEvents(
+	ctx context.Context,
+	w io.Writer,
+	items store.ItemStore,
+	feed string,
+	pending map[int64]store.Item,
+) error {
+	now := time.Now()
+
+	var published []store.Item
+	var guids []string
+	for _, item := range pending {
+		if !item.CreatedAt.After(now) {
+			published = append(published, item)
+			guids = append(guids, item.GUID)
+		}
+	}
+	if len(published) == 0 {
+		return nil
+	}
+
+	existing, err := items.ExistingGUIDs(ctx, feed, guids)
+	if err != nil {
+		return fmt.Errorf("failed to check scheduled items: %w", err)
+	}
+	exists := make(map[string]bool)
+	for _, guid := range existing {
+		exists[guid] = true
+	}
+
+	sort.Slice(published, func(i, j int) bool { return published[i].ID < published[j].ID })
+
+	for _, item := range published {
+		delete(pending, item.ID)
+
+		expired := item.ExpiresAt != nil && !item.ExpiresAt.After(now)
+		if !exists[item.GUID] || expired {
+			continue
+		}
+
+		// the id is left out, as the stream has already moved past this item
+		err = writeItemEvent(w, item, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeItemEvent writes an event for an item, withID sets the event id to the item id
+func writeItemEvent(w io.Writer, item store.Item, withID bool) error {
+	data, err := json.Marshal(itemResponse(item))
+	if err != nil {
+		return fmt.Errorf("failed to marshal item: %w", err)
+	}
+
+	if withID {
+		_, err = fmt.Fprintf(w, "id: %d\nevent: item\ndata: %s\n\n", item.ID, data)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: item\ndata: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	utilsHTTP "github.com/charlieegan3/toolbelt/pkg/utils/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/events"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestFeedEvents(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	broadcaster := events.NewBroadcaster()
+
+	existing, err := s.InsertItems(ctx, "example", []store.NewItem{
+		{GUID: "a", Title: "existing 1"},
+		{GUID: "b", Title: "existing 2"},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(
+		"/feeds/{feed}/events",
+		BuildFeedEventsHandler(s, s, broadcaster, FeedEventsOptions{}),
+	).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/items", BuildItemCreateHandler(s, ItemCreateOptions{
+		OnCreated: []func(ctx context.Context, feed string, items []store.Item){
+			func(ctx context.Context, feed string, items []store.Item) { broadcaster.Notify(feed) },
+		},
+	})).Methods("POST")
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// readEvents returns the data of the next n item events in the stream
+	readEvents := func(t *testing.T, scanner *bufio.Scanner, n int) []string {
+		var data []string
+		for len(data) < n && scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "data: ") {
+				data = append(data, strings.TrimPrefix(line, "data: "))
+			}
+		}
+		require.Len(t, data, n)
+		return data
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/feeds/example/events", nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", fmt.Sprint(existing[0].ID))
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		data := readEvents(t, bufio.NewScanner(resp.Body), 1)
+		assert.Contains(t, data[0], `"title":"existing 2"`)
+	})
+
+	t.Run("new items", func(t *testing.T) {
+		reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/feeds/example/events", nil)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		scanner := bufio.NewScanner(resp.Body)
+		// wait for the retry field, which is sent once the stream has subscribed
+		for scanner.Scan() && !strings.HasPrefix(scanner.Text(), "retry:") {
+		}
+
+		createResp, err := http.Post(server.URL+"/feeds/example/items", "application/json", strings.NewReader(`{"title":"new"}`))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, createResp.StatusCode)
+
+		data := readEvents(t, scanner, 1)
+		assert.Contains(t, data[0], `"title":"new"`, "existing items should not be replayed")
+	})
+
+	t.Run("scheduled items", func(t *testing.T) {
+		reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/feeds/scheduled/events", nil)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() && !strings.HasPrefix(scanner.Text(), "retry:") {
+		}
+
+		publishAt := time.Now().Add(time.Second)
+		_, err = s.InsertItems(ctx, "scheduled", []store.NewItem{
+			{GUID: "a", Title: "scheduled", CreatedAt: publishAt},
+		}, store.InsertOptions{})
+		require.NoError(t, err)
+
+		createResp, err := http.Post(server.URL+"/feeds/scheduled/items", "application/json", strings.NewReader(`{"title":"new"}`))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, createResp.StatusCode)
+
+		data := readEvents(t, scanner, 1)
+		assert.Contains(t, data[0], `"title":"new"`, "scheduled items should not be sent early")
+
+		// the publish job wakes streams once the item is published
+		time.Sleep(time.Until(publishAt))
+		broadcaster.Notify("scheduled")
+
+		var lines []string
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				if len(lines) > 0 {
+					break
+				}
+				continue
+			}
+			lines = append(lines, scanner.Text())
+		}
+		require.Len(t, lines, 2)
+		assert.Equal(t, "event: item", lines[0], "the item should be sent without an id")
+		assert.Contains(t, lines[1], `"title":"scheduled"`)
+	})
+
+	t.Run("private", func(t *testing.T) {
+		_, err := s.CreateSecret(ctx, "private", "reader", hashSecret("s3cret"))
+		require.NoError(t, err)
+
+		resp, err := http.Get(server.URL + "/feeds/private/events")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestFeedEventsWriteTimeout(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	broadcaster := events.NewBroadcaster()
+
+	router := mux.NewRouter()
+	router.HandleFunc(
+		"/feeds/{feed}/events",
+		BuildFeedEventsHandler(s, s, broadcaster, FeedEventsOptions{}),
+	).Methods("GET")
+
+	// the toolbelt's logging middleware wraps responses without passing through flushes or hijacking
+	router.Use(utilsHTTP.InitMiddlewareLogging())
+
+	// streams must outlive the server's timeouts, which are set for ordinary requests
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/feeds/example/events", nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && !strings.HasPrefix(scanner.Text(), "retry:") {
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	_, err = s.InsertItems(ctx, "example", []store.NewItem{{GUID: "a", Title: "after the timeout"}}, store.InsertOptions{})
+	require.NoError(t, err)
+	broadcaster.Notify("example")
+
+	var data string
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			data = scanner.Text()
+			break
+		}
+	}
+	require.NoError(t, scanner.Err())
+	assert.Contains(t, data, `"title":"after the timeout"`)
+}
@@ -1,19 +1,30 @@
 package handlers
 
 import (
-	"database/sql"
 	"fmt"
-	"github.com/doug-martin/goqu/v9"
-	"github.com/gorilla/feeds"
-	"github.com/gorilla/mux"
 	"net/http"
 	"strings"
-	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
 )
 
-func BuildFeedGetHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
-	goquDB := goqu.New("postgres", db)
+// FeedGetOptions configures the feed get handler
+type FeedGetOptions struct {
+	// BaseURL is the external URL of the tool, e.g. https://example.com/webhook-rss
+	BaseURL string
+	// HubURL is the WebSub hub advertised in feeds, feeds don't advertise a hub when blank
+	HubURL string
+	// Users maps user tokens to user names, a user's token can be given to only list the items they haven't read
+	Users map[string]string
+}
 
+func BuildFeedGetHandler(
+	items FeedStore,
+	secrets store.SecretStore,
+	opts FeedGetOptions,
+) func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		vars := mux.Vars(request)
 
@@ -28,52 +39,73 @@ func BuildFeedGetHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
 			return
 		}
 
-		responseFeed := &feeds.Feed{
-			Title:       feed,
-			Link:        &feeds.Link{Href: request.URL.String()},
-			Description: fmt.Sprintf("webhook-rss feed %q", feed),
-			Created:     time.Now(),
-		}
-
-		var items []struct {
-			ID        int64     `db:"id"`
-			Title     string    `db:"title"`
-			Body      string    `db:"body"`
-			URL       string    `db:"url"`
-			CreatedAt time.Time `db:"created_at"`
-		}
-
-		err := goquDB.From("webhookrss.items").
-			Where(goqu.C("feed").Eq(feed), goqu.C("created_at").Lt("NOW()")).
-			Order(goqu.I("created_at").Desc()).
-			Limit(50).
-			ScanStructs(&items)
+		// unauthorized readers of private feeds get the same response as for a missing feed
+		readable, err := feedReadable(request.Context(), secrets, feed, request)
 		if err != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		if !readable {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
 
-		if len(items) > 0 {
-			responseFeed.Created = items[0].CreatedAt
+		// feed readers can't usually set headers, so the user's token is given in the query
+		var unreadBy string
+		if request.URL.Query().Get("unread") == "true" {
+			user, ok := lookupUser(opts.Users, request.URL.Query().Get("user_token"))
+			if !ok {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			unreadBy = user
 		}
 
-		for _, item := range items {
-			responseFeed.Items = append(responseFeed.Items,
-				&feeds.Item{
-					Id:          fmt.Sprintf("%s/items/%d", strings.TrimSuffix(request.URL.String(), ".rss"), item.ID),
-					Title:       item.Title,
-					Link:        &feeds.Link{Href: item.URL},
-					Description: item.Body,
-					Created:     item.CreatedAt,
-				})
+		feedURL := publicFeedURL(request)
+
+		links := FeedLinks{Key: requestKey(request)}
+		if opts.HubURL != "" && opts.BaseURL != "" {
+			links.Hub = opts.HubURL
+			links.Self = fmt.Sprintf("%s/feeds/%s.rss", opts.BaseURL, feed)
 		}
 
-		atom, err := responseFeed.ToAtom()
+		atom, err := renderFeed(request.Context(), items, feed, feedURL, links, unreadBy)
 		if err != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
+		if links.Hub != "" {
+			writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, links.Hub))
+			writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="self"`, links.Self))
+		}
+
 		writer.Write([]byte(atom))
 	}
 }
+
+// requestKey returns the read secret given in the request path or query, if any
+func requestKey(request *http.Request) string {
+	if key := mux.Vars(request)["key"]; key != "" {
+		return key
+	}
+
+	return request.URL.Query().Get("key")
+}
+
+// publicFeedURL returns the canonical URL of the requested feed, without the query or any read secret in the path.
+// It's used for feed and item ids, so secrets and user tokens aren't included, and items keep the same ids when a
+// feed is filtered.
+func publicFeedURL(request *http.Request) string {
+	u := *request.URL
+
+	if key := mux.Vars(request)["key"]; key != "" {
+		u.Path = strings.TrimSuffix(u.Path, fmt.Sprintf("/key/%s.rss", key)) + ".rss"
+		u.RawPath = ""
+	}
+
+	u.RawQuery = ""
+	u.ForceQuery = false
+
+	return u.String()
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestFeedGetPrivate(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	_, err := s.InsertItems(ctx, "example", []store.NewItem{{GUID: "a", Title: "private item"}}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	_, err = s.CreateSecret(ctx, "example", "reader", hashSecret("s3cret"))
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}.rss", BuildFeedGetHandler(s, s, FeedGetOptions{})).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/key/{key}.rss", BuildFeedGetHandler(s, s, FeedGetOptions{})).Methods("GET")
+
+	get := func(target string, setAuth func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if setAuth != nil {
+			setAuth(req)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/feeds/example.rss", nil).Code)
+	assert.Equal(t, http.StatusNotFound, get("/feeds/example.rss?key=wrong", nil).Code)
+
+	rec := get("/feeds/example.rss?key=s3cret", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "private item")
+	assert.NotContains(t, string(body), "s3cret")
+
+	rec = get("/feeds/example/key/s3cret.rss", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err = io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "<id>/feeds/example.rss</id>")
+
+	rec = get("/feeds/example.rss", func(r *http.Request) { r.SetBasicAuth("reader", "s3cret") })
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// feeds without secrets are public
+	assert.Equal(t, http.StatusOK, get("/feeds/other.rss", nil).Code)
+
+	// feeds stay private once their secrets are revoked
+	secrets, err := s.ListSecrets(ctx, "example")
+	require.NoError(t, err)
+	_, err = s.RevokeSecret(ctx, "example", secrets[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, get("/feeds/example.rss", nil).Code)
+	assert.Equal(t, http.StatusNotFound, get("/feeds/example.rss?key=s3cret", nil).Code)
+}
+
+func TestFeedIDsMatchWebSubPushes(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	_, err := s.InsertItems(ctx, "example", []store.NewItem{
+		{GUID: "a", Title: "first"},
+		{GUID: "b", Title: "second"},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	ids := func(body string) []string {
+		var feed struct {
+			ID      string `xml:"id"`
+			Entries []struct {
+				ID string `xml:"id"`
+			} `xml:"entry"`
+		}
+		require.NoError(t, xml.Unmarshal([]byte(body), &feed))
+
+		ids := []string{feed.ID}
+		for _, e := range feed.Entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	// pushes are rendered with the absolute self url, as the hub does
+	pushed, err := RenderFeed(ctx, s, "example", "https://example.com/feeds/example.rss", FeedLinks{
+		Hub:  "https://example.com/hub",
+		Self: "https://example.com/feeds/example.rss",
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}.rss", BuildFeedGetHandler(s, s, FeedGetOptions{
+		BaseURL: "https://example.com",
+		HubURL:  "https://example.com/hub",
+	})).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/feeds/example.rss", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	polled := ids(rec.Body.String())
+	require.Len(t, polled, 3)
+	assert.Equal(t, polled, ids(pushed), "pushed and polled feeds should have the same ids")
+	assert.Equal(t, "/feeds/example.rss", polled[0])
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// FeedViewOptions configures the HTML feed view
+type FeedViewOptions struct {
+	// BaseURL is the external URL of the tool, when blank it's worked out from the request
+	BaseURL string
+	// Feeds describes the configured feeds, their titles and descriptions are shown on the page
+	Feeds map[string]FeedInfo
+	// PageSize is the number of items on each page, defaults to 20
+	PageSize int
+	// Users maps user tokens to user names. When a user's token is given, the page shows their item states and
+	// can be limited to unread items.
+	Users map[string]string
+}
+
+// htmlBodyRegex matches item bodies which contain HTML tags
+var htmlBodyRegex = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+
+type viewItem struct {
+	store.Item
+	// HTML is set when the body should be rendered as HTML rather than text. HTML bodies are rendered in sandboxed
+	// frames, so that scripts in them aren't run and they can't restyle the page.
+	HTML        bool
+	Attachments []viewAttachment
+	// State is the viewing user's state for the item, it's nil when the page isn't viewed as a user
+	State *store.ItemState
+}
+
+type viewAttachment struct {
+	Filename string
+	URL      string
+	Size     int64
+}
+
+var feedViewTemplate = template.Must(template.New("feed").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<link rel="alternate" type="application/atom+xml" title="{{ .Title }}" href="{{ .SubscribeURL }}">
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; background: #f6f6f6; }
+.card { background: #fff; border: 1px solid #ddd; border-radius: 6px; padding: 1rem; margin: 1rem 0; }
+.card h2 { font-size: 1.1rem; margin: 0 0 0.25rem; }
+.muted { color: #666; font-size: 0.9em; }
+.body { white-space: pre-wrap; overflow-wrap: anywhere; }
+iframe { width: 100%; min-height: 12rem; border: 0; }
+input { width: 100%; font-family: monospace; }
+nav { display: flex; justify-content: space-between; margin: 1rem 0; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{ if .Description }}<p>{{ .Description }}</p>{{ end }}
+<p><input type="text" readonly value="{{ .SubscribeURL }}" onclick="this.select(); navigator.clipboard && navigator.clipboard.writeText(this.value)"></p>
+{{ range .Items }}
+<article class="card" id="item-{{ .ID }}">
+<h2>{{ if .URL }}<a href="{{ .URL }}" rel="noopener noreferrer">{{ .Title }}</a>{{ else }}{{ .Title }}{{ end }}</h2>
+<div class="muted"><time datetime="{{ rfc3339 .CreatedAt }}" title="{{ rfc3339 .CreatedAt }}">{{ timeAgo .CreatedAt }}</time>{{ range .Tags }} · {{ . }}{{ end }}{{ with .State }}{{ if .Starred }} · starred{{ end }}{{ if not (or .Read .Archived) }} · <strong>unread</strong>{{ end }}{{ end }}</div>
+{{ if .Body }}{{ if .HTML }}<iframe sandbox srcdoc="{{ .Body }}" title="{{ .Title }}"></iframe>{{ else }}<p class="body">{{ .Body }}</p>{{ end }}{{ end }}
+{{ if .Attachments }}<ul>{{ range .Attachments }}<li><a href="{{ .URL }}">{{ .Filename }}</a> <span class="muted">{{ .Size }} bytes</span></li>{{ end }}</ul>{{ end }}
+</article>
+{{ else }}
+<p>There are no items in this feed{{ if gt .Page 1 }} page{{ end }}.</p>
+{{ end }}
+<nav>
+<span>{{ if .PrevURL }}<a href="{{ .PrevURL }}">&larr; Newer</a>{{ end }}</span>
+<span>{{ if .NextURL }}<a href="{{ .NextURL }}">Older &rarr;</a>{{ end }}</span>
+</nav>
+</body>
+</html>
+`))
+
+// BuildFeedViewHandler returns a handler rendering a feed as an HTML page, so that it can be followed in a browser.
+// Pages are selected with the page query param, private feeds need a read secret like the feed itself. Users can
+// give their token in the user_token query param to see their item states, and unread=true to hide items they've
+// read.
+func BuildFeedViewHandler(
+	items UserFeedStore,
+	secrets store.SecretStore,
+	opts FeedViewOptions,
+) func(http.ResponseWriter, *http.Request) {
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = 20
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed := mux.Vars(r)["feed"]
+		if !feedRegex.MatchString(feed) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			var err error
+			page, err = strconv.Atoi(p)
+			if err != nil || page < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		readable, err := feedReadable(r.Context(), secrets, feed, r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !readable {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// the page is viewed as a user when their token is given, so that their item states can be shown
+		userToken := r.URL.Query().Get("user_token")
+		var user string
+		if userToken != "" {
+			var ok bool
+			user, ok = lookupUser(opts.Users, userToken)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		unread := user != "" && r.URL.Query().Get("unread") == "true"
+
+		listOptions := store.ListOptions{
+			Limit:  pageSize + 1,
+			Offset: (page - 1) * pageSize,
+
+			PinnedFirst: true,
+		}
+		if unread {
+			listOptions.UnreadBy = user
+		}
+
+		// an extra item is loaded to find out if there's another page
+		feedItems, err := items.ListItems(r.Context(), feed, listOptions)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		hasNext := len(feedItems) > pageSize
+		if hasNext {
+			feedItems = feedItems[:pageSize]
+		}
+
+		var ids []int64
+		for _, item := range feedItems {
+			ids = append(ids, item.ID)
+		}
+		attachments, err := items.ListAttachments(r.Context(), feed, ids)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		states := make(map[int64]store.ItemState)
+		if user != "" {
+			userStates, err := items.ListItemStates(r.Context(), user, feed, ids)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			for _, state := range userStates {
+				states[state.ItemID] = state
+			}
+		}
+
+		// links are relative to the page and keep the read secret, so that they work for private feeds
+		key := r.URL.Query().Get("key")
+		withKey := func(u string, extra url.Values) string {
+			query := url.Values{}
+			for k, v := range extra {
+				query[k] = v
+			}
+			if key != "" {
+				query.Set("key", key)
+			}
+			if len(query) == 0 {
+				return u
+			}
+			return u + "?" + query.Encode()
+		}
+
+		itemAttachments := make(map[int64][]viewAttachment)
+		for _, a := range attachments {
+			itemAttachments[a.ItemID] = append(itemAttachments[a.ItemID], viewAttachment{
+				Filename: a.Filename,
+				URL:      withKey(fmt.Sprintf("%s/items/%d/attachments/%d", feed, a.ItemID, a.ID), nil),
+				Size:     a.Size,
+			})
+		}
+
+		var viewItems []viewItem
+		for _, item := range feedItems {
+			v := viewItem{
+				Item:        item,
+				HTML:        htmlBodyRegex.MatchString(item.Body),
+				Attachments: itemAttachments[item.ID],
+			}
+			if user != "" {
+				state := states[item.ID]
+				v.State = &state
+			}
+			viewItems = append(viewItems, v)
+		}
+
+		// page links also keep the user's token and filter
+		pageURL := func(page int) string {
+			query := url.Values{"page": {strconv.Itoa(page)}}
+			if user != "" {
+				query.Set("user_token", userToken)
+			}
+			if unread {
+				query.Set("unread", "true")
+			}
+			return withKey(feed, query)
+		}
+
+		info := opts.Feeds[feed]
+		title := info.Title
+		if title == "" {
+			title = feed
+		}
+
+		data := struct {
+			Title        string
+			Description  string
+			SubscribeURL string
+			Items        []viewItem
+			Page         int
+			PrevURL      string
+			NextURL      string
+		}{
+			Title:        title,
+			Description:  info.Description,
+			SubscribeURL: withKey(fmt.Sprintf("%s/feeds/%s.rss", indexBaseURL(r, opts.BaseURL, "/feeds/"+feed), feed), nil),
+			Items:        viewItems,
+			Page:         page,
+		}
+		if page > 1 {
+			data.PrevURL = pageURL(page - 1)
+		}
+		if hasNext {
+			data.NextURL = pageURL(page + 1)
+		}
+
+		var buf bytes.Buffer
+		err = feedViewTemplate.Execute(&buf, data)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestFeedView(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	var newItems []store.NewItem
+	for i := 0; i < 25; i++ {
+		newItems = append(newItems, store.NewItem{
+			GUID:      fmt.Sprint(i),
+			Title:     fmt.Sprintf("item %d", i),
+			CreatedAt: time.Now().Add(time.Duration(i-30) * time.Minute),
+		})
+	}
+	newItems[24].Body = "line one\nif a < b && c > d"
+	newItems[23].Body = "<p>html <b>body</b></p>"
+	newItems[22].URL = "https://example.com/22"
+	created, err := s.InsertItems(ctx, "example", newItems, store.InsertOptions{})
+	require.NoError(t, err)
+
+	_, err = s.AddAttachments(ctx, "example", created[24].ID, []store.NewAttachment{
+		{Filename: "notes.txt", ContentType: "text/plain", Data: []byte("hello")},
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}", BuildFeedViewHandler(s, s, FeedViewOptions{
+		BaseURL: "https://example.com/webhook-rss",
+		Feeds:   map[string]FeedInfo{"example": {Title: "Example feed"}},
+	})).Methods("GET")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/feeds/example")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "<title>Example feed</title>")
+	assert.Contains(t, body, `value="https://example.com/webhook-rss/feeds/example.rss"`)
+	assert.Contains(t, body, "item 24")
+	assert.Contains(t, body, "6 minutes ago")
+	assert.NotContains(t, body, "item 4<", "only the first page should be shown")
+	assert.Contains(t, body, "<p class=\"body\">line one\nif a &lt; b &amp;&amp; c &gt; d</p>", "text bodies should be escaped")
+	assert.Contains(t, body, `<iframe sandbox srcdoc="&lt;p&gt;html &lt;b&gt;body&lt;/b&gt;&lt;/p&gt;"`, "html bodies should be sandboxed")
+	assert.Contains(t, body, `<a href="https://example.com/22" rel="noopener noreferrer">item 22</a>`)
+	assert.Contains(t, body, fmt.Sprintf(`<a href="example/items/%d/attachments/1">notes.txt</a>`, created[24].ID))
+	assert.Contains(t, body, `<a href="example?page=2">Older &rarr;</a>`)
+	assert.NotContains(t, body, "Newer")
+
+	rec = get("/feeds/example?page=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body = rec.Body.String()
+	assert.Contains(t, body, "item 4<")
+	assert.Contains(t, body, "item 0<")
+	assert.Contains(t, body, `<a href="example?page=1">&larr; Newer</a>`)
+	assert.NotContains(t, body, "Older")
+
+	assert.Equal(t, http.StatusBadRequest, get("/feeds/example?page=0").Code)
+
+	// private feeds need a secret, which is kept in the page links
+	_, err = s.CreateSecret(ctx, "example", "reader", hashSecret("s3cret"))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, get("/feeds/example").Code)
+
+	rec = get("/feeds/example?key=s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<a href="example?key=s3cret&amp;page=2">`)
+}
+
+func TestFeedViewUser(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	created, err := s.InsertItems(ctx, "example", []store.NewItem{
+		{GUID: "a", Title: "first", CreatedAt: time.Now().Add(-2 * time.Minute)},
+		{GUID: "b", Title: "second", CreatedAt: time.Now().Add(-time.Minute)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	read := true
+	_, _, err = s.UpdateItemState(ctx, "alice", "example", created[0].ID, store.ItemStateUpdate{Read: &read})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}", BuildFeedViewHandler(s, s, FeedViewOptions{
+		PageSize: 1,
+		Users:    map[string]string{"alice-token": "alice"},
+	})).Methods("GET")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/feeds/example")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "unread", "states should only be shown to users")
+
+	assert.Equal(t, http.StatusUnauthorized, get("/feeds/example?user_token=wrong").Code)
+
+	rec = get("/feeds/example?user_token=alice-token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "second")
+	assert.Contains(t, body, "<strong>unread</strong>")
+	assert.Contains(t, body, `<a href="example?page=2&amp;user_token=alice-token">`)
+
+	rec = get("/feeds/example?user_token=alice-token&unread=true&page=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "first", "read items should be hidden")
+}
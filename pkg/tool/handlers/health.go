@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// ReadinessCheck returns an error when the tool isn't ready to serve requests
+type ReadinessCheck func(ctx context.Context) error
+
+// BuildHealthzHandler returns a handler which responds while the process is running, it doesn't check dependencies
+func BuildHealthzHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, toolAPIs.ResponseHealth{Status: "ok"})
+	}
+}
+
+// BuildReadyzHandler returns a handler which runs each readiness check, responding with a 503 if any fail
+func BuildReadyzHandler(checks map[string]ReadinessCheck) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		response := toolAPIs.ResponseHealth{Status: "ok", Checks: make(map[string]string)}
+		status := http.StatusOK
+
+		for name, check := range checks {
+			err := check(ctx)
+			if err != nil {
+				response.Checks[name] = err.Error()
+				response.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+
+			response.Checks[name] = "ok"
+		}
+
+		WriteJSON(w, status, response)
+	}
+}
+
+// StatusOptions configures the status handler
+type StatusOptions struct {
+	// Jobs returns the status of the jobs run by this replica
+	Jobs func() []jobs.RunStatus
+	// FeedCheck is used to find stale feeds, using the same rules as the feed-check job
+	FeedCheck *jobs.FeedCheck
+	// Heartbeats are checked using the same rules as the deadman-check job. The deadman feed is reported with its
+	// default interval unless there's a heartbeat named after it.
+	Heartbeats []jobs.Heartbeat
+}
+
+// BuildStatusHandler returns a handler which reports the last dead man pulse, each heartbeat, the last run of each
+// job and the feeds which are stale
+func BuildStatusHandler(items store.ItemStore, opts StatusOptions) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := toolAPIs.ResponseStatus{
+			Heartbeats: []toolAPIs.ResponseHeartbeat{},
+			Jobs:       []toolAPIs.ResponseJobStatus{},
+			StaleFeeds: []toolAPIs.ResponseStaleFeed{},
+		}
+
+		deadman := jobs.Heartbeat{Name: jobs.DeadmanFeed, Feed: jobs.DeadmanFeed, Interval: jobs.DeadmanMaxAge}
+		for _, heartbeat := range opts.Heartbeats {
+			status, err := heartbeat.Check(r.Context(), items)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to check heartbeat %s: %s", heartbeat.Name, err)
+				return
+			}
+
+			response.Heartbeats = append(response.Heartbeats, toolAPIs.ResponseHeartbeat{
+				Name:            heartbeat.Name,
+				Feed:            heartbeat.Feed,
+				IntervalSeconds: heartbeat.Interval.Seconds(),
+				Schedule:        heartbeat.Schedule,
+				GraceSeconds:    heartbeat.Grace.Seconds(),
+				LastBeatAt:      status.LastBeatAt,
+				Healthy:         status.Healthy(),
+			})
+
+			if heartbeat.Name == jobs.DeadmanFeed {
+				deadman = heartbeat
+			}
+		}
+
+		pulse, err := deadman.Check(r.Context(), items)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to load deadman feed: %s", err)
+			return
+		}
+		response.Deadman.LastPulseAt = pulse.LastBeatAt
+		response.Deadman.Healthy = pulse.Healthy()
+
+		if opts.Jobs != nil {
+			for _, job := range opts.Jobs() {
+				response.Jobs = append(response.Jobs, toolAPIs.ResponseJobStatus{
+					Name:                job.Name,
+					Schedule:            job.Schedule,
+					LastRunAt:           job.LastRunAt,
+					LastSuccessAt:       job.LastSuccessAt,
+					LastDurationSeconds: job.LastDuration.Seconds(),
+					LastError:           job.LastError,
+				})
+			}
+		}
+
+		if opts.FeedCheck != nil {
+			stale, err := opts.FeedCheck.StaleFeeds(r.Context())
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to check feeds: %s", err)
+				return
+			}
+
+			for _, feed := range stale {
+				response.StaleFeeds = append(response.StaleFeeds, toolAPIs.ResponseStaleFeed{
+					Feed:          feed.Feed,
+					LatestAt:      feed.LatestAt,
+					MaxAgeSeconds: feed.MaxAge.Seconds(),
+				})
+			}
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestReadyz(t *testing.T) {
+	checks := map[string]ReadinessCheck{
+		"storage": func(ctx context.Context) error { return nil },
+	}
+
+	rec := httptest.NewRecorder()
+	BuildReadyzHandler(checks)(rec, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "checks": {"storage": "ok"}}`, rec.Body.String())
+
+	checks["migrations"] = func(ctx context.Context) error { return errors.New("at migration 3, expected 4") }
+
+	rec = httptest.NewRecorder()
+	BuildReadyzHandler(checks)(rec, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(
+		t,
+		`{"status": "unavailable", "checks": {"storage": "ok", "migrations": "at migration 3, expected 4"}}`,
+		rec.Body.String(),
+	)
+}
+
+type statusTestJob struct {
+	err error
+}
+
+func (j *statusTestJob) Name() string                  { return "example" }
+func (j *statusTestJob) Run(ctx context.Context) error { return j.err }
+func (j *statusTestJob) Timeout() time.Duration        { return time.Second }
+func (j *statusTestJob) Schedule() string              { return "0 0 * * * *" }
+
+func TestStatus(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	pulseAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	_, err := s.InsertItems(ctx, jobs.DeadmanFeed, []store.NewItem{
+		{GUID: "pulse", Title: "Dead Man Pulse", CreatedAt: pulseAt},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+	_, err = s.InsertItems(ctx, "backups", []store.NewItem{
+		{GUID: "a", Title: "backup", CreatedAt: time.Now().Add(-48 * time.Hour)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	tracker := &jobs.Tracker{}
+	job := &statusTestJob{err: errors.New("failed")}
+	tracked := tracker.Track(job)
+	require.Error(t, tracked.Run(ctx))
+
+	handler := BuildStatusHandler(s, StatusOptions{
+		Jobs: tracker.Statuses,
+		FeedCheck: &jobs.FeedCheck{
+			Items: s,
+			Feeds: []interface{}{
+				map[string]interface{}{"name": "backups", "max_age": "24h"},
+				map[string]interface{}{"name": jobs.DeadmanFeed, "max_age": "2h"},
+			},
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status toolAPIs.ResponseStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+	require.NotNil(t, status.Deadman.LastPulseAt)
+	assert.True(t, status.Deadman.LastPulseAt.Equal(pulseAt))
+	assert.True(t, status.Deadman.Healthy)
+
+	require.Len(t, status.Jobs, 1)
+	assert.Equal(t, "example", status.Jobs[0].Name)
+	assert.NotNil(t, status.Jobs[0].LastRunAt)
+	assert.Nil(t, status.Jobs[0].LastSuccessAt)
+	assert.Equal(t, "failed", status.Jobs[0].LastError)
+
+	require.Len(t, status.StaleFeeds, 1)
+	assert.Equal(t, "backups", status.StaleFeeds[0].Feed)
+	assert.Equal(t, 86400.0, status.StaleFeeds[0].MaxAgeSeconds)
+
+	// the error is cleared by a successful run
+	job.err = nil
+	require.NoError(t, tracked.Run(ctx))
+	statuses := tracker.Statuses()
+	require.Len(t, statuses, 1)
+	assert.Empty(t, statuses[0].LastError)
+	assert.NotNil(t, statuses[0].LastSuccessAt)
+}
+
+func TestStatusHeartbeats(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	beatAt := time.Now().Add(-90 * time.Minute).UTC().Truncate(time.Second)
+	_, err := s.InsertItems(ctx, "backups-heartbeat", []store.NewItem{
+		{GUID: "a", Title: "backup", CreatedAt: beatAt},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+	_, err = s.InsertItems(ctx, jobs.DeadmanFeed, []store.NewItem{
+		{GUID: "future", Title: "Dead Man Pulse", CreatedAt: time.Now().Add(time.Hour)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	handler := BuildStatusHandler(s, StatusOptions{
+		Heartbeats: []jobs.Heartbeat{
+			{Name: "backups", Feed: "backups-heartbeat", Interval: time.Hour, Grace: time.Hour},
+			{Name: "builds", Feed: "builds", Interval: time.Hour},
+			{Name: jobs.DeadmanFeed, Feed: jobs.DeadmanFeed, Interval: time.Hour},
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status toolAPIs.ResponseStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+	require.Len(t, status.Heartbeats, 3)
+
+	backups := status.Heartbeats[0]
+	assert.Equal(t, "backups-heartbeat", backups.Feed)
+	require.NotNil(t, backups.LastBeatAt)
+	assert.True(t, backups.LastBeatAt.Equal(beatAt))
+	assert.True(t, backups.Healthy, "the grace period should be allowed after the interval")
+	assert.Equal(t, 3600.0, backups.GraceSeconds)
+
+	assert.Nil(t, status.Heartbeats[1].LastBeatAt)
+	assert.False(t, status.Heartbeats[1].Healthy, "feeds without beats should be unhealthy")
+
+	assert.Nil(t, status.Heartbeats[2].LastBeatAt, "future items should not count as beats")
+	assert.False(t, status.Heartbeats[2].Healthy)
+	assert.Nil(t, status.Deadman.LastPulseAt)
+	assert.False(t, status.Deadman.Healthy)
+}
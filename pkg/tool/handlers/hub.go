@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/websub"
+)
+
+// topicPathRegex matches the feed paths which can be subscribed to, relative to the base URL
+var topicPathRegex = regexp.MustCompile(`^/feeds/(?P<feed>[^/]+?)(?:/key/(?P<key>[^/]+))?\.rss$`)
+
+// HubOptions configures the WebSub hub handler
+type HubOptions struct {
+	// BaseURL is the external URL of the tool, topics must be feeds under this URL
+	BaseURL string
+}
+
+// BuildHubHandler returns a handler for WebSub subscription requests. Valid requests are accepted and the intent of
+// the subscriber is verified in the background, as described in the WebSub spec.
+func BuildHubHandler(
+	hub *websub.Hub,
+	secrets store.SecretStore,
+	opts HubOptions,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "failed to parse form: %s", err)
+			return
+		}
+
+		mode := r.PostForm.Get("hub.mode")
+		if mode != websub.ModeSubscribe && mode != websub.ModeUnsubscribe {
+			WriteError(w, http.StatusBadRequest, "hub.mode must be subscribe or unsubscribe")
+			return
+		}
+
+		callback, err := url.Parse(r.PostForm.Get("hub.callback"))
+		if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+			WriteError(w, http.StatusBadRequest, "hub.callback must be an absolute http or https URL")
+			return
+		}
+
+		topic := r.PostForm.Get("hub.topic")
+		feed, key, ok := parseTopic(opts.BaseURL, topic)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "hub.topic must be a feed URL under %s", opts.BaseURL)
+			return
+		}
+
+		// private feeds can only be subscribed to with a topic containing a valid secret
+		readable, err := secretMatches(r.Context(), secrets, feed, []string{key})
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to check feed secrets")
+			return
+		}
+		if !readable {
+			WriteError(w, http.StatusNotFound, "feed %s not found", feed)
+			return
+		}
+
+		err = hub.CheckCallback(r.Context(), callback)
+		if errors.Is(err, websub.ErrPrivateCallback) {
+			WriteError(w, http.StatusBadRequest, "hub.callback must not be a loopback, private or link-local address")
+			return
+		}
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "hub.callback is invalid: %s", err)
+			return
+		}
+
+		secret := r.PostForm.Get("hub.secret")
+		if len(secret) > 200 {
+			WriteError(w, http.StatusBadRequest, "hub.secret must be less than 200 bytes")
+			return
+		}
+
+		var requestedLease int64
+		if v := r.PostForm.Get("hub.lease_seconds"); v != "" {
+			requestedLease, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || requestedLease < 0 {
+				WriteError(w, http.StatusBadRequest, "hub.lease_seconds must be a positive integer")
+				return
+			}
+		}
+
+		sub := store.Subscription{
+			Feed:         feed,
+			Topic:        topic,
+			Callback:     callback.String(),
+			Secret:       secret,
+			LeaseSeconds: hub.LeaseSeconds(requestedLease),
+		}
+
+		// verification must happen after the response has been sent, it's queued for the hub's workers
+		if !hub.VerifyLater(mode, sub) {
+			w.Header().Set("Retry-After", "60")
+			WriteError(w, http.StatusServiceUnavailable, "too many pending verifications, try again later")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// BuildTopicAuthorizer returns a function which checks that a subscription's topic still grants access to its feed,
+// this stops sending private feeds to subscribers once their secret has been revoked.
+func BuildTopicAuthorizer(
+	secrets store.SecretStore,
+	opts HubOptions,
+) func(ctx context.Context, sub store.Subscription) (bool, error) {
+	return func(ctx context.Context, sub store.Subscription) (bool, error) {
+		feed, key, ok := parseTopic(opts.BaseURL, sub.Topic)
+		if !ok || feed != sub.Feed {
+			return false, nil
+		}
+
+		return secretMatches(ctx, secrets, feed, []string{key})
+	}
+}
+
+// parseTopic returns the feed and secret, if any, for a topic URL. Topics must be feeds under the base URL.
+func parseTopic(baseURL, topic string) (feed, key string, ok bool) {
+	if baseURL == "" || !strings.HasPrefix(topic, baseURL+"/") {
+		return "", "", false
+	}
+
+	u, err := url.Parse(topic)
+	if err != nil {
+		return "", "", false
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", "", false
+	}
+
+	m := topicPathRegex.FindStringSubmatch(strings.TrimPrefix(u.Path, strings.TrimSuffix(base.Path, "/")))
+	if m == nil {
+		return "", "", false
+	}
+
+	feed = m[topicPathRegex.SubexpIndex("feed")]
+	if !feedRegex.MatchString(feed) {
+		return "", "", false
+	}
+
+	key = m[topicPathRegex.SubexpIndex("key")]
+	if queryKey := u.Query().Get("key"); queryKey != "" {
+		key = queryKey
+	}
+
+	return feed, key, true
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/websub"
+)
+
+func TestHub(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	baseURL := "https://example.com/webhook-rss"
+
+	_, err := s.CreateSecret(ctx, "private", "reader", hashSecret("s3cret"))
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var deliveries []*http.Request
+	var bodies []string
+
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(r.URL.Query().Get("hub.challenge")))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, r)
+		bodies = append(bodies, string(body))
+	}))
+	defer subscriber.Close()
+
+	selfURL := func(feed string) string { return baseURL + "/feeds/" + feed + ".rss" }
+	hub := &websub.Hub{
+		Subscriptions: s,
+		HubURL:        baseURL + "/hub",
+		SelfURL:       selfURL,
+		Render: func(ctx context.Context, feed string) (string, error) {
+			return RenderFeed(ctx, s, feed, selfURL(feed), FeedLinks{})
+		},
+		Authorize:           BuildTopicAuthorizer(s, HubOptions{BaseURL: baseURL}),
+		DefaultLeaseSeconds: 3600,
+		MaxLeaseSeconds:     7200,
+		// the subscriber is served on loopback
+		AllowPrivateCallbacks: true,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/hub", BuildHubHandler(hub, s, HubOptions{BaseURL: baseURL})).Methods("POST")
+	router.HandleFunc("/feeds/{feed}/items", BuildItemCreateHandler(s, ItemCreateOptions{
+		OnCreated: []func(ctx context.Context, feed string, items []store.Item){
+			func(ctx context.Context, feed string, items []store.Item) { hub.Notify(feed) },
+		},
+	})).Methods("POST")
+
+	subscribe := func(values url.Values) int {
+		req := httptest.NewRequest("POST", "/hub", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, subscribe(url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {"https://elsewhere.com/feeds/example.rss"},
+		"hub.callback": {subscriber.URL},
+	}), "topics must be on this tool")
+
+	assert.Equal(t, http.StatusBadRequest, subscribe(url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {selfURL("example")},
+		"hub.callback": {"not-a-url"},
+	}))
+
+	assert.Equal(t, http.StatusNotFound, subscribe(url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {selfURL("private")},
+		"hub.callback": {subscriber.URL},
+	}), "private feeds need a secret in the topic")
+
+	require.Equal(t, http.StatusAccepted, subscribe(url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {selfURL("example")},
+		"hub.callback":      {subscriber.URL},
+		"hub.secret":        {"signing-secret"},
+		"hub.lease_seconds": {"100000"},
+	}))
+
+	require.Equal(t, http.StatusAccepted, subscribe(url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {baseURL + "/feeds/private/key/s3cret.rss"},
+		"hub.callback": {subscriber.URL},
+	}))
+
+	assert.Eventually(t, func() bool {
+		subs, err := s.ListSubscriptions(ctx, "example")
+		return err == nil && len(subs) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	subs, err := s.ListSubscriptions(ctx, "example")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7200), subs[0].LeaseSeconds, "leases should be capped")
+
+	req := httptest.NewRequest("POST", "/feeds/example/items", strings.NewReader(`{"title":"pushed item"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(deliveries) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	delivery, body := deliveries[0], bodies[0]
+	mu.Unlock()
+
+	assert.Contains(t, body, "pushed item")
+	assert.Contains(t, delivery.Header.Values("Link"), `<https://example.com/webhook-rss/hub>; rel="hub"`)
+
+	mac := hmac.New(sha256.New, []byte("signing-secret"))
+	mac.Write([]byte(body))
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), delivery.Header.Get("X-Hub-Signature"))
+
+	// subscribers to private feeds stop receiving updates when their secret is revoked
+	secrets, err := s.ListSecrets(ctx, "private")
+	require.NoError(t, err)
+	_, err = s.RevokeSecret(ctx, "private", secrets[0].ID)
+	require.NoError(t, err)
+	_, err = s.CreateSecret(ctx, "private", "other", hashSecret("other"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		subs, err := s.ListSubscriptions(ctx, "private")
+		return err == nil && len(subs) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = s.InsertItems(ctx, "private", []store.NewItem{{GUID: "a", Title: "private item"}}, store.InsertOptions{})
+	require.NoError(t, err)
+	require.NoError(t, hub.Publish(ctx, "private"))
+
+	mu.Lock()
+	assert.Len(t, deliveries, 1)
+	mu.Unlock()
+
+	require.Equal(t, http.StatusAccepted, subscribe(url.Values{
+		"hub.mode":     {"unsubscribe"},
+		"hub.topic":    {selfURL("example")},
+		"hub.callback": {subscriber.URL},
+	}))
+
+	assert.Eventually(t, func() bool {
+		subs, err := s.ListSubscriptions(ctx, "example")
+		return err == nil && len(subs) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHubPrivateCallbacks(t *testing.T) {
+	s := store.NewMemory()
+	baseURL := "https://example.com/webhook-rss"
+	hub := &websub.Hub{Subscriptions: s, DefaultLeaseSeconds: 3600}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/hub", BuildHubHandler(hub, s, HubOptions{BaseURL: baseURL})).Methods("POST")
+
+	for _, callback := range []string{
+		"http://127.0.0.1:8080/callback",
+		"http://localhost/callback",
+		"http://10.0.0.1/callback",
+		"http://192.168.1.10/callback",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/callback",
+		"http://[fe80::1]/callback",
+	} {
+		values := url.Values{
+			"hub.mode":     {"subscribe"},
+			"hub.topic":    {baseURL + "/feeds/example.rss"},
+			"hub.callback": {callback},
+		}
+		req := httptest.NewRequest("POST", "/hub", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, callback)
+		assert.Contains(t, rec.Body.String(), "loopback, private or link-local", callback)
+	}
+}
+
+func TestFeedGetHubLinks(t *testing.T) {
+	s := store.NewMemory()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}.rss", BuildFeedGetHandler(s, s, FeedGetOptions{
+		BaseURL: "https://example.com/webhook-rss",
+		HubURL:  "https://example.com/webhook-rss/hub",
+	})).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/feeds/example.rss", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Contains(t, rec.Header().Values("Link"), `<https://example.com/webhook-rss/hub>; rel="hub"`)
+	assert.Contains(t, rec.Header().Values("Link"), `<https://example.com/webhook-rss/feeds/example.rss>; rel="self"`)
+	assert.Contains(t, rec.Body.String(), `<link href="https://example.com/webhook-rss/hub" rel="hub"></link>`)
+	assert.Contains(t, rec.Body.String(), `<link href="https://example.com/webhook-rss/feeds/example.rss" rel="self"></link>`)
+}
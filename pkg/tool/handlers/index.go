@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// FeedInfo describes a feed in the index and OPML export, it's loaded from the feeds config block
+type FeedInfo struct {
+	Title       string
+	Description string
+	Owner       string
+	Tags        []string
+}
+
+// FeedIndexOptions configures the feed index and OPML handlers
+type FeedIndexOptions struct {
+	// BaseURL is the external URL of the tool, when blank it's worked out from the request
+	BaseURL string
+	// Feeds describes the configured feeds, these are listed even if they don't have any items yet
+	Feeds map[string]FeedInfo
+}
+
+// indexedFeed is a feed listed in the index
+type indexedFeed struct {
+	Name string
+	FeedInfo
+
+	Count    int64
+	LatestAt time.Time
+	URL      string
+	// PageURL is the HTML view of the feed
+	PageURL string
+}
+
+// listFeeds returns the public feeds matching the tag and owner filters, sorted by name. Private feeds aren't
+// listed so that their names aren't disclosed.
+func listFeeds(
+	ctx context.Context,
+	items store.ItemStore,
+	secrets store.SecretStore,
+	opts FeedIndexOptions,
+	baseURL, tag, owner string,
+) ([]indexedFeed, error) {
+	stats, err := items.FeedStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := make(map[string]*indexedFeed)
+	for name, info := range opts.Feeds {
+		feeds[name] = &indexedFeed{Name: name, FeedInfo: info}
+	}
+	for _, s := range stats {
+		f, ok := feeds[s.Feed]
+		if !ok {
+			f = &indexedFeed{Name: s.Feed}
+			feeds[s.Feed] = f
+		}
+		f.Count = s.Count
+		f.LatestAt = s.LatestAt
+	}
+
+	var listed []indexedFeed
+	for _, f := range feeds {
+		if owner != "" && !strings.EqualFold(f.Owner, owner) {
+			continue
+		}
+		if tag != "" && !store.Tags(f.Tags).Has(tag) {
+			continue
+		}
+
+		private, err := secrets.FeedPrivate(ctx, f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load feed privacy: %w", err)
+		}
+		if private {
+			continue
+		}
+
+		f.URL = fmt.Sprintf("%s/feeds/%s.rss", baseURL, f.Name)
+		f.PageURL = fmt.Sprintf("%s/feeds/%s", baseURL, f.Name)
+		listed = append(listed, *f)
+	}
+
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].Name < listed[j].Name
+	})
+
+	return listed, nil
+}
+
+// indexBaseURL returns the external URL of the tool, working it out from the request and the path it was made to
+// under the tool when there's no base URL configured
+func indexBaseURL(r *http.Request, baseURL, path string) string {
+	if baseURL != "" {
+		return baseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, strings.TrimSuffix(r.URL.Path, path))
+}
+
+type opml struct {
+	XMLName xml.Name    `xml:"opml"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"head>title"`
+	Created string      `xml:"head>dateCreated"`
+	Body    []opmlEntry `xml:"body>outline"`
+}
+
+type opmlEntry struct {
+	Type        string `xml:"type,attr"`
+	Text        string `xml:"text,attr"`
+	Title       string `xml:"title,attr,omitempty"`
+	Description string `xml:"description,attr,omitempty"`
+	XMLURL      string `xml:"xmlUrl,attr"`
+	HTMLURL     string `xml:"htmlUrl,attr"`
+	Category    string `xml:"category,attr,omitempty"`
+}
+
+// BuildOPMLHandler returns a handler listing the public feeds as OPML, so that they can be imported into a reader.
+// Feeds can be filtered with the tag and owner query params.
+func BuildOPMLHandler(
+	items store.ItemStore,
+	secrets store.SecretStore,
+	opts FeedIndexOptions,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		baseURL := indexBaseURL(r, opts.BaseURL, "/feeds.opml")
+
+		feeds, err := listFeeds(r.Context(), items, secrets, opts, baseURL, r.URL.Query().Get("tag"), r.URL.Query().Get("owner"))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		doc := opml{
+			Version: "2.0",
+			Title:   "webhook-rss feeds",
+			Created: time.Now().UTC().Format(time.RFC1123Z),
+		}
+		for _, f := range feeds {
+			title := f.Title
+			if title == "" {
+				title = f.Name
+			}
+
+			doc.Body = append(doc.Body, opmlEntry{
+				Type:        "rss",
+				Text:        title,
+				Title:       title,
+				Description: f.Description,
+				XMLURL:      f.URL,
+				HTMLURL:     f.PageURL,
+				Category:    strings.Join(f.Tags, ","),
+			})
+		}
+
+		b, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		w.Write(b)
+	}
+}
+
+// timeAgo describes how long ago t was, e.g. "5 minutes ago"
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case d < 0:
+		return "scheduled"
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// pageFuncs are available in the HTML page templates
+var pageFuncs = template.FuncMap{
+	"timeAgo": timeAgo,
+	"rfc3339": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>webhook-rss feeds</title>
+<link rel="alternate" type="text/x-opml" title="All feeds" href="{{ .OPMLURL }}">
+<style>
+body { font-family: system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.5rem; border-bottom: 1px solid #ddd; vertical-align: top; }
+.muted { color: #666; font-size: 0.9em; }
+input { width: 100%; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>webhook-rss feeds</h1>
+<p><a href="{{ .OPMLURL }}">Download OPML</a> to subscribe to {{ if .Filtered }}these{{ else }}all{{ end }} feeds at once.</p>
+{{ if .Feeds }}
+<table>
+<thead><tr><th>Feed</th><th>Items</th><th>Updated</th><th>Subscribe</th></tr></thead>
+<tbody>
+{{ range .Feeds }}
+<tr>
+<td><strong><a href="{{ .PageURL }}">{{ if .Title }}{{ .Title }}{{ else }}{{ .Name }}{{ end }}</a></strong>
+{{ if .Description }}<br><span class="muted">{{ .Description }}</span>{{ end }}
+{{ if .Owner }}<br><span class="muted">owner: {{ .Owner }}</span>{{ end }}
+{{ if .Tags }}<br><span class="muted">tags: {{ range $i, $t := .Tags }}{{ if $i }}, {{ end }}{{ $t }}{{ end }}</span>{{ end }}</td>
+<td>{{ .Count }}</td>
+<td>{{ if .LatestAt.IsZero }}<span class="muted">never</span>{{ else }}<time datetime="{{ rfc3339 .LatestAt }}" title="{{ rfc3339 .LatestAt }}">{{ timeAgo .LatestAt }}</time>{{ end }}</td>
+<td><input type="text" readonly value="{{ .URL }}" onclick="this.select(); navigator.clipboard && navigator.clipboard.writeText(this.value)"></td>
+</tr>
+{{ end }}
+</tbody>
+</table>
+{{ else }}
+<p>There are no feeds yet.</p>
+{{ end }}
+</body>
+</html>
+`))
+
+// BuildIndexHandler returns a handler rendering an HTML page listing the public feeds, with links to subscribe.
+// Feeds can be filtered with the tag and owner query params.
+func BuildIndexHandler(
+	items store.ItemStore,
+	secrets store.SecretStore,
+	opts FeedIndexOptions,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		baseURL := indexBaseURL(r, opts.BaseURL, "/")
+
+		tag, owner := r.URL.Query().Get("tag"), r.URL.Query().Get("owner")
+
+		feeds, err := listFeeds(r.Context(), items, secrets, opts, baseURL, tag, owner)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		opmlURL := baseURL + "/feeds.opml"
+		if r.URL.RawQuery != "" {
+			opmlURL += "?" + r.URL.Query().Encode()
+		}
+
+		var page bytes.Buffer
+		err = indexTemplate.Execute(&page, struct {
+			Feeds    []indexedFeed
+			OPMLURL  string
+			Filtered bool
+		}{
+			Feeds:    feeds,
+			OPMLURL:  opmlURL,
+			Filtered: tag != "" || owner != "",
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestFeedIndex(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	for _, feed := range []string{"alerts", "builds", "private"} {
+		_, err := s.InsertItems(ctx, feed, []store.NewItem{
+			{GUID: "a", Title: "item", CreatedAt: time.Now().Add(-2 * time.Hour)},
+		}, store.InsertOptions{})
+		require.NoError(t, err)
+	}
+	_, err := s.CreateSecret(ctx, "private", "reader", hashSecret("s3cret"))
+	require.NoError(t, err)
+
+	opts := FeedIndexOptions{
+		Feeds: map[string]FeedInfo{
+			"alerts":  {Title: "Production alerts", Owner: "sre", Tags: []string{"ops"}},
+			"backups": {Description: "Nightly backups", Owner: "platform", Tags: []string{"ops"}},
+		},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/webhook-rss/", BuildIndexHandler(s, s, opts)).Methods("GET")
+	router.HandleFunc("/webhook-rss/feeds.opml", BuildOPMLHandler(s, s, opts)).Methods("GET")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/webhook-rss/feeds.opml")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/x-opml; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, `<outline type="rss" text="Production alerts" title="Production alerts" xmlUrl="http://example.com/webhook-rss/feeds/alerts.rss" htmlUrl="http://example.com/webhook-rss/feeds/alerts" category="ops"></outline>`)
+	assert.Contains(t, body, `xmlUrl="http://example.com/webhook-rss/feeds/backups.rss"`, "configured feeds should be listed without items")
+	assert.Contains(t, body, `xmlUrl="http://example.com/webhook-rss/feeds/builds.rss"`)
+	assert.NotContains(t, body, "private", "private feeds should not be listed")
+
+	rec = get("/webhook-rss/feeds.opml?owner=platform")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "backups.rss")
+	assert.NotContains(t, rec.Body.String(), "alerts.rss")
+
+	rec = get("/webhook-rss/feeds.opml?tag=ops")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "alerts.rss")
+	assert.NotContains(t, rec.Body.String(), "builds.rss")
+
+	rec = get("/webhook-rss/?tag=ops")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body = rec.Body.String()
+	assert.Contains(t, body, "Production alerts")
+	assert.Contains(t, body, "2 hours ago")
+	assert.Contains(t, body, `value="http://example.com/webhook-rss/feeds/alerts.rss"`)
+	assert.Contains(t, body, `href="http://example.com/webhook-rss/feeds.opml?tag=ops"`)
+	assert.NotContains(t, body, "builds")
+}
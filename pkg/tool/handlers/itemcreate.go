@@ -2,114 +2,307 @@ package handlers
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/doug-martin/goqu/v9"
 	"github.com/gorilla/mux"
 
 	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
 )
 
-func BuildItemCreateHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
-	goquDB := goqu.New("postgres", db)
+// ItemCreateOptions configures how the item create handler interprets incoming items
+type ItemCreateOptions struct {
+	// FeedLocations holds the location used for dates without an offset, by feed name. Feeds not listed use UTC.
+	FeedLocations map[string]*time.Location
 
+	// MaxRequestBytes limits the size of the request body, 0 disables the limit
+	MaxRequestBytes int64
+	// MaxItemsPerRequest limits the number of items in a single request, 0 disables the limit
+	MaxItemsPerRequest int
+	// MaxFeedBytesPerDay limits the total bytes of items accepted by each feed per UTC day, 0 disables the limit
+	MaxFeedBytesPerDay int64
+
+	// OnCreated is called with the created items after they have been stored
+	OnCreated []func(ctx context.Context, feed string, items []store.Item)
+	// OnRejected is called with one of the Rejected reasons when a request is rejected, it's used for metrics
+	OnRejected func(reason string)
+
+	// now is used in tests to set the time items are inserted at
+	now func() time.Time
+}
+
+// Reasons requests to create items are rejected, passed to OnRejected
+const (
+	RejectedInvalidFeed   = "invalid_feed"
+	RejectedTooLarge      = "too_large"
+	RejectedInvalidJSON   = "invalid_json"
+	RejectedTooManyItems  = "too_many_items"
+	RejectedInvalidItems  = "invalid_items"
+	RejectedQuotaExceeded = "quota_exceeded"
+	RejectedRateLimited   = "rate_limited"
+)
+
+func (o ItemCreateOptions) rejected(reason string) {
+	if o.OnRejected != nil {
+		o.OnRejected(reason)
+	}
+}
+
+func (o ItemCreateOptions) currentTime() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+// BuildItemCreateHandler returns a handler which accepts an item, or array of items, for a feed. Invalid items are
+// reported by index in the JSON response. By default, any invalid item causes the whole request to be rejected,
+// requests with ?partial=true will instead create the valid items and report the invalid ones.
+func BuildItemCreateHandler(items store.ItemStore, opts ItemCreateOptions) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 
 		feed, ok := vars["feed"]
 		if !ok || feed == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("feed var missing"))
+			opts.rejected(RejectedInvalidFeed)
+			WriteError(w, http.StatusBadRequest, "feed var missing")
 			return
 		}
 
 		if !feedRegex.MatchString(feed) {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("feed didn't match regex"))
+			opts.rejected(RejectedInvalidFeed)
+			WriteError(w, http.StatusBadRequest, "feed didn't match regex")
 			return
 		}
 
+		if opts.MaxRequestBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, opts.MaxRequestBytes)
+		}
+
 		b, err := io.ReadAll(r.Body)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("failed to read request body"))
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				opts.rejected(RejectedTooLarge)
+				WriteError(w, http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxBytesErr.Limit)
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "failed to read request body")
 			return
 		}
 
-		var items []toolAPIs.PayloadNewItem
-		arrErr := json.NewDecoder(bytes.NewBuffer(b)).Decode(&items)
+		var payloadItems []toolAPIs.PayloadNewItem
+		arrErr := json.NewDecoder(bytes.NewBuffer(b)).Decode(&payloadItems)
 		if arrErr != nil {
 			// here we handle the case where a single item is sent.
 			// regrettably, the apple shortcuts app can't send arrays, so we have to handle single items here.
 			var item toolAPIs.PayloadNewItem
 			err := json.NewDecoder(bytes.NewBuffer(b)).Decode(&item)
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("failed to parse JSON data as as item array or item object"))
-				w.Write([]byte(err.Error()))
+				opts.rejected(RejectedInvalidJSON)
+				WriteError(w, http.StatusBadRequest, "failed to parse JSON data as as item array or item object: %s", err)
 				return
 			}
-			items = []toolAPIs.PayloadNewItem{item}
+			payloadItems = []toolAPIs.PayloadNewItem{item}
 		}
 
-		var records []goqu.Record
+		if len(payloadItems) == 0 {
+			opts.rejected(RejectedInvalidItems)
+			WriteError(w, http.StatusBadRequest, "no items in request")
+			return
+		}
 
-		for _, item := range items {
-			if item.Title == "" {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("title can't be blank"))
-				return
-			}
+		if opts.MaxItemsPerRequest > 0 && len(payloadItems) > opts.MaxItemsPerRequest {
+			opts.rejected(RejectedTooManyItems)
+			WriteError(
+				w,
+				http.StatusRequestEntityTooLarge,
+				"request contains %d items, the limit is %d",
+				len(payloadItems),
+				opts.MaxItemsPerRequest,
+			)
+			return
+		}
 
-			if len(item.Title) > 500 {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("title too long"))
-				return
-			}
+		partial := r.URL.Query().Get("partial") == "true"
 
-			if len(item.Body) > 100000 {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("body too long"))
-				return
-			}
+		// senders may opt in to using the current time when a date can't be parsed,
+		// otherwise unparseable dates are rejected rather than silently dropped.
+		dateFallback := r.URL.Query().Get("date_fallback") == "now"
+		loc := opts.FeedLocations[feed]
+		// items without a date are created at now, so it's also used to check their expiry
+		now := opts.currentTime()
+
+		var newItems []store.NewItem
+		var itemErrors []toolAPIs.ResponseError
+		// indexes holds the payload index of each new item by guid so created items can be reported
+		indexes := make(map[string]int)
 
-			record := goqu.Record{
-				"feed":  feed,
-				"title": item.Title,
-				"body":  item.Body,
-				"url":   item.URL,
+		for i, item := range payloadItems {
+			newItem, errs := buildNewItem(i, item, loc, dateFallback, now)
+			if len(errs) > 0 {
+				itemErrors = append(itemErrors, errs...)
+				continue
 			}
 
-			trimmedDate := strings.TrimSpace(item.Date)
-			if len(trimmedDate) == 10 {
-				date, err := time.Parse("2006-01-02", trimmedDate)
-				if err == nil {
-					record["created_at"] = date
-				}
-			} else if trimmedDate != "" {
-				date, err := time.Parse("2006-01-02T15:04:05-07:00", trimmedDate)
-				if err == nil {
-					record["created_at"] = date
-				}
+			guid, err := NewGUID()
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to generate item guid")
+				return
 			}
+			newItem.GUID = guid
+			indexes[guid] = i
 
-			records = append(records, record)
+			newItems = append(newItems, newItem)
 		}
 
-		ins := goquDB.Insert("webhookrss.items").Rows(records)
+		if len(itemErrors) > 0 && (!partial || len(newItems) == 0) {
+			opts.rejected(RejectedInvalidItems)
+			WriteJSON(w, http.StatusBadRequest, toolAPIs.ResponseErrors{Errors: itemErrors})
+			return
+		}
 
-		_, err = ins.Executor().Exec()
+		created, err := items.InsertItems(r.Context(), feed, newItems, store.InsertOptions{
+			MaxFeedBytesPerDay: opts.MaxFeedBytesPerDay,
+			Now:                now,
+		})
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			opts.rejected(RejectedQuotaExceeded)
+			WriteError(
+				w,
+				http.StatusTooManyRequests,
+				"feed %s has exceeded its daily limit of %d bytes",
+				feed,
+				opts.MaxFeedBytesPerDay,
+			)
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
+			WriteError(w, http.StatusInternalServerError, "failed to insert items: %s", err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		for _, fn := range opts.OnCreated {
+			fn(r.Context(), feed, created)
+		}
+
+		response := toolAPIs.ResponseItemsCreated{
+			Items:  []toolAPIs.ResponseCreatedItem{},
+			Errors: itemErrors,
+		}
+		for _, c := range created {
+			response.Items = append(response.Items, toolAPIs.ResponseCreatedItem{
+				Index: indexes[c.GUID],
+				ID:    c.ID,
+				GUID:  c.GUID,
+			})
+		}
+
+		WriteJSON(w, http.StatusCreated, response)
+	}
+}
+
+// buildNewItem validates an item from the request payload and returns the new item to insert for it. If the item
+// is invalid, errors are returned for each invalid field. Items without a date are published at now.
+func buildNewItem(
+	index int,
+	item toolAPIs.PayloadNewItem,
+	loc *time.Location,
+	dateFallback bool,
+	now time.Time,
+) (store.NewItem, []toolAPIs.ResponseError) {
+	var errs []toolAPIs.ResponseError
+	addError := func(field, reason string) {
+		i := index
+		errs = append(errs, toolAPIs.ResponseError{Index: &i, Field: field, Reason: reason})
+	}
+
+	if item.Title == "" {
+		addError("title", "title can't be blank")
+	}
+
+	if len(item.Title) > store.MaxTitleBytes {
+		addError("title", "title too long")
+	}
+
+	if len(item.Body) > store.MaxBodyBytes {
+		addError("body", "body too long")
+	}
+
+	if len(item.Tags) > store.MaxItemTags {
+		addError("tags", "too many tags")
 	}
+
+	newItem := store.NewItem{
+		Title:  item.Title,
+		Body:   item.Body,
+		URL:    item.URL,
+		Pinned: item.Pinned,
+	}
+
+	if item.Priority != "" {
+		priority, ok := store.ParsePriority(strings.ToLower(strings.TrimSpace(item.Priority)))
+		if !ok {
+			addError("priority", "priority must be one of low, normal, high or urgent")
+		}
+		newItem.Priority = priority
+	}
+
+	for _, tag := range item.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagRegex.MatchString(tag) {
+			addError("tags", fmt.Sprintf("tag %q must be letters, numbers, dashes, dots or underscores", tag))
+			continue
+		}
+		if !newItem.Tags.Has(tag) {
+			newItem.Tags = append(newItem.Tags, tag)
+		}
+	}
+
+	if strings.TrimSpace(item.Date) != "" {
+		date, err := parseDate(item.Date, loc)
+		if err != nil && !dateFallback {
+			addError("date", "failed to parse date: "+err.Error())
+		}
+		if err == nil {
+			newItem.CreatedAt = date
+		}
+	}
+
+	if strings.TrimSpace(item.PublishAt) != "" {
+		publishAt, err := parseDate(item.PublishAt, loc)
+		switch {
+		case strings.TrimSpace(item.Date) != "":
+			addError("publish_at", "date and publish_at can't both be set")
+		case err != nil:
+			addError("publish_at", "failed to parse publish_at: "+err.Error())
+		default:
+			newItem.CreatedAt = publishAt
+		}
+	}
+
+	if strings.TrimSpace(item.ExpiresAt) != "" {
+		expiresAt, err := parseDate(item.ExpiresAt, loc)
+		publishedAt := newItem.CreatedAt
+		if publishedAt.IsZero() {
+			publishedAt = now
+		}
+		switch {
+		case err != nil:
+			addError("expires_at", "failed to parse expires_at: "+err.Error())
+		case !expiresAt.After(publishedAt):
+			addError("expires_at", "expires_at must be after the item is published")
+		default:
+			newItem.ExpiresAt = &expiresAt
+		}
+	}
+
+	return newItem, errs
 }
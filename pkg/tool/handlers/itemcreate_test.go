@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func postItems(t *testing.T, handler http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}/items", handler).Methods("POST")
+
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestItemCreate(t *testing.T) {
+	s := store.NewMemory()
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{})
+
+	rec := postItems(t, handler, "/feeds/example/items", `{"title": "single", "date": 1665587045}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var created toolAPIs.ResponseItemsCreated
+	err := json.NewDecoder(rec.Body).Decode(&created)
+	require.NoError(t, err)
+	require.Len(t, created.Items, 1)
+	assert.NotEmpty(t, created.Items[0].GUID)
+
+	items, err := s.ListItems(context.Background(), "example", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "single", items[0].Title)
+	assert.Equal(t, int64(1665587045), items[0].CreatedAt.Unix())
+}
+
+func TestItemCreateInvalidItems(t *testing.T) {
+	s := store.NewMemory()
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{})
+
+	body := `[{"title": "valid"}, {"title": "", "date": "yesterday"}]`
+
+	rec := postItems(t, handler, "/feeds/example/items", body)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var errs toolAPIs.ResponseErrors
+	err := json.NewDecoder(rec.Body).Decode(&errs)
+	require.NoError(t, err)
+	require.Len(t, errs.Errors, 2)
+	for _, e := range errs.Errors {
+		require.NotNil(t, e.Index)
+		assert.Equal(t, 1, *e.Index)
+	}
+	assert.Equal(t, "title", errs.Errors[0].Field)
+	assert.Equal(t, "date", errs.Errors[1].Field)
+
+	stats, err := s.FeedStats(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, stats, "no items should be created")
+
+	rec = postItems(t, handler, "/feeds/example/items?partial=true", body)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var created toolAPIs.ResponseItemsCreated
+	err = json.NewDecoder(rec.Body).Decode(&created)
+	require.NoError(t, err)
+	require.Len(t, created.Items, 1)
+	assert.Equal(t, 0, created.Items[0].Index)
+	assert.Len(t, created.Errors, 2)
+}
+
+func TestItemCreateTags(t *testing.T) {
+	s := store.NewMemory()
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{})
+
+	rec := postItems(t, handler, "/feeds/example/items", `{"title": "tagged", "tags": ["Critical", "backups", "critical"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	items, err := s.ListItems(context.Background(), "example", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, store.Tags{"critical", "backups"}, items[0].Tags, "tags should be lowercased and deduplicated")
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "invalid", "tags": ["a,b"]}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var errs toolAPIs.ResponseErrors
+	err = json.NewDecoder(rec.Body).Decode(&errs)
+	require.NoError(t, err)
+	require.Len(t, errs.Errors, 1)
+	assert.Equal(t, "tags", errs.Errors[0].Field)
+}
+
+func TestItemCreateLimits(t *testing.T) {
+	s := store.NewMemory()
+	var rejected []string
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{
+		MaxRequestBytes:    100,
+		MaxItemsPerRequest: 2,
+		MaxFeedBytesPerDay: 20,
+		OnRejected: func(reason string) {
+			rejected = append(rejected, reason)
+		},
+	})
+
+	rec := postItems(t, handler, "/feeds/example/items", `{"title": "`+strings.Repeat("a", 100)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = postItems(t, handler, "/feeds/example/items", `[{"title": "a"}, {"title": "b"}, {"title": "c"}]`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "0123456789"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "0123456789a"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	assert.Equal(t, []string{RejectedTooLarge, RejectedTooManyItems, RejectedQuotaExceeded}, rejected)
+}
+
+func TestItemCreateQuotaDayBoundary(t *testing.T) {
+	s := store.NewMemory()
+	now := time.Date(2022, 10, 12, 23, 59, 59, 0, time.UTC)
+	var rejected []string
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{
+		MaxFeedBytesPerDay: 10,
+		OnRejected: func(reason string) {
+			rejected = append(rejected, reason)
+		},
+		now: func() time.Time { return now },
+	})
+
+	rec := postItems(t, handler, "/feeds/example/items", `{"title": "0123456789"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "items up to the limit should be accepted")
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "a"}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "daily limit of 10 bytes")
+
+	// other feeds have their own quota
+	rec = postItems(t, handler, "/feeds/other/items", `{"title": "a"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	now = now.Add(time.Second)
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "0123456789"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "the quota should reset on the next UTC day")
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "a"}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	assert.Equal(t, []string{RejectedQuotaExceeded, RejectedQuotaExceeded}, rejected)
+}
+
+func TestItemCreateScheduled(t *testing.T) {
+	s := store.NewMemory()
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{})
+
+	publishAt := time.Now().Add(time.Hour).Unix()
+	expiresAt := time.Now().Add(2 * time.Hour).Unix()
+
+	body := fmt.Sprintf(`{"title": "later", "publish_at": %d, "expires_at": %d}`, publishAt, expiresAt)
+	rec := postItems(t, handler, "/feeds/example/items", body)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	items, err := s.ListItems(context.Background(), "example", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, items, "scheduled items shouldn't be listed yet")
+
+	scheduled, err := s.ListScheduledItems(context.Background(), "example")
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, publishAt, scheduled[0].CreatedAt.Unix())
+	require.NotNil(t, scheduled[0].ExpiresAt)
+	assert.Equal(t, expiresAt, scheduled[0].ExpiresAt.Unix())
+}
+
+func TestItemCreateScheduledInvalid(t *testing.T) {
+	s := store.NewMemory()
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{})
+
+	testCases := map[string]struct {
+		body  string
+		field string
+	}{
+		"date and publish_at": {
+			body:  `{"title": "a", "date": 1665587045, "publish_at": 1665587045}`,
+			field: "publish_at",
+		},
+		"invalid publish_at": {
+			body:  `{"title": "a", "publish_at": "soon"}`,
+			field: "publish_at",
+		},
+		"expires in the past": {
+			body:  `{"title": "a", "expires_at": 1665587045}`,
+			field: "expires_at",
+		},
+		"expires before publishing": {
+			body: fmt.Sprintf(
+				`{"title": "a", "publish_at": %d, "expires_at": %d}`,
+				time.Now().Add(2*time.Hour).Unix(),
+				time.Now().Add(time.Hour).Unix(),
+			),
+			field: "expires_at",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := postItems(t, handler, "/feeds/example/items", tc.body)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var errs toolAPIs.ResponseErrors
+			err := json.NewDecoder(rec.Body).Decode(&errs)
+			require.NoError(t, err)
+			require.Len(t, errs.Errors, 1)
+			assert.Equal(t, tc.field, errs.Errors[0].Field)
+		})
+	}
+}
+
+func TestItemCreateExpiresAtHandlerTime(t *testing.T) {
+	s := store.NewMemory()
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{
+		now: func() time.Time { return now },
+	})
+
+	// expiry is checked against the handler's time, which items without a date are created at
+	rec := postItems(t, handler, "/feeds/example/items",
+		fmt.Sprintf(`{"title": "a", "expires_at": %d}`, now.Add(time.Minute).Unix()))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = postItems(t, handler, "/feeds/example/items",
+		fmt.Sprintf(`{"title": "b", "expires_at": %d}`, now.Add(-time.Minute).Unix()))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "expires_at must be after the item is published")
+}
+
+func TestItemCreatePriority(t *testing.T) {
+	s := store.NewMemory()
+	handler := BuildItemCreateHandler(s, ItemCreateOptions{})
+
+	rec := postItems(t, handler, "/feeds/example/items", `{"title": "freeze", "priority": "Urgent", "pinned": true}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	items, err := s.ListItems(context.Background(), "example", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, store.PriorityUrgent, items[0].Priority)
+	assert.True(t, items[0].Pinned)
+
+	rec = postItems(t, handler, "/feeds/example/items", `{"title": "freeze", "priority": "critical"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var errs toolAPIs.ResponseErrors
+	err = json.NewDecoder(rec.Body).Decode(&errs)
+	require.NoError(t, err)
+	require.Len(t, errs.Errors, 1)
+	assert.Equal(t, "priority", errs.Errors[0].Field)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// UserItemStore is the storage needed to list items along with a user's state for them
+type UserItemStore interface {
+	store.ItemStore
+	store.ItemStateStore
+}
+
+func itemStateResponse(state store.ItemState) toolAPIs.ResponseItemState {
+	return toolAPIs.ResponseItemState{
+		ItemID:    state.ItemID,
+		Feed:      state.Feed,
+		Read:      state.Read,
+		Starred:   state.Starred,
+		Archived:  state.Archived,
+		UpdatedAt: &state.UpdatedAt,
+	}
+}
+
+// BuildUserItemsHandler returns a handler which lists the published items in a feed, with the requesting user's
+// state for each. Requests with ?unread=true only list items the user hasn't read or archived. Pages are selected
+// with the limit and offset query params. Private feeds are only listed when one of their secrets is given with ?key=.
+func BuildUserItemsHandler(items UserItemStore, secrets store.SecretStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed := mux.Vars(r)["feed"]
+		if !feedRegex.MatchString(feed) {
+			WriteError(w, http.StatusBadRequest, "feed didn't match regex")
+			return
+		}
+
+		if !checkFeedReadable(w, r, secrets, feed) {
+			return
+		}
+
+		user := RequestUser(r)
+
+		opts := store.ListOptions{Limit: 50, PinnedFirst: true}
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			opts.Limit, err = strconv.Atoi(v)
+			if err != nil || opts.Limit < 1 || opts.Limit > 100 {
+				WriteError(w, http.StatusBadRequest, "limit must be a number between 1 and 100")
+				return
+			}
+		}
+		if v := r.URL.Query().Get("offset"); v != "" {
+			var err error
+			opts.Offset, err = strconv.Atoi(v)
+			if err != nil || opts.Offset < 0 {
+				WriteError(w, http.StatusBadRequest, "offset must be a positive number")
+				return
+			}
+		}
+		if r.URL.Query().Get("unread") == "true" {
+			opts.UnreadBy = user
+		}
+
+		feedItems, err := items.ListItems(r.Context(), feed, opts)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list items: %s", err)
+			return
+		}
+
+		var ids []int64
+		for _, item := range feedItems {
+			ids = append(ids, item.ID)
+		}
+
+		states, err := items.ListItemStates(r.Context(), user, feed, ids)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list item states: %s", err)
+			return
+		}
+
+		byID := make(map[int64]store.ItemState)
+		for _, state := range states {
+			byID[state.ItemID] = state
+		}
+
+		response := toolAPIs.ResponseUserItems{Items: []toolAPIs.ResponseUserItem{}}
+		for _, item := range feedItems {
+			state := byID[item.ID]
+			response.Items = append(response.Items, toolAPIs.ResponseUserItem{
+				ResponseItem: itemResponse(item),
+				Read:         state.Read,
+				Starred:      state.Starred,
+				Archived:     state.Archived,
+			})
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
+
+// BuildItemStateHandler returns a handler which marks an item read or unread, starred or archived for the requesting
+// user. Only the fields sent are changed. As when listing items, private feeds need one of their secrets.
+func BuildItemStateHandler(
+	states store.ItemStateStore,
+	secrets store.SecretStore,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		if !checkFeedReadable(w, r, secrets, vars["feed"]) {
+			return
+		}
+
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid item id")
+			return
+		}
+
+		var payload toolAPIs.PayloadItemState
+		err = json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "failed to parse JSON data: %s", err)
+			return
+		}
+
+		state, found, err := states.UpdateItemState(r.Context(), RequestUser(r), vars["feed"], id, store.ItemStateUpdate{
+			Read:     payload.Read,
+			Starred:  payload.Starred,
+			Archived: payload.Archived,
+		})
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to update item state: %s", err)
+			return
+		}
+		if !found {
+			WriteError(w, http.StatusNotFound, "item not found")
+			return
+		}
+
+		WriteJSON(w, http.StatusOK, itemStateResponse(state))
+	}
+}
+
+// BuildUnreadCountsHandler returns a handler which lists the number of items the requesting user hasn't read or
+// archived in each feed. Private feeds are left out, so their names aren't shown to users without a secret.
+func BuildUnreadCountsHandler(
+	states store.ItemStateStore,
+	secrets store.SecretStore,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		counts, err := states.UnreadCounts(r.Context(), RequestUser(r))
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to count unread items: %s", err)
+			return
+		}
+
+		response := toolAPIs.ResponseUnreadCounts{Feeds: []toolAPIs.ResponseUnreadCount{}}
+		for _, c := range counts {
+			private, err := secrets.FeedPrivate(r.Context(), c.Feed)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to load feed privacy: %s", err)
+				return
+			}
+			if private {
+				continue
+			}
+
+			response.Feeds = append(response.Feeds, toolAPIs.ResponseUnreadCount{Feed: c.Feed, Unread: c.Count})
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
+
+// checkFeedReadable writes a not found response when the feed is private and the request doesn't have one of its
+// secrets, returning false. User tokens don't grant access to private feeds.
+func checkFeedReadable(w http.ResponseWriter, r *http.Request, secrets store.SecretStore, feed string) bool {
+	readable, err := feedReadable(r.Context(), secrets, feed, r)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to check feed access: %s", err)
+		return false
+	}
+	if !readable {
+		WriteError(w, http.StatusNotFound, "feed not found")
+		return false
+	}
+
+	return true
+}
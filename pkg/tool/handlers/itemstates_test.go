@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestItemStates(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	base := time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC)
+	created, err := s.InsertItems(ctx, "incidents", []store.NewItem{
+		{GUID: "a", Title: "incident 1", CreatedAt: base},
+		{GUID: "b", Title: "incident 2", CreatedAt: base.Add(time.Minute)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	users := map[string]string{"alice-token": "alice", "bob-token": "bob"}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/unread", WithUser(users, BuildUnreadCountsHandler(s, s))).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/items", WithUser(users, BuildUserItemsHandler(s, s))).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/items/{id}/state", WithUser(users, BuildItemStateHandler(s, s))).Methods("PUT")
+	router.HandleFunc("/feeds/{feed}.rss", BuildFeedGetHandler(s, s, FeedGetOptions{Users: users})).Methods("GET")
+
+	request := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/unread", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/unread", "wrong", "").Code)
+
+	target := fmt.Sprintf("/feeds/incidents/items/%d/state", created[0].ID)
+	rec := request("PUT", target, "alice-token", `{"read": true, "starred": true}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var state toolAPIs.ResponseItemState
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&state))
+	assert.Equal(t, created[0].ID, state.ItemID)
+	assert.True(t, state.Read)
+	assert.True(t, state.Starred)
+	assert.False(t, state.Archived)
+
+	assert.Equal(t, http.StatusNotFound, request("PUT", "/feeds/incidents/items/999/state", "alice-token", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request("PUT", target, "alice-token", `not json`).Code)
+
+	unreadCounts := func(token string) []toolAPIs.ResponseUnreadCount {
+		rec := request("GET", "/unread", token, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var counts toolAPIs.ResponseUnreadCounts
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&counts))
+		return counts.Feeds
+	}
+
+	assert.Equal(t, []toolAPIs.ResponseUnreadCount{{Feed: "incidents", Unread: 1}}, unreadCounts("alice-token"))
+	assert.Equal(t, []toolAPIs.ResponseUnreadCount{{Feed: "incidents", Unread: 2}}, unreadCounts("bob-token"))
+
+	rec = request("GET", "/feeds/incidents/items", "alice-token", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var items toolAPIs.ResponseUserItems
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Len(t, items.Items, 2)
+	assert.Equal(t, "incident 2", items.Items[0].Title)
+	assert.False(t, items.Items[0].Read)
+	assert.Equal(t, "incident 1", items.Items[1].Title)
+	assert.True(t, items.Items[1].Read)
+	assert.True(t, items.Items[1].Starred)
+
+	rec = request("GET", "/feeds/incidents/items?unread=true", "alice-token", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	items = toolAPIs.ResponseUserItems{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Len(t, items.Items, 1)
+	assert.Equal(t, "incident 2", items.Items[0].Title)
+
+	assert.Equal(t, http.StatusBadRequest, request("GET", "/feeds/incidents/items?limit=0", "alice-token", "").Code)
+
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/feeds/incidents.rss?unread=true", "", "").Code)
+
+	rec = request("GET", "/feeds/incidents.rss?unread=true&user_token=alice-token", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "incident 2")
+	assert.NotContains(t, string(body), "incident 1")
+	assert.NotContains(t, string(body), "alice-token", "user tokens shouldn't be included in the feed")
+	assert.Contains(t, string(body), "<id>/feeds/incidents.rss</id>")
+	assert.Contains(t, string(body), fmt.Sprintf("<id>/feeds/incidents/items/%d</id>", created[1].ID),
+		"items should have the same ids as in the unfiltered feed")
+}
+
+func TestItemStatesPrivateFeeds(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	created, err := s.InsertItems(ctx, "private", []store.NewItem{{GUID: "a", Title: "secret incident"}}, store.InsertOptions{})
+	require.NoError(t, err)
+	_, err = s.InsertItems(ctx, "public", []store.NewItem{{GUID: "a", Title: "public incident"}}, store.InsertOptions{})
+	require.NoError(t, err)
+	_, err = s.CreateSecret(ctx, "private", "reader", hashSecret("s3cret"))
+	require.NoError(t, err)
+
+	users := map[string]string{"alice-token": "alice"}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/unread", WithUser(users, BuildUnreadCountsHandler(s, s))).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/items", WithUser(users, BuildUserItemsHandler(s, s))).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/items/{id}/state", WithUser(users, BuildItemStateHandler(s, s))).Methods("PUT")
+
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer alice-token")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("GET", "/unread", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var counts toolAPIs.ResponseUnreadCounts
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&counts))
+	assert.Equal(t, []toolAPIs.ResponseUnreadCount{{Feed: "public", Unread: 1}}, counts.Feeds,
+		"private feeds shouldn't be listed")
+
+	rec = request("GET", "/feeds/private/items", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret incident")
+
+	target := fmt.Sprintf("/feeds/private/items/%d/state", created[0].ID)
+	assert.Equal(t, http.StatusNotFound, request("PUT", target, `{"read": true}`).Code)
+
+	states, err := s.ListItemStates(ctx, "alice", "private", []int64{created[0].ID})
+	require.NoError(t, err)
+	assert.Empty(t, states, "states shouldn't be changed without the feed secret")
+
+	// the feed's secret grants access, as it does for the feed itself
+	rec = request("GET", "/feeds/private/items?key=s3cret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "secret incident")
+
+	assert.Equal(t, http.StatusOK, request("PUT", target+"?key=s3cret", `{"read": true}`).Code)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// BuildJobRunsHandler returns a handler listing the recent runs of each job, newest first. The job query parameter
+// limits the response to a single job and limit sets the number of runs listed per job.
+func BuildJobRunsHandler(runs store.JobRunStore, list func() []jobs.RunStatus) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > 100 {
+				WriteError(w, http.StatusBadRequest, "limit must be a number between 1 and 100")
+				return
+			}
+		}
+
+		job := r.URL.Query().Get("job")
+
+		response := toolAPIs.ResponseJobRuns{Jobs: []toolAPIs.ResponseJobHistory{}}
+		for _, status := range list() {
+			if job != "" && status.Name != job {
+				continue
+			}
+
+			jobRuns, err := runs.ListJobRuns(r.Context(), status.Name, limit)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to list job runs: %s", err)
+				return
+			}
+
+			history := toolAPIs.ResponseJobHistory{
+				Name:     status.Name,
+				Schedule: status.Schedule,
+				Runs:     []toolAPIs.ResponseJobRun{},
+			}
+			for _, run := range jobRuns {
+				history.Runs = append(history.Runs, toolAPIs.ResponseJobRun{
+					ID:              run.ID,
+					StartedAt:       run.StartedAt,
+					FinishedAt:      run.FinishedAt,
+					DurationSeconds: run.FinishedAt.Sub(run.StartedAt).Seconds(),
+					Outcome:         run.Outcome,
+					Error:           run.Error,
+					Stats:           run.Stats,
+				})
+			}
+
+			response.Jobs = append(response.Jobs, history)
+		}
+
+		if job != "" && len(response.Jobs) == 0 {
+			WriteError(w, http.StatusNotFound, "job not found")
+			return
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
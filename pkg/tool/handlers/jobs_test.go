@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+type statsTestJob struct {
+	err error
+}
+
+func (j *statsTestJob) Name() string { return "example" }
+func (j *statsTestJob) Run(ctx context.Context) error {
+	jobs.AddStat(ctx, "items_deleted", 2)
+	jobs.AddStat(ctx, "items_deleted", 1)
+	return j.err
+}
+func (j *statsTestJob) Timeout() time.Duration { return time.Second }
+func (j *statsTestJob) Schedule() string       { return "0 0 * * * *" }
+
+func TestJobRuns(t *testing.T) {
+	s := store.NewMemory()
+	tracker := &jobs.Tracker{Runs: s}
+
+	job := &statsTestJob{}
+	tracked := tracker.Track(job)
+	require.NoError(t, tracked.Run(context.Background()))
+
+	job.err = errors.New("failed to clean")
+	require.Error(t, tracked.Run(context.Background()))
+
+	handler := BuildJobRunsHandler(s, tracker.Statuses)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/api/v1/jobs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response toolAPIs.ResponseJobRuns
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Jobs, 1)
+	assert.Equal(t, "example", response.Jobs[0].Name)
+	assert.Equal(t, "0 0 * * * *", response.Jobs[0].Schedule)
+	require.Len(t, response.Jobs[0].Runs, 2)
+
+	failed := response.Jobs[0].Runs[0]
+	assert.Equal(t, store.JobRunFailure, failed.Outcome)
+	assert.Equal(t, "failed to clean", failed.Error)
+	assert.Equal(t, map[string]int64{"items_deleted": 3}, failed.Stats)
+
+	succeeded := response.Jobs[0].Runs[1]
+	assert.Equal(t, store.JobRunSuccess, succeeded.Outcome)
+	assert.Empty(t, succeeded.Error)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/api/v1/jobs?job=example&limit=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Jobs, 1)
+	assert.Len(t, response.Jobs[0].Runs, 1)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/api/v1/jobs?job=missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/api/v1/jobs?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// BuildOutboxListHandler returns a handler listing the newest fan-out deliveries with a status, dead deliveries
+// are listed by default
+func BuildOutboxListHandler(outbox store.OutboxStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = store.DeliveryDead
+		}
+		if status != store.DeliveryPending && status != store.DeliveryDelivered && status != store.DeliveryDead {
+			WriteError(w, http.StatusBadRequest, "status must be pending, delivered or dead")
+			return
+		}
+
+		deliveries, err := outbox.ListDeliveries(r.Context(), status, 100)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list deliveries: %s", err)
+			return
+		}
+
+		response := []toolAPIs.ResponseDelivery{}
+		for _, d := range deliveries {
+			response = append(response, toolAPIs.ResponseDelivery{
+				ID:            d.ID,
+				Target:        d.Target,
+				Feed:          d.Feed,
+				ItemID:        d.ItemID,
+				Status:        d.Status,
+				Attempts:      d.Attempts,
+				LastError:     d.LastError,
+				NextAttemptAt: d.NextAttemptAt,
+				CreatedAt:     d.CreatedAt,
+			})
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
+
+// BuildOutboxRetryHandler returns a handler which makes a dead delivery pending again
+func BuildOutboxRetryHandler(outbox store.OutboxStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid delivery id")
+			return
+		}
+
+		found, err := outbox.RequeueDelivery(r.Context(), id)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to retry delivery: %s", err)
+			return
+		}
+		if !found {
+			WriteError(w, http.StatusNotFound, "dead delivery not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// BuildItemPinHandler returns a handler which pins an item in a feed, or unpins it when pinned is false. Pinned
+// items are listed at the top of the feed and aren't removed when the feed is trimmed.
+func BuildItemPinHandler(items store.ItemStore, pinned bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid item id")
+			return
+		}
+
+		found, err := items.SetItemPinned(r.Context(), vars["feed"], id, pinned)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to update item: %s", err)
+			return
+		}
+		if !found {
+			WriteError(w, http.StatusNotFound, "item not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
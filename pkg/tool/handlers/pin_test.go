@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestItemPin(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	base := time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC)
+	created, err := s.InsertItems(ctx, "deploys", []store.NewItem{
+		{GUID: "a", Title: "freeze in effect", CreatedAt: base},
+		{GUID: "b", Title: "deploy", CreatedAt: base.Add(time.Minute)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}/items/{id}/pin", BuildItemPinHandler(s, true)).Methods("PUT")
+	router.HandleFunc("/feeds/{feed}/items/{id}/pin", BuildItemPinHandler(s, false)).Methods("DELETE")
+
+	request := func(method, feed string, id int64) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, fmt.Sprintf("/feeds/%s/items/%d/pin", feed, id), nil))
+		return rec.Code
+	}
+
+	titles := func() []string {
+		rendered, err := RenderFeed(ctx, s, "deploys", "/feeds/deploys.rss", FeedLinks{})
+		require.NoError(t, err)
+
+		var titles []string
+		for _, line := range strings.Split(rendered, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "<title>") && line != "<title>deploys</title>" {
+				titles = append(titles, line)
+			}
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"<title>deploy</title>", "<title>freeze in effect</title>"}, titles())
+
+	assert.Equal(t, http.StatusNotFound, request("PUT", "other", created[0].ID))
+	assert.Equal(t, http.StatusNoContent, request("PUT", "deploys", created[0].ID))
+	assert.Equal(t, []string{"<title>freeze in effect</title>", "<title>deploy</title>"}, titles())
+
+	assert.Equal(t, http.StatusNoContent, request("DELETE", "deploys", created[0].ID))
+	assert.Equal(t, []string{"<title>deploy</title>", "<title>freeze in effect</title>"}, titles())
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// PingOptions configures the ping handler
+type PingOptions struct {
+	// Heartbeats are the checks which can be pinged, by name
+	Heartbeats []jobs.Heartbeat
+	// Monitor records checks going up and down, it's optional
+	Monitor *jobs.HeartbeatMonitor
+
+	// MaxBodyBytes limits the log output kept from a ping's body, longer bodies are truncated. Defaults to 10KiB.
+	MaxBodyBytes int64
+	// MaxFeedBytesPerDay limits the total bytes of items accepted by each feed per UTC day, 0 disables the limit.
+	// Pings count towards the same quota as items created in the heartbeat's feed.
+	MaxFeedBytesPerDay int64
+	// RateLimits are applied as they are to item creation, with pings counted against their heartbeat's feed
+	RateLimits RateLimitOptions
+
+	// OnCreated is called with the ping items after they have been stored
+	OnCreated []func(ctx context.Context, feed string, items []store.Item)
+	// OnRejected is called with RejectedQuotaExceeded when a ping is over its feed's quota, it's used for metrics
+	OnRejected func(reason string)
+}
+
+// BuildPingHandler returns a handler for cron jobs and other scheduled tasks to report on their runs, in the style
+// of healthchecks.io clients. Each ping is recorded as an item in the check's heartbeat feed, with the request body
+// as log output. The signal path variable may be start or fail, a ping without one reports a success. The duration
+// of a run is reported when it follows a start ping. Pings are subject to the same quota and rate limits as items
+// created in the heartbeat's feed.
+func BuildPingHandler(items store.ItemStore, opts PingOptions) func(http.ResponseWriter, *http.Request) {
+	heartbeats := make(map[string]jobs.Heartbeat)
+	for _, h := range opts.Heartbeats {
+		heartbeats[h.Name] = h
+	}
+
+	maxBodyBytes := opts.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = 10 * 1024
+	}
+
+	rateLimits := opts.RateLimits
+	rateLimits.FeedFor = func(r *http.Request) string {
+		return heartbeats[mux.Vars(r)["check"]].Feed
+	}
+
+	return WithRateLimits(rateLimits, func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["check"]
+		heartbeat, ok := heartbeats[name]
+		if !ok {
+			WriteError(w, http.StatusNotFound, "check not found")
+			return
+		}
+
+		signal := mux.Vars(r)["signal"]
+		if signal == "" {
+			signal = jobs.PingSuccess
+		}
+		if signal != jobs.PingSuccess && signal != jobs.PingStart && signal != jobs.PingFail {
+			WriteError(w, http.StatusNotFound, "unknown ping signal")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "failed to read body: %s", err)
+			return
+		}
+
+		now := time.Now()
+		title := fmt.Sprintf("%s started", name)
+
+		if signal != jobs.PingStart {
+			title = fmt.Sprintf("%s succeeded", name)
+			if signal == jobs.PingFail {
+				title = fmt.Sprintf("%s failed", name)
+			}
+
+			// checks may share a feed, so the last ping is the newest one for this check
+			last, found, err := heartbeat.LastPing(r.Context(), items)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to load last ping: %s", err)
+				return
+			}
+			if found && last.Tags.Has(jobs.PingStart) {
+				title = fmt.Sprintf("%s after %s", title, now.Sub(last.CreatedAt).Round(time.Second))
+			}
+		}
+
+		guid, err := NewGUID()
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to generate item guid")
+			return
+		}
+
+		created, err := items.InsertItems(r.Context(), heartbeat.Feed, []store.NewItem{{
+			GUID:      guid,
+			Title:     title,
+			Body:      strings.TrimSpace(string(body)),
+			Tags:      jobs.PingTags(name, signal),
+			CreatedAt: now,
+		}}, store.InsertOptions{MaxFeedBytesPerDay: opts.MaxFeedBytesPerDay, Now: now})
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			if opts.OnRejected != nil {
+				opts.OnRejected(RejectedQuotaExceeded)
+			}
+			WriteError(
+				w,
+				http.StatusTooManyRequests,
+				"feed %s has exceeded its daily limit of %d bytes",
+				heartbeat.Feed,
+				opts.MaxFeedBytesPerDay,
+			)
+			return
+		}
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to record ping: %s", err)
+			return
+		}
+
+		for _, fn := range opts.OnCreated {
+			fn(r.Context(), heartbeat.Feed, created)
+		}
+
+		if opts.Monitor != nil && signal != jobs.PingStart {
+			reason := fmt.Sprintf("%s reported a success", name)
+			if signal == jobs.PingFail {
+				reason = fmt.Sprintf("%s reported a failure", name)
+			}
+
+			// the ping has been recorded, so a failure to record the change isn't reported to the client. The
+			// deadman-check job will record it on its next run.
+			err = opts.Monitor.Update(r.Context(), name, signal == jobs.PingSuccess, reason)
+			if err != nil {
+				log.Printf("failed to update heartbeat %s: %s", name, err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK\n"))
+	})
+}
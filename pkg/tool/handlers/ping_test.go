@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/ratelimit"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestPing(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	heartbeat := jobs.Heartbeat{Name: "backups", Feed: "backups-heartbeat", Schedule: "0 3 * * *", Grace: time.Hour}
+
+	var notified []string
+	handler := BuildPingHandler(s, PingOptions{
+		Heartbeats: []jobs.Heartbeat{heartbeat},
+		Monitor:    &jobs.HeartbeatMonitor{Items: s, States: s, Feed: "heartbeats"},
+		OnCreated: []func(ctx context.Context, feed string, items []store.Item){
+			func(ctx context.Context, feed string, items []store.Item) {
+				notified = append(notified, feed)
+			},
+		},
+	})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ping/{check}", handler).Methods("GET", "POST")
+	router.HandleFunc("/ping/{check}/{signal:start|fail}", handler).Methods("GET", "POST")
+
+	ping := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := ping("GET", "/ping/missing", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = ping("GET", "/ping/backups/start", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "OK\n", rec.Body.String())
+
+	status, err := heartbeat.Check(ctx, s)
+	require.NoError(t, err)
+	assert.Nil(t, status.LastBeatAt, "start pings should not count as beats")
+
+	rec = ping("POST", "/ping/backups", "copied 12 files\n")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	items, err := s.ListItems(ctx, "backups-heartbeat", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.True(t, strings.HasPrefix(items[0].Title, "backups succeeded after "), items[0].Title)
+	assert.Equal(t, "copied 12 files", items[0].Body)
+	assert.Equal(t, store.Tags{jobs.PingTag, jobs.PingSuccess, "backups"}, items[0].Tags)
+	assert.Equal(t, "backups started", items[1].Title)
+
+	status, err = heartbeat.Check(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, status.Healthy(), "the next beat isn't due until 3am")
+
+	rec = ping("POST", "/ping/backups/fail", "disk full")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	status, err = heartbeat.Check(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, status.Failed)
+	assert.Equal(t, "backups reported a failure", status.Reason())
+
+	changes, err := s.ListItems(ctx, "heartbeats", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "backups is down", changes[0].Title)
+	assert.Equal(t, store.Tags{"backups", store.HeartbeatDown}, changes[0].Tags)
+	assert.Equal(t, "backups is up", changes[1].Title)
+
+	assert.Equal(t, []string{
+		"backups-heartbeat", "backups-heartbeat", "backups-heartbeat",
+	}, notified, "monitor items use the monitor's own hooks")
+}
+
+func TestPingSharedFeed(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	backups := jobs.Heartbeat{Name: "backups", Feed: "cron", Interval: time.Hour}
+	reports := jobs.Heartbeat{Name: "reports", Feed: "cron", Interval: time.Hour}
+
+	handler := BuildPingHandler(s, PingOptions{Heartbeats: []jobs.Heartbeat{backups, reports}})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ping/{check}", handler).Methods("GET", "POST")
+	router.HandleFunc("/ping/{check}/{signal:start|fail}", handler).Methods("GET", "POST")
+
+	ping := func(path string) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	ping("/ping/backups/start")
+	ping("/ping/reports")
+
+	// another check's ping doesn't count for this one
+	status, err := backups.Check(ctx, s)
+	require.NoError(t, err)
+	assert.Nil(t, status.LastBeatAt)
+
+	status, err = reports.Check(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, status.Healthy())
+
+	// the duration is reported from this check's start, past other checks' pings
+	ping("/ping/backups/fail")
+	items, err := s.ListItems(ctx, "cron", store.ListOptions{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.True(t, strings.HasPrefix(items[0].Title, "backups failed after "), items[0].Title)
+
+	status, err = backups.Check(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, status.Failed)
+
+	status, err = reports.Check(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, status.Healthy(), "another check's failure shouldn't fail this one")
+}
+
+func TestPingLimits(t *testing.T) {
+	s := store.NewMemory()
+
+	heartbeats := []jobs.Heartbeat{
+		{Name: "backups", Feed: "cron", Interval: time.Hour},
+		{Name: "reports", Feed: "cron", Interval: time.Hour},
+		{Name: "builds", Feed: "builds", Interval: time.Hour},
+	}
+
+	var rejected []string
+	handler := BuildPingHandler(s, PingOptions{
+		Heartbeats:         heartbeats,
+		MaxFeedBytesPerDay: 40,
+		RateLimits: RateLimitOptions{
+			Feed:       ratelimit.NewMemory(ratelimit.Rate{PerMinute: 1, Burst: 2}),
+			OnRejected: func(reason string) { rejected = append(rejected, reason) },
+		},
+		OnRejected: func(reason string) { rejected = append(rejected, reason) },
+	})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ping/{check}", handler).Methods("GET", "POST")
+
+	ping := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := ping("/ping/backups", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// pings are limited by the heartbeat's feed, which checks sharing it use up together
+	rec = ping("/ping/reports", strings.Repeat("a", 40))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "daily limit of 40 bytes")
+
+	rec = ping("/ping/reports", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	rec = ping("/ping/builds", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, []string{RejectedQuotaExceeded, RejectedRateLimited}, rejected)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/ratelimit"
+)
+
+// RateLimitOptions configures the rate limits applied by WithRateLimits. Nil limiters are not applied.
+type RateLimitOptions struct {
+	// Feed is keyed by the feed name from the request path
+	Feed ratelimit.Limiter
+	// Client is keyed by the client IP
+	Client ratelimit.Limiter
+
+	// TrustForwardedFor uses the last address in X-Forwarded-For as the client IP, this should only be set when
+	// running behind a single proxy which appends to the header.
+	TrustForwardedFor bool
+
+	// OnRejected is called with RejectedRateLimited when a request is over its limit, it's used for metrics
+	OnRejected func(reason string)
+
+	// FeedFor returns the feed a request adds items to, it defaults to the feed path variable
+	FeedFor func(r *http.Request) string
+}
+
+// WithRateLimits wraps a handler, responding with a 429 and Retry-After when a request exceeds its feed or client
+// rate limit. Both limits are checked before a token is taken from either, so rejected requests don't use up the
+// other limit.
+func WithRateLimits(opts RateLimitOptions, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var checks []rateLimitCheck
+		if opts.Feed != nil {
+			feed := mux.Vars(r)["feed"]
+			if opts.FeedFor != nil {
+				feed = opts.FeedFor(r)
+			}
+			checks = append(checks, rateLimitCheck{limiter: opts.Feed, key: "feed:" + feed})
+		}
+		if opts.Client != nil {
+			checks = append(checks, rateLimitCheck{limiter: opts.Client, key: "client:" + clientKey(r, opts.TrustForwardedFor)})
+		}
+
+		for _, take := range []bool{false, true} {
+			for _, check := range checks {
+				allowed, wait, err := check.run(r, take)
+				if err != nil {
+					WriteError(w, http.StatusInternalServerError, "failed to check rate limit: %s", err)
+					return
+				}
+
+				// a concurrent request may take the last token between the checks, in which case tokens already
+				// taken for this request are spent
+				if !allowed {
+					retryAfter := int(math.Ceil(wait.Seconds()))
+					if retryAfter < 1 {
+						retryAfter = 1
+					}
+					if opts.OnRejected != nil {
+						opts.OnRejected(RejectedRateLimited)
+					}
+					w.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+					WriteError(w, http.StatusTooManyRequests, "rate limit exceeded, retry in %ds", retryAfter)
+					return
+				}
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+type rateLimitCheck struct {
+	limiter ratelimit.Limiter
+	key     string
+}
+
+// run checks if the request is within the limit, taking a token when take is set
+func (c rateLimitCheck) run(r *http.Request, take bool) (bool, time.Duration, error) {
+	if take {
+		return c.limiter.Allow(r.Context(), c.key)
+	}
+
+	return c.limiter.Peek(r.Context(), c.key)
+}
+
+// clientKey identifies the client making a request by its IP. Headers such as Authorization aren't used, as
+// clients could send a different value with each request to get a new bucket.
+func clientKey(r *http.Request, trustForwardedFor bool) string {
+	// proxies append the address they received the request from, so only the last address is set by the proxy and
+	// the rest can be sent by the client
+	if trustForwardedFor {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			addresses := strings.Split(values[len(values)-1], ",")
+			if last := strings.TrimSpace(addresses[len(addresses)-1]); last != "" {
+				return "ip:" + last
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/ratelimit"
+)
+
+func TestWithRateLimits(t *testing.T) {
+	var rejected []string
+	opts := RateLimitOptions{
+		Feed:       ratelimit.NewMemory(ratelimit.Rate{PerMinute: 1, Burst: 2}),
+		Client:     ratelimit.NewMemory(ratelimit.Rate{PerMinute: 1, Burst: 1}),
+		OnRejected: func(reason string) { rejected = append(rejected, reason) },
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}/items", WithRateLimits(opts, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})).Methods("POST")
+
+	post := func(feed, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/feeds/"+feed+"/items", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("example", "192.0.2.1:1234", nil)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// the client's bucket is empty, other ports and auth headers don't get a new one
+	rec = post("example", "192.0.2.1:5678", map[string]string{"Authorization": "Bearer made-up"})
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, []string{RejectedRateLimited}, rejected)
+
+	// the rejected request didn't take a token from the feed, so another client can still use it
+	rec = post("example", "192.0.2.2:1234", nil)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// now the feed's bucket is empty too
+	rec = post("example", "192.0.2.3:1234", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// the feed check rejected the request before the client's token was taken
+	rec = post("other", "192.0.2.3:1234", nil)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestClientKey(t *testing.T) {
+	testCases := map[string]struct {
+		trustForwardedFor bool
+		forwardedFor      []string
+		expected          string
+	}{
+		"remote address": {
+			forwardedFor: []string{"198.51.100.1"},
+			expected:     "ip:192.0.2.1",
+		},
+		"single forwarded address": {
+			trustForwardedFor: true,
+			forwardedFor:      []string{"198.51.100.1"},
+			expected:          "ip:198.51.100.1",
+		},
+		"client sent forwarded address": {
+			trustForwardedFor: true,
+			forwardedFor:      []string{"203.0.113.9, 198.51.100.1"},
+			expected:          "ip:198.51.100.1",
+		},
+		"client sent forwarded header": {
+			trustForwardedFor: true,
+			forwardedFor:      []string{"203.0.113.9", "198.51.100.1"},
+			expected:          "ip:198.51.100.1",
+		},
+		"empty forwarded address": {
+			trustForwardedFor: true,
+			forwardedFor:      []string{"203.0.113.9,"},
+			expected:          "ip:192.0.2.1",
+		},
+		"no forwarded header": {
+			trustForwardedFor: true,
+			expected:          "ip:192.0.2.1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/feeds/example/items", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, v := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, tc.expected, clientKey(req, tc.trustForwardedFor))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// FeedLinks are additional links included in rendered feeds, these are used for WebSub discovery
+type FeedLinks struct {
+	Hub  string
+	Self string
+
+	// Key is the read secret the feed was requested with, it's added to attachment links so that they can be
+	// fetched by readers of private feeds
+	Key string
+}
+
+// FeedStore is the storage needed to render feeds
+type FeedStore interface {
+	store.ItemStore
+	store.AttachmentStore
+}
+
+// UserFeedStore is the storage needed to render feeds with a user's item states
+type UserFeedStore interface {
+	FeedStore
+	store.ItemStateStore
+}
+
+// atomFeedWithLinks adds links to an Atom feed, gorilla/feeds only supports a single feed level link
+type atomFeedWithLinks struct {
+	*feeds.AtomFeed
+	Links []feeds.AtomLink
+}
+
+func (a *atomFeedWithLinks) FeedXml() interface{} {
+	return a
+}
+
+// RenderFeed renders the newest published items in a feed as Atom, after any pinned items. feedURL is used as the
+// feed's link and the base of attachment links. Ids only use its path, so that feeds pushed to WebSub subscribers
+// with an absolute feedURL have the same ids as those polled by readers. Item attachments are linked as enclosures.
+func RenderFeed(ctx context.Context, items FeedStore, feed, feedURL string, links FeedLinks) (string, error) {
+	return renderFeed(ctx, items, feed, feedURL, links, "")
+}
+
+// renderFeed renders a feed like RenderFeed, leaving out items read or archived by unreadBy when it's set
+func renderFeed(
+	ctx context.Context,
+	items FeedStore,
+	feed, feedURL string,
+	links FeedLinks,
+	unreadBy string,
+) (string, error) {
+	responseFeed := &feeds.Feed{
+		Title:       feed,
+		Link:        &feeds.Link{Href: feedURL},
+		Description: fmt.Sprintf("webhook-rss feed %q", feed),
+		Created:     time.Now(),
+	}
+
+	feedItems, err := items.ListItems(ctx, feed, store.ListOptions{Limit: 50, PinnedFirst: true, UnreadBy: unreadBy})
+	if err != nil {
+		return "", fmt.Errorf("failed to list items: %w", err)
+	}
+
+	// pinned items are listed first, so the newest item isn't necessarily the first
+	for i, item := range feedItems {
+		if i == 0 || item.CreatedAt.After(responseFeed.Created) {
+			responseFeed.Created = item.CreatedAt
+		}
+	}
+
+	itemsURL := strings.TrimSuffix(feedURL, ".rss") + "/items"
+
+	feedID := feedURL
+	if u, err := url.Parse(feedURL); err == nil {
+		feedID = u.Path
+	}
+	itemsID := strings.TrimSuffix(feedID, ".rss") + "/items"
+
+	var ids []int64
+	for _, item := range feedItems {
+		ids = append(ids, item.ID)
+	}
+
+	attachments, err := items.ListAttachments(ctx, feed, ids)
+	if err != nil {
+		return "", fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	enclosures := make(map[int64][]feeds.AtomLink)
+	for _, a := range attachments {
+		href := fmt.Sprintf("%s/%d/attachments/%d", itemsURL, a.ItemID, a.ID)
+		if links.Key != "" {
+			href += "?key=" + url.QueryEscape(links.Key)
+		}
+
+		enclosures[a.ItemID] = append(enclosures[a.ItemID], feeds.AtomLink{
+			Href:   href,
+			Rel:    "enclosure",
+			Type:   a.ContentType,
+			Length: fmt.Sprint(a.Size),
+		})
+	}
+
+	for _, item := range feedItems {
+		responseFeed.Items = append(responseFeed.Items,
+			&feeds.Item{
+				Id:          fmt.Sprintf("%s/%d", itemsID, item.ID),
+				Title:       item.Title,
+				Link:        &feeds.Link{Href: item.URL},
+				Description: item.Body,
+				Created:     item.CreatedAt,
+			})
+	}
+
+	atomFeed := &atomFeedWithLinks{
+		AtomFeed: (&feeds.Atom{Feed: responseFeed}).AtomFeed(),
+	}
+	atomFeed.Id = feedID
+	// gorilla/feeds only supports a single enclosure per item, so they're added to the converted entries
+	for i, entry := range atomFeed.Entries {
+		entry.Links = append(entry.Links, enclosures[feedItems[i].ID]...)
+	}
+	if links.Hub != "" {
+		atomFeed.Links = append(atomFeed.Links, feeds.AtomLink{Href: links.Hub, Rel: "hub"})
+	}
+	if links.Self != "" {
+		atomFeed.Links = append(atomFeed.Links, feeds.AtomLink{Href: links.Self, Rel: "self"})
+	}
+
+	return feeds.ToXML(atomFeed)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// BuildScheduledListHandler returns a handler which lists the items in a feed waiting to be published, soonest
+// first
+func BuildScheduledListHandler(items store.ItemStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed := mux.Vars(r)["feed"]
+		if !feedRegex.MatchString(feed) {
+			WriteError(w, http.StatusBadRequest, "feed didn't match regex")
+			return
+		}
+
+		scheduled, err := items.ListScheduledItems(r.Context(), feed)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list scheduled items: %s", err)
+			return
+		}
+
+		response := []toolAPIs.ResponseItem{}
+		for _, item := range scheduled {
+			response = append(response, itemResponse(item))
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
+
+// BuildScheduledCancelHandler returns a handler which cancels a scheduled item before it's published. Items which
+// have already been published can't be removed this way.
+func BuildScheduledCancelHandler(items store.ItemStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid item id")
+			return
+		}
+
+		scheduled, err := items.ListScheduledItems(r.Context(), vars["feed"])
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list scheduled items: %s", err)
+			return
+		}
+
+		found := false
+		for _, item := range scheduled {
+			if item.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			WriteError(w, http.StatusNotFound, "scheduled item not found")
+			return
+		}
+
+		_, err = items.DeleteItems(r.Context(), vars["feed"], []int64{id})
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to cancel scheduled item: %s", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
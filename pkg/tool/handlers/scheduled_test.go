@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestScheduledItems(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	_, err := s.InsertItems(ctx, "example", []store.NewItem{
+		{GUID: "a", Title: "published"},
+		{GUID: "b", Title: "later", CreatedAt: time.Now().Add(2 * time.Hour)},
+		{GUID: "c", Title: "sooner", CreatedAt: time.Now().Add(time.Hour)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds/{feed}/scheduled", BuildScheduledListHandler(s)).Methods("GET")
+	router.HandleFunc("/feeds/{feed}/scheduled/{id}", BuildScheduledCancelHandler(s)).Methods("DELETE")
+
+	list := func() []toolAPIs.ResponseItem {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/feeds/example/scheduled", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response []toolAPIs.ResponseItem
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		return response
+	}
+
+	scheduled := list()
+	require.Len(t, scheduled, 2)
+	assert.Equal(t, "sooner", scheduled[0].Title)
+	assert.Equal(t, "later", scheduled[1].Title)
+
+	published, err := s.ListItems(ctx, "example", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+
+	cancel := func(id int64) int {
+		rec := httptest.NewRecorder()
+		target := "/feeds/example/scheduled/" + strconv.FormatInt(id, 10)
+		router.ServeHTTP(rec, httptest.NewRequest("DELETE", target, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, cancel(published[0].ID), "published items can't be cancelled")
+	assert.Equal(t, http.StatusNoContent, cancel(scheduled[0].ID))
+
+	scheduled = list()
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, "later", scheduled[0].Title)
+}
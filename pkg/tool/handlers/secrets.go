@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func secretResponse(s store.Secret) toolAPIs.ResponseSecret {
+	return toolAPIs.ResponseSecret{
+		ID:        s.ID,
+		Feed:      s.Feed,
+		Label:     s.Label,
+		CreatedAt: s.CreatedAt,
+		RevokedAt: s.RevokedAt,
+	}
+}
+
+// BuildSecretCreateHandler returns a handler which issues a new read secret for a feed. The secret is only
+// returned in this response, only its hash is stored.
+func BuildSecretCreateHandler(secrets store.SecretStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed := mux.Vars(r)["feed"]
+		if !feedRegex.MatchString(feed) {
+			WriteError(w, http.StatusBadRequest, "feed didn't match regex")
+			return
+		}
+
+		var payload toolAPIs.PayloadNewSecret
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "failed to parse JSON data: %s", err)
+				return
+			}
+		}
+
+		secret, err := newSecret()
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to generate secret")
+			return
+		}
+
+		stored, err := secrets.CreateSecret(r.Context(), feed, payload.Label, hashSecret(secret))
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to store secret: %s", err)
+			return
+		}
+
+		response := secretResponse(stored)
+		response.Secret = secret
+
+		WriteJSON(w, http.StatusCreated, response)
+	}
+}
+
+// BuildSecretListHandler returns a handler which lists the secrets issued for a feed, including revoked ones
+func BuildSecretListHandler(secrets store.SecretStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed := mux.Vars(r)["feed"]
+
+		stored, err := secrets.ListSecrets(r.Context(), feed)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list secrets: %s", err)
+			return
+		}
+
+		response := []toolAPIs.ResponseSecret{}
+		for _, s := range stored {
+			response = append(response, secretResponse(s))
+		}
+
+		WriteJSON(w, http.StatusOK, response)
+	}
+}
+
+// BuildSecretRevokeHandler returns a handler which revokes a secret, readers using it will no longer have access
+func BuildSecretRevokeHandler(secrets store.SecretStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid secret id")
+			return
+		}
+
+		found, err := secrets.RevokeSecret(r.Context(), vars["feed"], id)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to revoke secret: %s", err)
+			return
+		}
+		if !found {
+			WriteError(w, http.StatusNotFound, "secret not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildFeedPrivateHandler returns a handler which makes a feed private, or public when private is false. Feeds are
+// made private when their first secret is issued, and stay private until they're made public here.
+func BuildFeedPrivateHandler(secrets store.SecretStore, private bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed := mux.Vars(r)["feed"]
+		if !feedRegex.MatchString(feed) {
+			WriteError(w, http.StatusBadRequest, "feed didn't match regex")
+			return
+		}
+
+		err := secrets.SetFeedPrivate(r.Context(), feed, private)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to update feed: %s", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// feedReadable checks if the request may read a feed. Public feeds can be read by anyone, for private feeds a
+// secret must be provided in the key query param, the key path segment or as the HTTP basic auth password.
+func feedReadable(ctx context.Context, secrets store.SecretStore, feed string, r *http.Request) (bool, error) {
+	var candidates []string
+	if key := r.URL.Query().Get("key"); key != "" {
+		candidates = append(candidates, key)
+	}
+	if key := mux.Vars(r)["key"]; key != "" {
+		candidates = append(candidates, key)
+	}
+	if _, password, ok := r.BasicAuth(); ok && password != "" {
+		candidates = append(candidates, password)
+	}
+
+	return secretMatches(ctx, secrets, feed, candidates)
+}
+
+// secretMatches returns true when the feed is public, or when any of the candidates is an active secret. Private
+// feeds without active secrets can't be read.
+func secretMatches(ctx context.Context, secrets store.SecretStore, feed string, candidates []string) (bool, error) {
+	private, err := secrets.FeedPrivate(ctx, feed)
+	if err != nil {
+		return false, fmt.Errorf("failed to load feed privacy: %w", err)
+	}
+	if !private {
+		return true, nil
+	}
+
+	hashes, err := secrets.ActiveSecretHashes(ctx, feed)
+	if err != nil {
+		return false, fmt.Errorf("failed to load feed secrets: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		candidateHash := []byte(hashSecret(candidate))
+		for _, hash := range hashes {
+			if subtle.ConstantTimeCompare(candidateHash, []byte(hash)) == 1 {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,5 +1,75 @@
 package handlers
 
-import "regexp"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	toolAPIs "github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
 
 var feedRegex = regexp.MustCompile(`^\w+(\w-)*\w+$`)
+
+// ValidFeed returns true when feed is a valid feed name, it's used to check feeds named outside of HTTP requests
+func ValidFeed(feed string) bool {
+	return feedRegex.MatchString(feed)
+}
+
+// tagRegex matches valid item tags, after they have been lowercased
+var tagRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,49}$`)
+
+// ValidTag returns true when tag is a valid item tag, tags must be lowercased before they're checked
+func ValidTag(tag string) bool {
+	return tagRegex.MatchString(tag)
+}
+
+// WriteJSON writes v as the JSON response body with the given status
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes a request level error, i.e. one not relating to a particular item
+func WriteError(w http.ResponseWriter, status int, format string, a ...any) {
+	WriteJSON(w, status, toolAPIs.ResponseErrors{
+		Errors: []toolAPIs.ResponseError{{Reason: fmt.Sprintf(format, a...)}},
+	})
+}
+
+// NewGUID returns a random, UUID formatted identifier for a new item
+func NewGUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	// set the version (4) and variant bits
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := hex.EncodeToString(b)
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32]), nil
+}
+
+// itemResponse describes an item in API responses and event streams
+func itemResponse(item store.Item) toolAPIs.ResponseItem {
+	return toolAPIs.ResponseItem{
+		ID:        item.ID,
+		GUID:      item.GUID,
+		Title:     item.Title,
+		Body:      item.Body,
+		URL:       item.URL,
+		Tags:      item.Tags,
+		CreatedAt: item.CreatedAt,
+		ExpiresAt: item.ExpiresAt,
+		Priority:  string(item.Priority),
+		Pinned:    item.Pinned,
+	}
+}
@@ -0,0 +1,50 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// readinessChecks returns the checks run by the readiness endpoint. The memory backend has nothing to check.
+func (d *WebhookRSS) readinessChecks() map[string]handlers.ReadinessCheck {
+	checks := make(map[string]handlers.ReadinessCheck)
+
+	s, ok := d.store.(*store.SQL)
+	if !ok {
+		return checks
+	}
+
+	checks["storage"] = s.Ping
+	checks["migrations"] = func(ctx context.Context) error {
+		var latest uint
+		var err error
+		switch d.storageBackend() {
+		case "postgres":
+			latest, err = store.LatestMigration(webhookRSSToolMigrations, "migrations")
+		case "sqlite":
+			latest, err = store.LatestSQLiteMigration()
+		}
+		if err != nil {
+			return err
+		}
+
+		version, dirty, err := s.MigrationVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed and must be fixed by hand", version)
+		}
+		// a newer schema is allowed, so that a release can be rolled back without its migrations
+		if version < latest {
+			return fmt.Errorf("at migration %d, expected %d", version, latest)
+		}
+
+		return nil
+	}
+
+	return checks
+}
@@ -0,0 +1,188 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/events"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/fanout"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/metrics"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// eventBroadcaster returns the broadcaster used to notify event stream clients of new items. When configured, it
+// also receives notifications from other replicas using postgres.
+func (d *WebhookRSS) eventBroadcaster() (*events.Broadcaster, error) {
+	if d.broadcaster != nil {
+		return d.broadcaster, nil
+	}
+
+	broadcaster := events.NewBroadcaster()
+
+	listenDSN, err := d.optionalString("events.postgres.dsn", "")
+	if err != nil {
+		return nil, err
+	}
+	if listenDSN != "" {
+		if d.storageBackend() != "postgres" {
+			return nil, fmt.Errorf("events.postgres.dsn requires the postgres storage backend")
+		}
+
+		// new items are announced by the store using postgres notifications, which reach every replica
+		err = events.ListenPostgres(context.Background(), listenDSN, broadcaster)
+		if err != nil {
+			return nil, err
+		}
+		d.postgresEvents = true
+	}
+
+	d.broadcaster = broadcaster
+
+	return d.broadcaster, nil
+}
+
+// toolMetrics returns the metrics shared by the handlers and jobs
+func (d *WebhookRSS) toolMetrics() *metrics.Metrics {
+	if d.metrics == nil {
+		d.metrics = metrics.New(d.store, d.store)
+	}
+
+	return d.metrics
+}
+
+// jobTracker returns the tracker recording the runs of each job, for the status endpoint and job history
+func (d *WebhookRSS) jobTracker() *jobs.Tracker {
+	if d.tracker == nil {
+		d.tracker = &jobs.Tracker{Runs: d.store}
+	}
+
+	return d.tracker
+}
+
+// itemCreatedHooks returns the functions to call after items are created, these are shared by the item create
+// handler and jobs which create items. Items which are already published are passed on to the published hooks,
+// scheduled items are passed on by the publish job once they're published.
+func (d *WebhookRSS) itemCreatedHooks() ([]func(ctx context.Context, feed string, items []store.Item), error) {
+	if d.hooks != nil {
+		return d.hooks, nil
+	}
+
+	publishedHooks, err := d.itemPublishedHooks()
+	if err != nil {
+		return nil, err
+	}
+
+	d.hooks = []func(ctx context.Context, feed string, items []store.Item){
+		d.toolMetrics().ItemsCreated,
+		func(ctx context.Context, feed string, items []store.Item) {
+			var published []store.Item
+			for _, item := range items {
+				if item.Announced {
+					published = append(published, item)
+				}
+			}
+			if len(published) == 0 {
+				return
+			}
+
+			for _, fn := range publishedHooks {
+				fn(ctx, feed, published)
+			}
+		},
+	}
+
+	return d.hooks, nil
+}
+
+// itemPublishedHooks returns the functions to call when items are published, this is when they're created for most
+// items and when the publish job claims them for scheduled items
+func (d *WebhookRSS) itemPublishedHooks() ([]func(ctx context.Context, feed string, items []store.Item), error) {
+	if d.publishedHooks != nil {
+		return d.publishedHooks, nil
+	}
+
+	hooks := []func(ctx context.Context, feed string, items []store.Item){}
+
+	broadcaster, err := d.eventBroadcaster()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events config: %w", err)
+	}
+	if !d.postgresEvents {
+		hooks = append(hooks, func(ctx context.Context, feed string, items []store.Item) {
+			broadcaster.Notify(feed)
+		})
+	}
+
+	fanoutTargets, err := d.fanoutTargets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fanout config: %w", err)
+	}
+	if len(fanoutTargets) > 0 {
+		f := &fanout.Fanout{Targets: fanoutTargets, Outbox: d.store}
+
+		// items are only added to the outbox here, they're delivered by the fanout job
+		hooks = append(hooks, func(ctx context.Context, feed string, items []store.Item) {
+			err := f.Enqueue(ctx, feed, items)
+			if err != nil {
+				log.Printf("failed to enqueue items for fanout: %s", err)
+			}
+		})
+	}
+
+	hub, err := d.webSubHub()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load websub config: %w", err)
+	}
+	if hub != nil {
+		hooks = append(hooks, func(ctx context.Context, feed string, items []store.Item) {
+			hub.Notify(feed)
+		})
+	}
+
+	notifier, minPriority, err := d.priorityNotifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load priority notifications config: %w", err)
+	}
+	if notifier != nil {
+		hooks = append(hooks, func(ctx context.Context, feed string, items []store.Item) {
+			notifyPriorityItems(notifier, minPriority, feed, items)
+		})
+	}
+
+	d.publishedHooks = hooks
+
+	return d.publishedHooks, nil
+}
+
+// notifyPriorityItems sends a notification for each published item which is at least minPriority. Notifications are
+// sent in the background so that slow notifiers don't hold up item creation.
+func notifyPriorityItems(notifier jobs.Notifier, minPriority store.Priority, feed string, items []store.Item) {
+	for _, item := range items {
+		if !item.Priority.AtLeast(minPriority) {
+			continue
+		}
+
+		title := fmt.Sprintf("[%s] %s: %s", item.Priority, feed, item.Title)
+		message := item.Body
+		if message == "" {
+			message = item.Title
+		}
+		// pushover limits messages to 1024 characters
+		if runes := []rune(message); len(runes) > 1000 {
+			message = string(runes[:1000]) + "…"
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			err := notifier.Notify(ctx, title, message)
+			if err != nil {
+				log.Printf("failed to send priority notification for %s: %s", feed, err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,78 @@
+package tool
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// TestPriorityNotificationsForScheduledItems checks that scheduled items are notified when they're published,
+// rather than when they're created
+func TestPriorityNotificationsForScheduledItems(t *testing.T) {
+	ctx := context.Background()
+
+	notifications := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		notifications <- string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	d := &WebhookRSS{}
+	require.NoError(t, d.SetConfig(map[string]any{
+		"storage": map[string]any{"backend": "memory"},
+		"priority_notifications": map[string]any{
+			"endpoint": server.URL,
+		},
+	}))
+
+	hooks, err := d.itemCreatedHooks()
+	require.NoError(t, err)
+	publishedHooks, err := d.itemPublishedHooks()
+	require.NoError(t, err)
+
+	publishAt := time.Now().Add(500 * time.Millisecond)
+	created, err := d.store.InsertItems(ctx, "alerts", []store.NewItem{
+		{GUID: "a", Title: "maintenance starting", Priority: store.PriorityUrgent, CreatedAt: publishAt},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+	for _, fn := range hooks {
+		fn(ctx, "alerts", created)
+	}
+
+	publish := &jobs.Publish{Items: d.store, OnPublished: publishedHooks}
+	require.NoError(t, publish.Run(ctx))
+
+	select {
+	case n := <-notifications:
+		t.Fatalf("scheduled item was notified before it was published: %s", n)
+	case <-time.After(time.Until(publishAt)):
+	}
+
+	require.NoError(t, publish.Run(ctx))
+
+	select {
+	case n := <-notifications:
+		assert.Contains(t, n, "maintenance starting")
+	case <-time.After(5 * time.Second):
+		t.Fatal("published item wasn't notified")
+	}
+
+	// items are only notified once
+	require.NoError(t, publish.Run(ctx))
+	select {
+	case n := <-notifications:
+		t.Fatalf("item was notified again: %s", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poster"
+)
+
+// alert posts an item to a webhook-rss items endpoint, p is used to send it when it's set
+func alert(ctx context.Context, p *poster.Poster, endpoint, title, body string) error {
+	if p == nil {
+		p = &poster.Poster{}
+	}
+
+	return p.PostItems(ctx, endpoint, poster.Item{Title: title, Body: body})
+}
@@ -5,13 +5,25 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
 )
 
-// Clean will remove items overflow items from feeds with more than 50 items
+// Clean will remove items overflow items from feeds with more than 50 items, and items which have expired. Pinned
+// items and items starred by a user are kept.
+// It also removes usage and rate limit records which are no longer needed to enforce limits, and job runs older than
+// the retention period.
 type Clean struct {
 	ScheduleOverride string
 
+	Items store.ItemStore
+
+	// DB is optional, idle rate limit buckets are removed from it when it's set. It's only set when using Postgres.
 	DB *sql.DB
+
+	// JobRuns is optional, runs are pruned from it when it's set
+	JobRuns         store.JobRunStore
+	JobRunRetention time.Duration
 }
 
 func (c *Clean) Name() string {
@@ -23,19 +35,42 @@ func (c *Clean) Run(ctx context.Context) error {
 	errCh := make(chan error)
 
 	go func() {
-		_, err := c.DB.Exec(`
-delete from webhookrss.items where id in (
-  select id from webhookrss.items where feed in (
-    select feed from (select feed, count(id) as count from webhookrss.items group by feed) as counts
-    where count > 50
-  )
-  order by created_at desc
-  offset 50
-) `)
+		deleted, err := c.Items.TrimFeeds(ctx, 50)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to clean old items: %w", err)
 			return
 		}
+		AddStat(ctx, "items_deleted", deleted)
+
+		expired, err := c.Items.DeleteExpiredItems(ctx, time.Now())
+		if err != nil {
+			errCh <- fmt.Errorf("failed to clean expired items: %w", err)
+			return
+		}
+		AddStat(ctx, "expired_items_deleted", expired)
+
+		err = c.Items.PruneUsage(ctx, time.Now().Add(-7*24*time.Hour))
+		if err != nil {
+			errCh <- fmt.Errorf("failed to clean old feed usage: %w", err)
+			return
+		}
+
+		if c.DB != nil {
+			_, err = c.DB.ExecContext(ctx, `delete from webhookrss.rate_limit_buckets where updated_at < now() - interval '1 day'`)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to clean idle rate limit buckets: %w", err)
+				return
+			}
+		}
+
+		if c.JobRuns != nil && c.JobRunRetention > 0 {
+			pruned, err := c.JobRuns.PruneJobRuns(ctx, time.Now().Add(-c.JobRunRetention))
+			if err != nil {
+				errCh <- fmt.Errorf("failed to clean old job runs: %w", err)
+				return
+			}
+			AddStat(ctx, "job_runs_deleted", pruned)
+		}
 
 		doneCh <- true
 	}()
@@ -1,22 +1,23 @@
 package jobs
 
 import (
-	"bytes"
 	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
-	"github.com/doug-martin/goqu/v9"
-	"net/http"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poster"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
 )
 
 type CleanCheck struct {
-	DB *sql.DB
+	Items store.ItemStore
 
 	Endpoint         string
 	ScheduleOverride string
+	// Poster sends the warnings, the defaults are used when it's nil
+	Poster *poster.Poster
 }
 
 func (c *CleanCheck) Name() string {
@@ -27,24 +28,23 @@ func (c *CleanCheck) Run(ctx context.Context) error {
 	doneCh := make(chan bool)
 	errCh := make(chan error)
 
-	goquDB := goqu.New("postgres", c.DB)
-
 	go func() {
-		var rows []struct {
-			Feed  string `db:"feed"`
-			Count int    `db:"count"`
-		}
-
-		counts := goquDB.From("webhookrss.items").Select("feed", goqu.COUNT("id").As("count")).GroupBy("feed")
-
-		sel := goquDB.From(counts).Select("feed", "count").Where(goqu.C("count").Gt(75)).Order(goqu.C("count").Desc())
-
-		err := sel.Executor().ScanStructs(&rows)
+		stats, err := c.Items.FeedStats(ctx)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to get state to check if clean: %w", err)
 			return
 		}
 
+		var rows []store.FeedStats
+		for _, s := range stats {
+			if s.Count > 75 {
+				rows = append(rows, s)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Count > rows[j].Count
+		})
+
 		if len(rows) > 0 {
 			items := []string{}
 			for _, row := range rows {
@@ -52,37 +52,9 @@ func (c *CleanCheck) Run(ctx context.Context) error {
 			}
 			body := fmt.Sprintf("<ul>%s</ul>", strings.Join(items, "\n"))
 
-			datab := []map[string]string{
-				{
-					"title": "Clean Check Failed",
-					"body":  body,
-					"url":   "",
-				},
-			}
-
-			b, err := json.Marshal(datab)
+			err := alert(ctx, c.Poster, c.Endpoint, "Clean Check Failed", body)
 			if err != nil {
-				errCh <- fmt.Errorf("failed to form item JSON: %s", err)
-				return
-			}
-
-			client := &http.Client{}
-			req, err := http.NewRequest("POST", c.Endpoint, bytes.NewBuffer(b))
-			if err != nil {
-				errCh <- fmt.Errorf("failed to build request for clean warning: %s", err)
-				return
-			}
-
-			req.Header.Add("Content-Type", "application/json; charset=utf-8")
-
-			resp, err := client.Do(req)
-			if err != nil {
-				errCh <- fmt.Errorf("failed to send request with clean warning: %s", err)
-				return
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				errCh <- fmt.Errorf("failed to send request with clean warning: non 200OK response")
+				errCh <- fmt.Errorf("failed to send clean warning: %s", err)
 				return
 			}
 		}
@@ -1,12 +1,11 @@
 package jobs
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poster"
 )
 
 // DeadMan will send events into a given feed at regular intervals.
@@ -14,6 +13,8 @@ import (
 type DeadMan struct {
 	Endpoint         string
 	ScheduleOverride string
+	// Poster sends the pulses, the defaults are used when it's nil
+	Poster *poster.Poster
 }
 
 func (d *DeadMan) Name() string {
@@ -25,36 +26,9 @@ func (d *DeadMan) Run(ctx context.Context) error {
 	errCh := make(chan error)
 
 	go func() {
-		datab := []map[string]string{
-			{
-				"title": "Dead Man Pulse",
-				"body":  "",
-				"url":   "",
-			},
-		}
-
-		b, err := json.Marshal(datab)
-		if err != nil {
-			errCh <- fmt.Errorf("failed to form dead man item JSON: %s", err)
-			return
-		}
-
-		client := &http.Client{}
-		req, err := http.NewRequest("POST", d.Endpoint, bytes.NewBuffer(b))
+		err := alert(ctx, d.Poster, d.Endpoint, "Dead Man Pulse", "")
 		if err != nil {
-			errCh <- fmt.Errorf("failed to build request for dead man item: %s", err)
-			return
-		}
-
-		req.Header.Add("Content-Type", "application/json; charset=utf-8")
-
-		resp, err := client.Do(req)
-		if err != nil {
-			errCh <- fmt.Errorf("failed to send request for dead man item: %s", err)
-			return
-		}
-		if resp.StatusCode != http.StatusOK {
-			errCh <- fmt.Errorf("failed to send request: non 200OK response")
+			errCh <- fmt.Errorf("failed to send dead man item: %s", err)
 			return
 		}
 
@@ -2,22 +2,31 @@ package jobs
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"github.com/doug-martin/goqu/v9"
-	"github.com/gregdel/pushover"
-	"math"
+	"log"
+	"strings"
 	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// DeadmanFeed is the feed checked for dead man pulses, and DeadmanMaxAge is the age after which the pulses are
+// considered to have stopped. These are used for the deadman heartbeat when no heartbeats are configured.
+const (
+	DeadmanFeed   = "deadman"
+	DeadmanMaxAge = 24 * time.Hour
 )
 
-// DeadmanCheck will validate functionality of the tool by checking the deadman feed.
-// This job will notify using pushover if the deadman feed is not working.
+// DeadmanCheck checks that each heartbeat feed has received an item recently. The default heartbeat is the
+// deadman feed, which validates the functionality of the tool. Other systems can post heartbeats to their own
+// feeds to be monitored in the same way. Each missed heartbeat is sent to its notifier.
 type DeadmanCheck struct {
 	ScheduleOverride string
 
-	DB            *sql.DB
-	PushoverToken string
-	PushoverApp   string
+	Items      store.ItemStore
+	Heartbeats []Heartbeat
+	// Monitor is optional, when it's set each heartbeat's status changes are recorded
+	Monitor *HeartbeatMonitor
 }
 
 func (d *DeadmanCheck) Name() string {
@@ -28,54 +37,40 @@ func (d *DeadmanCheck) Run(ctx context.Context) error {
 	doneCh := make(chan bool)
 	errCh := make(chan error)
 
-	goquDB := goqu.New("postgres", d.DB)
-
 	go func() {
-		var err error
-		defer func() {
+		var missed []string
+		for _, heartbeat := range d.Heartbeats {
+			status, err := heartbeat.Check(ctx, d.Items)
 			if err != nil {
-				app := pushover.New(d.PushoverApp)
-				recipient := pushover.NewRecipient(d.PushoverToken)
-				message := pushover.NewMessage(err.Error())
+				errCh <- fmt.Errorf("failed to check heartbeat %s: %w", heartbeat.Name, err)
+				return
+			}
 
-				_, sendErr := app.SendMessage(message, recipient)
-				if sendErr != nil {
-					errCh <- sendErr
+			message := status.Reason()
+			if d.Monitor != nil {
+				err = d.Monitor.Update(ctx, heartbeat.Name, status.Healthy(), message)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to update heartbeat %s: %w", heartbeat.Name, err)
 					return
 				}
-
-				errCh <- err
 			}
-		}()
-
-		var item struct {
-			ID        int64     `db:"id"`
-			Title     string    `db:"title"`
-			Body      string    `db:"body"`
-			URL       string    `db:"url"`
-			CreatedAt time.Time `db:"created_at"`
-		}
-
-		found, err := goquDB.From("webhookrss.items").
-			Where(goqu.C("feed").Eq("deadman")).
-			Order(goqu.I("created_at").Desc()).
-			Limit(1).
-			ScanStruct(&item)
-		if err != nil {
-			err = fmt.Errorf("failed to query deadman feed: %w", err)
-			return
-		}
-		if !found {
-			err = fmt.Errorf("deadman feed is empty")
-			return
-		}
+			if status.Healthy() {
+				continue
+			}
 
-		utc := time.Now().UTC()
+			missed = append(missed, message)
+			AddStat(ctx, "heartbeats_missed", 1)
 
-		diff := utc.Sub(item.CreatedAt)
+			log.Println("Alerting for heartbeat", heartbeat.Name)
+			err = heartbeat.Notifier.Notify(ctx, fmt.Sprintf("Heartbeat %s missed", heartbeat.Name), message)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to notify for heartbeat %s: %w", heartbeat.Name, err)
+				return
+			}
+		}
 
-		if math.Abs(diff.Hours()) > 24 {
-			err = fmt.Errorf("deadman feed is stale: %s", diff)
+		if len(missed) > 0 {
+			errCh <- fmt.Errorf("%s", strings.Join(missed, ", "))
 			return
 		}
 
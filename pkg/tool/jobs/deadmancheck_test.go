@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// recordingNotifier records the titles of the alerts it's sent, returning err for each
+type recordingNotifier struct {
+	mu     sync.Mutex
+	titles []string
+	err    error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, title, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.titles = append(n.titles, title)
+
+	return n.err
+}
+
+func TestDeadmanCheckRun(t *testing.T) {
+	now := time.Now()
+
+	testCases := map[string]struct {
+		// items are inserted into the feed named by each key
+		items map[string][]store.NewItem
+
+		alerted []string
+		// reasons are the reasons the job reports for missed heartbeats
+		reasons []string
+		down    []string
+	}{
+		"all healthy": {
+			items: map[string][]store.NewItem{
+				"backups": {{GUID: "a", Title: "beat", CreatedAt: now.Add(-time.Minute)}},
+				"builds":  {{GUID: "a", Title: "beat", CreatedAt: now.Add(-time.Minute)}},
+			},
+		},
+		"one missed": {
+			items: map[string][]store.NewItem{
+				"backups": {{GUID: "a", Title: "beat", CreatedAt: now.Add(-2 * time.Hour)}},
+				"builds":  {{GUID: "a", Title: "beat", CreatedAt: now.Add(-time.Minute)}},
+			},
+			alerted: []string{"Heartbeat backups missed"},
+			reasons: []string{"backups feed is stale: 2h0m"},
+			down:    []string{"backups"},
+		},
+		"both missed": {
+			items: map[string][]store.NewItem{
+				"backups": {{GUID: "a", Title: "beat", CreatedAt: now.Add(-2 * time.Hour)}},
+			},
+			alerted: []string{"Heartbeat backups missed", "Heartbeat builds missed"},
+			reasons: []string{"backups feed is stale: 2h0m", "builds feed is empty"},
+			down:    []string{"backups", "builds"},
+		},
+		"future dated items don't count": {
+			items: map[string][]store.NewItem{
+				"backups": {
+					{GUID: "a", Title: "beat", CreatedAt: now.Add(-2 * time.Hour)},
+					{GUID: "b", Title: "beat", CreatedAt: now.Add(time.Hour)},
+				},
+				"builds": {{GUID: "a", Title: "beat", CreatedAt: now.Add(time.Hour)}},
+			},
+			alerted: []string{"Heartbeat backups missed", "Heartbeat builds missed"},
+			reasons: []string{"backups feed is stale: 2h0m", "builds feed is empty"},
+			down:    []string{"backups", "builds"},
+		},
+		"failed ping": {
+			items: map[string][]store.NewItem{
+				"backups": {{GUID: "a", Title: "fail", Tags: PingTags("backups", PingFail), CreatedAt: now.Add(-time.Minute)}},
+				"builds":  {{GUID: "a", Title: "beat", CreatedAt: now.Add(-time.Minute)}},
+			},
+			alerted: []string{"Heartbeat backups missed"},
+			reasons: []string{"backups reported a failure"},
+			down:    []string{"backups"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemory()
+
+			for feed, items := range tc.items {
+				_, err := s.InsertItems(ctx, feed, items, store.InsertOptions{})
+				require.NoError(t, err)
+			}
+
+			notifier := &recordingNotifier{}
+			tracker := &Tracker{Runs: s}
+			check := tracker.Track(&DeadmanCheck{
+				Items: s,
+				Heartbeats: []Heartbeat{
+					{Name: "backups", Feed: "backups", Interval: time.Hour, Notifier: notifier},
+					{Name: "builds", Feed: "builds", Interval: time.Hour, Notifier: notifier},
+				},
+				Monitor: &HeartbeatMonitor{Items: s, States: s, Feed: "heartbeats"},
+			})
+
+			err := check.Run(ctx)
+			if len(tc.reasons) == 0 {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.True(t, strings.HasPrefix(err.Error(), "job failed with error: "), err.Error())
+				for _, reason := range tc.reasons {
+					assert.Contains(t, err.Error(), reason)
+				}
+			}
+
+			assert.Equal(t, tc.alerted, notifier.titles)
+
+			runs, err := s.ListJobRuns(ctx, "deadman-check", 0)
+			require.NoError(t, err)
+			require.Len(t, runs, 1)
+			assert.Equal(t, int64(len(tc.alerted)), runs[0].Stats["heartbeats_missed"])
+
+			for _, heartbeat := range []string{"backups", "builds"} {
+				state, found, err := s.GetHeartbeatState(ctx, heartbeat)
+				require.NoError(t, err)
+				require.True(t, found, "each heartbeat's status should be recorded")
+
+				expected := store.HeartbeatUp
+				for _, down := range tc.down {
+					if down == heartbeat {
+						expected = store.HeartbeatDown
+					}
+				}
+				assert.Equal(t, expected, state.Status, heartbeat)
+			}
+		})
+	}
+}
+
+func TestDeadmanCheckNotifyError(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	notifier := &recordingNotifier{err: errors.New("pushover unavailable")}
+	check := &DeadmanCheck{
+		Items: s,
+		Heartbeats: []Heartbeat{
+			{Name: "backups", Feed: "backups", Interval: time.Hour, Notifier: notifier},
+			{Name: "builds", Feed: "builds", Interval: time.Hour, Notifier: notifier},
+		},
+	}
+
+	err := check.Run(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to notify for heartbeat backups: pushover unavailable")
+	assert.Equal(t, []string{"Heartbeat backups missed"}, notifier.titles)
+}
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/digest"
+)
+
+// Digest posts a summary of the new items in a source feed to a digest feed. There is a job for each configured
+// digest so that each can have its own schedule.
+type Digest struct {
+	ScheduleOverride string
+
+	Digest   *digest.Digest
+	Digester *digest.Digester
+}
+
+func (d *Digest) Name() string {
+	return "digest-" + d.Digest.Name
+}
+
+func (d *Digest) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		err := d.Digester.Run(ctx, d.Digest)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to create digest %s: %w", d.Digest.Name, err)
+			return
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (d *Digest) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (d *Digest) Schedule() string {
+	if d.ScheduleOverride != "" {
+		return d.ScheduleOverride
+	}
+	// every day at 8am
+	return "0 0 8 * * *"
+}
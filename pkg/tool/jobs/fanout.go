@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/fanout"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Fanout sends items waiting in the outbox to fan-out targets. Failed deliveries are retried with backoff until
+// they're dead lettered. Delivered items are removed from the outbox after a week.
+type Fanout struct {
+	ScheduleOverride string
+
+	Outbox    store.OutboxStore
+	Deliverer *fanout.Deliverer
+}
+
+func (f *Fanout) Name() string {
+	return "fanout"
+}
+
+func (f *Fanout) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		delivered, err := f.Deliverer.Deliver(ctx)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to deliver items: %w", err)
+			return
+		}
+		AddStat(ctx, "deliveries_sent", int64(delivered))
+
+		pruned, err := f.Outbox.PruneDeliveries(ctx, time.Now().Add(-7*24*time.Hour))
+		if err != nil {
+			errCh <- fmt.Errorf("failed to prune deliveries: %w", err)
+			return
+		}
+		AddStat(ctx, "deliveries_deleted", pruned)
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (f *Fanout) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (f *Fanout) Schedule() string {
+	if f.ScheduleOverride != "" {
+		return f.ScheduleOverride
+	}
+	return "*/15 * * * * *"
+}
@@ -1,23 +1,22 @@
 package jobs
 
 import (
-	"bytes"
 	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 
-	"github.com/doug-martin/goqu/v9"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poster"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
 )
 
 type FeedCheck struct {
-	DB *sql.DB
+	Items store.ItemStore
 
 	Endpoint         string
 	ScheduleOverride string
+	// Poster sends the alerts, the defaults are used when it's nil
+	Poster *poster.Poster
 
 	Feeds []interface{}
 }
@@ -30,61 +29,27 @@ func (c *FeedCheck) Run(ctx context.Context) error {
 	doneCh := make(chan bool)
 	errCh := make(chan error)
 
-	goquDB := goqu.New("postgres", c.DB)
-
 	go func() {
-		var rows []struct {
-			Feed string    `db:"feed"`
-			Age  time.Time `db:"created_at"`
-		}
-
-		sel := goquDB.From("webhookrss.items").Select("feed", goqu.MAX("created_at").As("created_at")).GroupBy("feed")
-		err := sel.Executor().ScanStructs(&rows)
+		stale, err := c.StaleFeeds(ctx)
 		if err != nil {
-			errCh <- fmt.Errorf("failed to get feed ages: %w", err)
+			errCh <- err
 			return
 		}
 
-		for _, row := range rows {
-			for _, feed := range c.Feeds {
-				feedData, ok := feed.(map[string]interface{})
-				if !ok {
-					errCh <- fmt.Errorf("failed to parse feed data")
-					return
-				}
-
-				feedName, ok := feedData["name"].(string)
-				if !ok {
-					errCh <- fmt.Errorf("failed to parse feed name")
-					return
-				}
-				if feedName == row.Feed {
-					maxAgeString, ok := feedData["max_age"].(string)
-					if !ok {
-						errCh <- fmt.Errorf("failed to parse max_age for feed %s", feedName)
-						return
-					}
-
-					maxAge, err := time.ParseDuration(maxAgeString)
-					if err != nil {
-						errCh <- fmt.Errorf("failed to parse max_age for feed %s: %w", feedName, err)
-						return
-					}
-
-					if time.Now().UTC().Sub(row.Age) > maxAge {
-						log.Println("Alerting for feed", feedName)
-						err := alert(
-							c.Endpoint,
-							"Feed Stale Error",
-							fmt.Sprintf("Feed %s has not been updated in over %s", feedName, maxAge),
-						)
-						if err != nil {
-							errCh <- fmt.Errorf("failed to send alert for feed %s: %w", feedName, err)
-							return
-						}
-					}
-				}
+		for _, feed := range stale {
+			log.Println("Alerting for feed", feed.Feed)
+			err := alert(
+				ctx,
+				c.Poster,
+				c.Endpoint,
+				"Feed Stale Error",
+				fmt.Sprintf("Feed %s has not been updated in over %s", feed.Feed, feed.MaxAge),
+			)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to send alert for feed %s: %w", feed.Feed, err)
+				return
 			}
+			AddStat(ctx, "feeds_alerted", 1)
 		}
 
 		doneCh <- true
@@ -100,46 +65,79 @@ func (c *FeedCheck) Run(ctx context.Context) error {
 	}
 }
 
-func (c *FeedCheck) Timeout() time.Duration {
-	return 15 * time.Second
+// StaleFeed is a checked feed whose newest item is older than its max age
+type StaleFeed struct {
+	Feed     string
+	LatestAt time.Time
+	MaxAge   time.Duration
 }
 
-func (c *FeedCheck) Schedule() string {
-	if c.ScheduleOverride != "" {
-		return c.ScheduleOverride
-	}
-	return "0 0 0 * * *"
-}
-
-func alert(webhookRSSEndpoint, title, message string) error {
-	datab := []map[string]string{
-		{
-			"title": title,
-			"body":  message,
-			"url":   "",
-		},
-	}
-
-	b, err := json.Marshal(datab)
+// StaleFeeds returns the checked feeds whose newest item is older than their max age. Feeds without items aren't
+// checked.
+func (c *FeedCheck) StaleFeeds(ctx context.Context) ([]StaleFeed, error) {
+	maxAges, err := c.MaxAges()
 	if err != nil {
-		return fmt.Errorf("failed to form alert item JSON: %s", err)
+		return nil, err
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", webhookRSSEndpoint, bytes.NewBuffer(b))
+	rows, err := c.Items.FeedStats(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to build request for alert item: %s", err)
+		return nil, fmt.Errorf("failed to get feed ages: %w", err)
 	}
 
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	stale := []StaleFeed{}
+	for _, row := range rows {
+		maxAge, ok := maxAges[row.Feed]
+		if !ok {
+			continue
+		}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request for alert item: %s", err)
+		if time.Now().UTC().Sub(row.LatestAt) > maxAge {
+			stale = append(stale, StaleFeed{Feed: row.Feed, LatestAt: row.LatestAt, MaxAge: maxAge})
+		}
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to send request: non 200OK response: %d", resp.StatusCode)
+
+	return stale, nil
+}
+
+// MaxAges returns the max age of the newest item in each checked feed
+func (c *FeedCheck) MaxAges() (map[string]time.Duration, error) {
+	maxAges := make(map[string]time.Duration)
+
+	for _, feed := range c.Feeds {
+		feedData, ok := feed.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to parse feed data")
+		}
+
+		feedName, ok := feedData["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse feed name")
+		}
+
+		maxAgeString, ok := feedData["max_age"].(string)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse max_age for feed %s", feedName)
+		}
+
+		maxAge, err := time.ParseDuration(maxAgeString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse max_age for feed %s: %w", feedName, err)
+		}
+
+		maxAges[feedName] = maxAge
 	}
 
-	return nil
+	return maxAges, nil
+}
+
+func (c *FeedCheck) Timeout() time.Duration {
+	return 15 * time.Second
+}
+
+func (c *FeedCheck) Schedule() string {
+	if c.ScheduleOverride != "" {
+		return c.ScheduleOverride
+	}
+	return "0 0 0 * * *"
 }
@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestFeedCheckStaleFeeds(t *testing.T) {
+	testCases := map[string]struct {
+		items []store.NewItem
+		stale bool
+	}{
+		"recent item": {
+			items: []store.NewItem{{GUID: "a", Title: "recent", CreatedAt: time.Now().Add(-time.Minute)}},
+		},
+		"old item": {
+			items: []store.NewItem{{GUID: "a", Title: "old", CreatedAt: time.Now().Add(-2 * time.Hour)}},
+			stale: true,
+		},
+		"old item and a scheduled item": {
+			items: []store.NewItem{
+				{GUID: "a", Title: "old", CreatedAt: time.Now().Add(-2 * time.Hour)},
+				{GUID: "b", Title: "scheduled", CreatedAt: time.Now().Add(24 * time.Hour)},
+			},
+			stale: true,
+		},
+		"only a scheduled item": {
+			items: []store.NewItem{{GUID: "a", Title: "scheduled", CreatedAt: time.Now().Add(24 * time.Hour)}},
+			stale: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemory()
+
+			_, err := s.InsertItems(ctx, "backups", tc.items, store.InsertOptions{})
+			require.NoError(t, err)
+			// unchecked feeds are never stale
+			_, err = s.InsertItems(ctx, "other", []store.NewItem{
+				{GUID: "a", Title: "old", CreatedAt: time.Now().Add(-48 * time.Hour)},
+			}, store.InsertOptions{})
+			require.NoError(t, err)
+
+			check := &FeedCheck{
+				Items: s,
+				Feeds: []interface{}{map[string]interface{}{"name": "backups", "max_age": "1h"}},
+			}
+
+			stale, err := check.StaleFeeds(ctx)
+			require.NoError(t, err)
+
+			if !tc.stale {
+				assert.Empty(t, stale)
+				return
+			}
+			require.Len(t, stale, 1)
+			assert.Equal(t, "backups", stale[0].Feed)
+			assert.Equal(t, time.Hour, stale[0].MaxAge)
+			assert.False(t, stale[0].LatestAt.After(time.Now()), "scheduled items shouldn't be the latest")
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gregdel/pushover"
+	"github.com/robfig/cron"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poster"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Tags set on the items recorded by pings. Each ping item is tagged with PingTag, the kind of ping and the name of
+// the check it's for.
+const (
+	PingTag     = "ping"
+	PingSuccess = "success"
+	PingStart   = "start"
+	PingFail    = "fail"
+)
+
+// PingTags returns the tags for a ping item
+func PingTags(check, signal string) store.Tags {
+	return store.Tags{PingTag, signal, check}
+}
+
+// PingCheck returns the name of the check a ping item was recorded for, ok is false for items which aren't pings.
+// The name is empty for pings recorded before they were tagged with their check.
+func PingCheck(item store.Item) (name string, ok bool) {
+	if !item.Tags.Has(PingTag) {
+		return "", false
+	}
+
+	for _, tag := range item.Tags {
+		if tag != PingTag && tag != PingSuccess && tag != PingStart && tag != PingFail {
+			return tag, true
+		}
+	}
+
+	return "", true
+}
+
+// ReservedHeartbeatName returns true for names which can't be used by heartbeats, as they're used to tag pings
+func ReservedHeartbeatName(name string) bool {
+	return name == PingTag || name == PingSuccess || name == PingStart || name == PingFail
+}
+
+// Heartbeat is a feed which is expected to receive items regularly. The next item is due Interval after the last
+// one, or at the next time matching Schedule when it's set. The heartbeat is missed when the next item is more
+// than Grace late, or when the feed has never received an item.
+type Heartbeat struct {
+	Name     string
+	Feed     string
+	Interval time.Duration
+	// Schedule is a cron expression, with or without a seconds field
+	Schedule string
+	Grace    time.Duration
+
+	// Notifier is told when the heartbeat is missed
+	Notifier Notifier
+}
+
+// ParseHeartbeatSchedule parses a heartbeat's cron schedule, which may have 5 fields or 6 with seconds first
+func ParseHeartbeatSchedule(spec string) (cron.Schedule, error) {
+	if len(strings.Fields(spec)) == 5 {
+		return cron.ParseStandard(spec)
+	}
+	return cron.Parse(spec)
+}
+
+// DueAt returns the time the next item is expected after one received at last
+func (h Heartbeat) DueAt(last time.Time) (time.Time, error) {
+	if h.Schedule == "" {
+		return last.Add(h.Interval), nil
+	}
+
+	schedule, err := ParseHeartbeatSchedule(h.Schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule for heartbeat %s: %w", h.Name, err)
+	}
+
+	return schedule.Next(last), nil
+}
+
+// HeartbeatStatus is the result of checking a heartbeat
+type HeartbeatStatus struct {
+	Heartbeat Heartbeat
+	// LastBeatAt is the time of the newest item in the feed, it's nil when there are none
+	LastBeatAt *time.Time
+	// Missed is true when the next item is overdue
+	Missed bool
+	// Failed is true when the newest item is a failure ping
+	Failed bool
+}
+
+// Healthy is true when the heartbeat is neither missed nor failed
+func (s HeartbeatStatus) Healthy() bool {
+	return !s.Missed && !s.Failed
+}
+
+// Reason describes why the heartbeat isn't healthy
+func (s HeartbeatStatus) Reason() string {
+	switch {
+	case s.LastBeatAt == nil:
+		return fmt.Sprintf("%s feed is empty", s.Heartbeat.Feed)
+	case s.Failed:
+		return fmt.Sprintf("%s reported a failure", s.Heartbeat.Name)
+	case s.Missed:
+		return fmt.Sprintf("%s feed is stale: %s", s.Heartbeat.Feed, time.Since(*s.LastBeatAt).Round(time.Second))
+	default:
+		return fmt.Sprintf("%s is healthy", s.Heartbeat.Name)
+	}
+}
+
+// Check finds the newest item in the heartbeat's feed and whether it's recent enough. Start pings don't count,
+// since the run they started hasn't finished, and neither do pings for other checks sharing the feed. Items dated in
+// the future are ignored, they haven't happened yet so they can't show that the sender is alive.
+func (h Heartbeat) Check(ctx context.Context, items store.ItemStore) (HeartbeatStatus, error) {
+	status := HeartbeatStatus{Heartbeat: h, Missed: true}
+
+	const pageSize = 20
+	for offset := 0; ; offset += pageSize {
+		page, err := items.ListItems(ctx, h.Feed, store.ListOptions{Limit: pageSize, Offset: offset})
+		if err != nil {
+			return status, fmt.Errorf("failed to query feed %s: %w", h.Feed, err)
+		}
+
+		for _, item := range page {
+			if check, ok := PingCheck(item); ok && (!h.pingedBy(check) || item.Tags.Has(PingStart)) {
+				continue
+			}
+
+			dueAt, err := h.DueAt(item.CreatedAt)
+			if err != nil {
+				return status, err
+			}
+
+			status.LastBeatAt = &item.CreatedAt
+			status.Missed = time.Now().After(dueAt.Add(h.Grace))
+			status.Failed = item.Tags.Has(PingTag) && item.Tags.Has(PingFail)
+
+			return status, nil
+		}
+
+		if len(page) < pageSize {
+			return status, nil
+		}
+	}
+}
+
+// LastPing returns the newest ping recorded for the heartbeat, found is false when there are none
+func (h Heartbeat) LastPing(ctx context.Context, items store.ItemStore) (item store.Item, found bool, err error) {
+	const pageSize = 20
+	for offset := 0; ; offset += pageSize {
+		page, err := items.ListItems(ctx, h.Feed, store.ListOptions{Limit: pageSize, Offset: offset})
+		if err != nil {
+			return store.Item{}, false, fmt.Errorf("failed to query feed %s: %w", h.Feed, err)
+		}
+
+		for _, item := range page {
+			if check, ok := PingCheck(item); ok && h.pingedBy(check) {
+				return item, true, nil
+			}
+		}
+
+		if len(page) < pageSize {
+			return store.Item{}, false, nil
+		}
+	}
+}
+
+// pingedBy returns true when a ping for the named check is for this heartbeat. Pings recorded before they were
+// tagged with their check are counted for every heartbeat on the feed.
+func (h Heartbeat) pingedBy(check string) bool {
+	return check == "" || check == h.Name
+}
+
+// Notifier sends an alert when a heartbeat is missed
+type Notifier interface {
+	Notify(ctx context.Context, title, message string) error
+}
+
+// PushoverNotifier sends alerts as Pushover messages
+type PushoverNotifier struct {
+	App   string
+	Token string
+}
+
+func (n *PushoverNotifier) Notify(ctx context.Context, title, message string) error {
+	app := pushover.New(n.App)
+	recipient := pushover.NewRecipient(n.Token)
+
+	_, err := app.SendMessage(pushover.NewMessageWithTitle(message, title), recipient)
+	if err != nil {
+		return fmt.Errorf("failed to send pushover message: %w", err)
+	}
+
+	return nil
+}
+
+// FeedNotifier sends alerts as items posted to a webhook-rss items endpoint
+type FeedNotifier struct {
+	Endpoint string
+	// Poster sends the alerts, the defaults are used when it's nil
+	Poster *poster.Poster
+}
+
+func (n *FeedNotifier) Notify(ctx context.Context, title, message string) error {
+	return alert(ctx, n.Poster, n.Endpoint, title, message)
+}
+
+// HeartbeatMonitor records each time a heartbeat goes up or down as an item in a feed
+type HeartbeatMonitor struct {
+	Items  store.ItemStore
+	States store.HeartbeatStateStore
+
+	// Feed receives the status changes
+	Feed string
+
+	// OnCreated is called with the status change items after they have been stored
+	OnCreated []func(ctx context.Context, feed string, items []store.Item)
+}
+
+// Update saves the status of a heartbeat, adding an item to the feed when it has changed. A heartbeat which
+// hasn't been seen before is reported when it's first checked.
+func (m *HeartbeatMonitor) Update(ctx context.Context, name string, up bool, reason string) error {
+	status := store.HeartbeatDown
+	if up {
+		status = store.HeartbeatUp
+	}
+
+	state, found, err := m.States.GetHeartbeatState(ctx, name)
+	if err != nil {
+		return err
+	}
+	if found && state.Status == status {
+		return nil
+	}
+
+	now := time.Now()
+	created, err := m.Items.InsertItems(ctx, m.Feed, []store.NewItem{{
+		GUID:      fmt.Sprintf("heartbeat-%s-%s-%d", name, status, now.UnixNano()),
+		Title:     fmt.Sprintf("%s is %s", name, status),
+		Body:      reason,
+		Tags:      store.Tags{name, status},
+		CreatedAt: now,
+	}}, store.InsertOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat %s going %s: %w", name, status, err)
+	}
+
+	for _, fn := range m.OnCreated {
+		fn(ctx, m.Feed, created)
+	}
+
+	return m.States.SaveHeartbeatState(ctx, store.HeartbeatState{Name: name, Status: status, ChangedAt: now})
+}
@@ -0,0 +1,158 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func TestHeartbeatDueAt(t *testing.T) {
+	last := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		heartbeat Heartbeat
+		expected  time.Time
+		err       bool
+	}{
+		"interval": {
+			heartbeat: Heartbeat{Name: "backups", Interval: time.Hour},
+			expected:  time.Date(2022, 10, 12, 13, 0, 0, 0, time.UTC),
+		},
+		"schedule": {
+			heartbeat: Heartbeat{Name: "backups", Schedule: "0 3 * * *"},
+			expected:  time.Date(2022, 10, 13, 3, 0, 0, 0, time.UTC),
+		},
+		"schedule with seconds": {
+			heartbeat: Heartbeat{Name: "backups", Schedule: "30 0 3 * * *"},
+			expected:  time.Date(2022, 10, 13, 3, 0, 30, 0, time.UTC),
+		},
+		"schedule later the same day": {
+			heartbeat: Heartbeat{Name: "backups", Schedule: "0 18 * * *"},
+			expected:  time.Date(2022, 10, 12, 18, 0, 0, 0, time.UTC),
+		},
+		"invalid schedule": {
+			heartbeat: Heartbeat{Name: "backups", Schedule: "daily"},
+			err:       true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dueAt, err := tc.heartbeat.DueAt(last)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, dueAt)
+		})
+	}
+}
+
+func TestHeartbeatCheck(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	old := now.Add(-2 * time.Hour)
+
+	testCases := map[string]struct {
+		grace time.Duration
+		items []store.NewItem
+
+		lastBeatAt *time.Time
+		missed     bool
+		failed     bool
+	}{
+		"empty feed": {
+			missed: true,
+		},
+		"recent item": {
+			items:      []store.NewItem{{GUID: "a", Title: "beat", CreatedAt: recent}},
+			lastBeatAt: &recent,
+		},
+		"old item": {
+			items:      []store.NewItem{{GUID: "a", Title: "beat", CreatedAt: old}},
+			lastBeatAt: &old,
+			missed:     true,
+		},
+		"old item within grace": {
+			grace:      2 * time.Hour,
+			items:      []store.NewItem{{GUID: "a", Title: "beat", CreatedAt: old}},
+			lastBeatAt: &old,
+		},
+		"only a future dated item": {
+			items:  []store.NewItem{{GUID: "a", Title: "beat", CreatedAt: now.Add(time.Hour)}},
+			missed: true,
+		},
+		"old item and a future dated item": {
+			items: []store.NewItem{
+				{GUID: "a", Title: "beat", CreatedAt: old},
+				{GUID: "b", Title: "beat", CreatedAt: now.Add(time.Hour)},
+			},
+			lastBeatAt: &old,
+			missed:     true,
+		},
+		"start ping after a success": {
+			items: []store.NewItem{
+				{GUID: "a", Title: "success", Tags: PingTags("backups", PingSuccess), CreatedAt: old},
+				{GUID: "b", Title: "start", Tags: PingTags("backups", PingStart), CreatedAt: recent},
+			},
+			lastBeatAt: &old,
+			missed:     true,
+		},
+		"only a start ping": {
+			items:  []store.NewItem{{GUID: "a", Title: "start", Tags: PingTags("backups", PingStart), CreatedAt: recent}},
+			missed: true,
+		},
+		"fail ping": {
+			items:      []store.NewItem{{GUID: "a", Title: "fail", Tags: PingTags("backups", PingFail), CreatedAt: recent}},
+			lastBeatAt: &recent,
+			failed:     true,
+		},
+		"another check's ping": {
+			items: []store.NewItem{
+				{GUID: "a", Title: "success", Tags: PingTags("backups", PingSuccess), CreatedAt: old},
+				{GUID: "b", Title: "fail", Tags: PingTags("reports", PingFail), CreatedAt: recent},
+			},
+			lastBeatAt: &old,
+			missed:     true,
+		},
+		"ping without a check": {
+			items: []store.NewItem{
+				{GUID: "a", Title: "success", Tags: store.Tags{PingTag, PingSuccess}, CreatedAt: recent},
+			},
+			lastBeatAt: &recent,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemory()
+
+			if len(tc.items) > 0 {
+				_, err := s.InsertItems(ctx, "cron", tc.items, store.InsertOptions{})
+				require.NoError(t, err)
+			}
+
+			heartbeat := Heartbeat{Name: "backups", Feed: "cron", Interval: time.Hour, Grace: tc.grace}
+
+			status, err := heartbeat.Check(ctx, s)
+			require.NoError(t, err)
+
+			if tc.lastBeatAt == nil {
+				assert.Nil(t, status.LastBeatAt)
+			} else {
+				require.NotNil(t, status.LastBeatAt)
+				assert.True(t, tc.lastBeatAt.Equal(*status.LastBeatAt), "last beat at %s", status.LastBeatAt)
+			}
+			assert.Equal(t, tc.missed, status.Missed)
+			assert.Equal(t, tc.failed, status.Failed)
+			assert.Equal(t, !tc.missed && !tc.failed, status.Healthy())
+		})
+	}
+}
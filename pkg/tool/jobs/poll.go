@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poll"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Poll fetches external feeds which are due to be polled and inserts their new entries into local feeds. It also
+// forgets entries which haven't been seen in their source for a month.
+type Poll struct {
+	ScheduleOverride string
+
+	State  store.PollStore
+	Poller *poll.Poller
+}
+
+func (p *Poll) Name() string {
+	return "poll"
+}
+
+func (p *Poll) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		err := p.Poller.Poll(ctx)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to poll sources: %w", err)
+			return
+		}
+
+		err = p.State.PruneSeenEntries(ctx, time.Now().Add(-30*24*time.Hour))
+		if err != nil {
+			errCh <- fmt.Errorf("failed to prune seen entries: %w", err)
+			return
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (p *Poll) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (p *Poll) Schedule() string {
+	if p.ScheduleOverride != "" {
+		return p.ScheduleOverride
+	}
+	return "0 * * * * *"
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Publish announces scheduled items once their publish time has passed. The hooks run for new items, such as
+// fan-out and WebSub notifications, are deferred to this job for scheduled items.
+type Publish struct {
+	ScheduleOverride string
+
+	Items store.ItemStore
+	// OnPublished is called with the newly published items in each feed
+	OnPublished []func(ctx context.Context, feed string, items []store.Item)
+}
+
+func (p *Publish) Name() string {
+	return "publish"
+}
+
+func (p *Publish) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		published, err := p.Items.ClaimPublishedItems(ctx, time.Now())
+		if err != nil {
+			errCh <- fmt.Errorf("failed to claim published items: %w", err)
+			return
+		}
+		AddStat(ctx, "items_published", int64(len(published)))
+
+		var feeds []string
+		byFeed := make(map[string][]store.Item)
+		for _, item := range published {
+			if byFeed[item.Feed] == nil {
+				feeds = append(feeds, item.Feed)
+			}
+			byFeed[item.Feed] = append(byFeed[item.Feed], item)
+		}
+
+		for _, feed := range feeds {
+			for _, fn := range p.OnPublished {
+				fn(ctx, feed, byFeed[feed])
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (p *Publish) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (p *Publish) Schedule() string {
+	if p.ScheduleOverride != "" {
+		return p.ScheduleOverride
+	}
+	return "0 * * * * *"
+}
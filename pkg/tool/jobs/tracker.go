@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// RunStatus is the outcome of the most recent run of a job
+type RunStatus struct {
+	Name     string
+	Schedule string
+
+	// LastRunAt is when the last run finished, it's nil when the job hasn't run since the process started
+	LastRunAt     *time.Time
+	LastSuccessAt *time.Time
+	LastDuration  time.Duration
+	// LastError is the error returned by the last run, it's empty when the last run succeeded
+	LastError string
+}
+
+// Tracker records the most recent run of each job it wraps. The statuses are only held in memory, so each replica
+// reports the jobs it ran. When Runs is set, every run is also stored there along with the stats the job reported.
+type Tracker struct {
+	Runs store.JobRunStore
+
+	mu       sync.Mutex
+	statuses []*RunStatus
+}
+
+// Track wraps a job so that its runs are recorded
+func (t *Tracker) Track(job apis.Job) apis.Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := &RunStatus{Name: job.Name(), Schedule: job.Schedule()}
+	t.statuses = append(t.statuses, status)
+
+	return &trackedJob{Job: job, tracker: t, status: status}
+}
+
+// Statuses returns the status of each tracked job, in the order they were tracked
+func (t *Tracker) Statuses() []RunStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := []RunStatus{}
+	for _, s := range t.statuses {
+		statuses = append(statuses, *s)
+	}
+
+	return statuses
+}
+
+type trackedJob struct {
+	apis.Job
+	tracker *Tracker
+	status  *RunStatus
+}
+
+func (j *trackedJob) Run(ctx context.Context) error {
+	start := time.Now()
+
+	stats := &runStats{values: store.JobStats{}}
+	err := j.Job.Run(context.WithValue(ctx, runStatsKey{}, stats))
+
+	finished := time.Now()
+
+	if j.tracker.Runs != nil {
+		run := store.JobRun{
+			Job:        j.Name(),
+			StartedAt:  start,
+			FinishedAt: finished,
+			Outcome:    Outcome(err),
+			Stats:      stats.snapshot(),
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+
+		// the job's context may have expired, the run is still worth recording
+		recordCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, recordErr := j.tracker.Runs.RecordJobRun(recordCtx, run)
+		cancel()
+		if recordErr != nil {
+			log.Printf("failed to record run of job %s: %s", run.Job, recordErr)
+		}
+	}
+
+	j.tracker.mu.Lock()
+	defer j.tracker.mu.Unlock()
+
+	j.status.LastRunAt = &finished
+	j.status.LastDuration = finished.Sub(start)
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	} else {
+		j.status.LastSuccessAt = &finished
+	}
+
+	return err
+}
+
+// Outcome classifies the error returned by a job run as one of the store's job run outcomes
+func Outcome(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return store.JobRunTimeout
+	case err != nil:
+		return store.JobRunFailure
+	default:
+		return store.JobRunSuccess
+	}
+}
+
+type runStatsKey struct{}
+
+type runStats struct {
+	mu     sync.Mutex
+	values store.JobStats
+}
+
+func (s *runStats) snapshot() store.JobStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := store.JobStats{}
+	for k, v := range s.values {
+		values[k] = v
+	}
+
+	return values
+}
+
+// AddStat adds n to a counter reported with the current job run, such as the number of rows removed. It does
+// nothing when the job isn't being tracked.
+func AddStat(ctx context.Context, name string, n int64) {
+	stats, ok := ctx.Value(runStatsKey{}).(*runStats)
+	if !ok {
+		return
+	}
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.values[name] += n
+}
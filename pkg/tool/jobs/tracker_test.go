@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// testJob is a job which reports stats and returns err when it's run
+type testJob struct {
+	name  string
+	err   error
+	stats store.JobStats
+}
+
+func (j *testJob) Name() string {
+	return j.name
+}
+
+func (j *testJob) Run(ctx context.Context) error {
+	for name, n := range j.stats {
+		AddStat(ctx, name, n)
+	}
+
+	return j.err
+}
+
+func (j *testJob) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (j *testJob) Schedule() string {
+	return "0 0 * * * *"
+}
+
+func TestTracker(t *testing.T) {
+	testCases := map[string]struct {
+		err   error
+		stats store.JobStats
+
+		outcome string
+	}{
+		"success": {
+			stats:   store.JobStats{"items_removed": 3},
+			outcome: store.JobRunSuccess,
+		},
+		"failure": {
+			err:     errors.New("database unavailable"),
+			stats:   store.JobStats{"items_removed": 1},
+			outcome: store.JobRunFailure,
+		},
+		"timeout": {
+			err:     fmt.Errorf("failed to list items: %w", context.DeadlineExceeded),
+			stats:   store.JobStats{},
+			outcome: store.JobRunTimeout,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemory()
+			tracker := &Tracker{Runs: s}
+
+			job := tracker.Track(&testJob{name: "clean", err: tc.err, stats: tc.stats})
+			other := tracker.Track(&testJob{name: "digest"})
+
+			statuses := tracker.Statuses()
+			require.Len(t, statuses, 2)
+			assert.Equal(t, "clean", statuses[0].Name)
+			assert.Equal(t, "0 0 * * * *", statuses[0].Schedule)
+			assert.Nil(t, statuses[0].LastRunAt, "jobs which haven't run shouldn't have a last run")
+
+			err := job.Run(ctx)
+			assert.Equal(t, tc.err, err, "the job's error should be returned unchanged")
+
+			statuses = tracker.Statuses()
+			require.Len(t, statuses, 2)
+			require.NotNil(t, statuses[0].LastRunAt)
+			if tc.err == nil {
+				assert.Empty(t, statuses[0].LastError)
+				require.NotNil(t, statuses[0].LastSuccessAt)
+				assert.Equal(t, *statuses[0].LastRunAt, *statuses[0].LastSuccessAt)
+			} else {
+				assert.Equal(t, tc.err.Error(), statuses[0].LastError)
+				assert.Nil(t, statuses[0].LastSuccessAt)
+			}
+			assert.Nil(t, statuses[1].LastRunAt, "other jobs shouldn't be updated")
+
+			runs, err := s.ListJobRuns(ctx, "clean", 0)
+			require.NoError(t, err)
+			require.Len(t, runs, 1)
+			assert.Equal(t, tc.outcome, runs[0].Outcome)
+			assert.Equal(t, tc.stats, runs[0].Stats)
+			if tc.err != nil {
+				assert.Equal(t, tc.err.Error(), runs[0].Error)
+			}
+
+			require.NoError(t, other.Run(ctx))
+			runs, err = s.ListJobRuns(ctx, "digest", 0)
+			require.NoError(t, err)
+			assert.Len(t, runs, 1)
+		})
+	}
+}
+
+func TestTrackerLastSuccess(t *testing.T) {
+	tracker := &Tracker{}
+	job := &testJob{name: "clean"}
+	tracked := tracker.Track(job)
+
+	require.NoError(t, tracked.Run(context.Background()))
+	succeededAt := tracker.Statuses()[0].LastSuccessAt
+	require.NotNil(t, succeededAt)
+
+	// the last success is kept when a later run fails, without Runs set nothing is stored
+	job.err = errors.New("failed")
+	require.Error(t, tracked.Run(context.Background()))
+
+	status := tracker.Statuses()[0]
+	assert.Equal(t, "failed", status.LastError)
+	require.NotNil(t, status.LastSuccessAt)
+	assert.Equal(t, *succeededAt, *status.LastSuccessAt)
+	assert.False(t, status.LastRunAt.Before(*succeededAt))
+}
+
+func TestAddStatUntracked(t *testing.T) {
+	// stats reported outside of a tracked run are ignored
+	assert.NotPanics(t, func() {
+		AddStat(context.Background(), "items_removed", 1)
+	})
+}
@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/websub"
+)
+
+// WebSubLeases removes expired WebSub subscriptions and renews subscriptions which are about to expire by verifying
+// them again with their subscribers.
+type WebSubLeases struct {
+	ScheduleOverride string
+
+	Subscriptions store.SubscriptionStore
+	Hub           *websub.Hub
+
+	// RenewWithin is how long before expiry subscriptions are renewed, defaults to a day
+	RenewWithin time.Duration
+}
+
+func (w *WebSubLeases) Name() string {
+	return "websub-leases"
+}
+
+func (w *WebSubLeases) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		now := time.Now()
+
+		_, err := w.Subscriptions.DeleteExpiredSubscriptions(ctx, now)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to delete expired subscriptions: %w", err)
+			return
+		}
+
+		renewWithin := w.RenewWithin
+		if renewWithin == 0 {
+			renewWithin = 24 * time.Hour
+		}
+
+		expiring, err := w.Subscriptions.ListExpiringSubscriptions(ctx, now.Add(renewWithin))
+		if err != nil {
+			errCh <- fmt.Errorf("failed to list expiring subscriptions: %w", err)
+			return
+		}
+
+		// subscribers which don't confirm the renewal keep their current lease until it expires
+		for _, sub := range expiring {
+			err := w.Hub.Verify(ctx, websub.ModeSubscribe, sub)
+			if err != nil {
+				log.Printf("failed to renew subscription of %s to %s: %s", sub.Callback, sub.Topic, err)
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (w *WebSubLeases) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (w *WebSubLeases) Schedule() string {
+	if w.ScheduleOverride != "" {
+		return w.ScheduleOverride
+	}
+	return "0 0 * * * *"
+}
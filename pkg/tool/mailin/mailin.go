@@ -0,0 +1,326 @@
+// Package mailin turns email into feed items. It provides an SMTP backend which accepts mail addressed to
+// <feed>@<domain> from allowed senders, using the subject as the item title, the text part as the body and storing
+// any attachments so that they're linked from the feed as enclosures.
+package mailin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	// registers decoders for the charsets commonly used in email
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-smtp"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Backend accepts mail for feeds, it's used to create an SMTP server with NewServer
+type Backend struct {
+	// Domain is the domain feed addresses are in, mail for other domains is rejected
+	Domain string
+	// AllowedSenders are the envelope senders mail is accepted from. Entries are either an address, or a domain
+	// prefixed with @ to allow any address in it.
+	AllowedSenders []string
+
+	Items       store.ItemStore
+	Attachments store.AttachmentStore
+	// InsertOptions are the limits applied when inserting items
+	InsertOptions store.InsertOptions
+
+	// OnCreated is called with the items created from each message
+	OnCreated []func(ctx context.Context, feed string, items []store.Item)
+}
+
+// NewServer returns an SMTP server for the backend, messages larger than maxMessageBytes are rejected
+func NewServer(b *Backend, maxMessageBytes int64) *smtp.Server {
+	s := smtp.NewServer(b)
+	s.Domain = b.Domain
+	s.MaxMessageBytes = maxMessageBytes
+	s.MaxRecipients = 10
+	s.ReadTimeout = time.Minute
+	s.WriteTimeout = time.Minute
+
+	return s
+}
+
+// NewSession is called for each SMTP connection
+func (b *Backend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &session{backend: b}, nil
+}
+
+// allowedSender returns true when mail from the address is accepted
+func (b *Backend) allowedSender(address string) bool {
+	address = strings.ToLower(address)
+
+	for _, allowed := range b.AllowedSenders {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "@") {
+			if strings.HasSuffix(address, allowed) {
+				return true
+			}
+			continue
+		}
+		if address == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// feedForRecipient returns the feed a recipient address is for, ok is false when the address isn't a feed address
+func (b *Backend) feedForRecipient(address string) (string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "", false
+	}
+
+	if !strings.EqualFold(address[at+1:], b.Domain) {
+		return "", false
+	}
+
+	// plus addressing is ignored so that senders can label or filter the mail they send
+	feed := address[:at]
+	if plus := strings.Index(feed, "+"); plus >= 0 {
+		feed = feed[:plus]
+	}
+	feed = strings.ToLower(feed)
+
+	return feed, handlers.ValidFeed(feed)
+}
+
+// session holds the state of a single SMTP transaction
+type session struct {
+	backend *Backend
+
+	from  string
+	feeds []string
+}
+
+func (s *session) Reset() {
+	s.from = ""
+	s.feeds = nil
+}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+func (s *session) Mail(from string, opts *smtp.MailOptions) error {
+	if !s.backend.allowedSender(from) {
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+			Message:      "Sender not allowed",
+		}
+	}
+
+	s.from = from
+
+	return nil
+}
+
+func (s *session) Rcpt(to string, opts *smtp.RcptOptions) error {
+	feed, ok := s.backend.feedForRecipient(to)
+	if !ok {
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+			Message:      "No such feed address",
+		}
+	}
+
+	for _, f := range s.feeds {
+		if f == feed {
+			return nil
+		}
+	}
+	s.feeds = append(s.feeds, feed)
+
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	msg, err := Parse(r)
+	if err != nil {
+		// the rest of the message must be read before returning
+		io.Copy(io.Discard, r)
+
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      fmt.Sprintf("Failed to parse message: %s", err),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, feed := range s.feeds {
+		err = s.backend.deliver(ctx, feed, msg)
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			return &smtp.SMTPError{
+				Code:         452,
+				EnhancedCode: smtp.EnhancedCode{4, 2, 2},
+				Message:      "Feed daily quota exceeded",
+			}
+		}
+		if err != nil {
+			log.Printf("failed to create item from email for feed %s: %s", feed, err)
+			return &smtp.SMTPError{
+				Code:         451,
+				EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+				Message:      "Failed to store message",
+			}
+		}
+	}
+
+	return nil
+}
+
+// deliver creates an item in the feed for the message. Messages which are sent again, such as when delivery to
+// another feed failed, are skipped in feeds which already have them.
+func (b *Backend) deliver(ctx context.Context, feed string, msg Message) error {
+	guid := messageGUID(feed, msg)
+
+	existing, err := b.Items.ExistingGUIDs(ctx, feed, []string{guid})
+	if err != nil {
+		return fmt.Errorf("failed to check for existing item: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	created, err := b.Attachments.InsertItemWithAttachments(ctx, feed, store.NewItem{
+		GUID:  guid,
+		Title: msg.Subject,
+		Body:  msg.Body,
+	}, msg.Attachments, b.InsertOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range b.OnCreated {
+		fn(ctx, feed, []store.Item{created})
+	}
+
+	return nil
+}
+
+// messageGUID returns a stable identifier for a message in a feed, used as the guid of the item created from it.
+// Messages are identified by their Message-ID, falling back to their content.
+func messageGUID(feed string, msg Message) string {
+	id := msg.MessageID
+	if id == "" {
+		content := sha256.New()
+		content.Write([]byte(msg.Subject + "\n" + msg.Body))
+		for _, a := range msg.Attachments {
+			content.Write([]byte("\n" + a.Filename + "\n"))
+			content.Write(a.Data)
+		}
+		id = hex.EncodeToString(content.Sum(nil))
+	}
+
+	sum := sha256.Sum256([]byte(feed + "\n" + id))
+
+	return hex.EncodeToString(sum[:16])
+}
+
+// Message is the content of an email used to create an item
+type Message struct {
+	// MessageID is the Message-ID header, without angle brackets. It's empty when the header isn't set.
+	MessageID   string
+	Subject     string
+	Body        string
+	Attachments []store.NewAttachment
+}
+
+// Parse reads an email. The plain text part is used as the body, falling back to the HTML part when there isn't
+// one. Parts with a filename, and inline parts which aren't text, are attachments.
+func Parse(r io.Reader) (Message, error) {
+	var msg Message
+
+	mr, err := mail.CreateReader(r)
+	if err != nil && !message.IsUnknownCharset(err) {
+		return msg, err
+	}
+
+	subject, err := mr.Header.Subject()
+	if err != nil && !message.IsUnknownCharset(err) {
+		return msg, fmt.Errorf("failed to read subject: %w", err)
+	}
+	msg.Subject = store.Truncate(strings.TrimSpace(subject), store.MaxTitleBytes)
+	if msg.Subject == "" {
+		msg.Subject = "(no subject)"
+	}
+
+	// a malformed Message-ID is treated as missing
+	msg.MessageID, _ = mr.Header.MessageID()
+
+	var text, html string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !message.IsUnknownCharset(err) {
+			return msg, fmt.Errorf("failed to read part: %w", err)
+		}
+
+		var filename, contentType string
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, _, _ = h.ContentType()
+		case *mail.AttachmentHeader:
+			contentType, _, _ = h.ContentType()
+			filename, _ = h.Filename()
+		}
+
+		data, err := io.ReadAll(part.Body)
+		if err != nil {
+			return msg, fmt.Errorf("failed to read part: %w", err)
+		}
+
+		isText := strings.HasPrefix(contentType, "text/") || contentType == ""
+		switch {
+		case filename == "" && contentType == "text/html":
+			if html == "" {
+				html = string(data)
+			}
+		case filename == "" && isText:
+			if text == "" {
+				text = string(data)
+			}
+		default:
+			if filename == "" {
+				filename = "attachment"
+			}
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			msg.Attachments = append(msg.Attachments, store.NewAttachment{
+				Filename:    filename,
+				ContentType: contentType,
+				Data:        data,
+			})
+		}
+	}
+
+	msg.Body = text
+	if strings.TrimSpace(msg.Body) == "" {
+		msg.Body = html
+	}
+	msg.Body = store.Truncate(strings.TrimSpace(msg.Body), store.MaxBodyBytes)
+
+	return msg, nil
+}
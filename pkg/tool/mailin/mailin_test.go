@@ -0,0 +1,172 @@
+package mailin
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+const multipartMessage = "From: Alerts <alerts@example.com>\r\n" +
+	"To: reports@feeds.example.com\r\n" +
+	"Subject: =?utf-8?q?Nightly_report_=E2=9C=93?=\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"All jobs passed.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>All jobs passed.</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv\r\n" +
+	"Content-Disposition: attachment; filename=report.csv\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"am9iLHN0YXR1cwpidWlsZCxvawo=\r\n" +
+	"--outer--\r\n"
+
+func TestParse(t *testing.T) {
+	msg, err := Parse(strings.NewReader(multipartMessage))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Nightly report ✓", msg.Subject)
+	assert.Equal(t, "All jobs passed.", msg.Body)
+	assert.Empty(t, msg.MessageID)
+	require.Len(t, msg.Attachments, 1)
+	assert.Equal(t, "report.csv", msg.Attachments[0].Filename)
+	assert.Equal(t, "text/csv", msg.Attachments[0].ContentType)
+	assert.Equal(t, "job,status\nbuild,ok\n", string(msg.Attachments[0].Data))
+
+	msg, err = Parse(strings.NewReader("Subject: html only\r\nContent-Type: text/html\r\n\r\n<b>hello</b>\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "<b>hello</b>", msg.Body)
+
+	msg, err = Parse(strings.NewReader("From: someone@example.com\r\n\r\nno subject\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "(no subject)", msg.Subject)
+	assert.Equal(t, "no subject", msg.Body)
+
+	msg, err = Parse(strings.NewReader("Message-ID: <1234@mail.example.com>\r\nSubject: id\r\n\r\nbody\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "1234@mail.example.com", msg.MessageID)
+}
+
+func TestMessageGUID(t *testing.T) {
+	msg := Message{MessageID: "1234@mail.example.com", Subject: "report", Body: "ok"}
+	other := Message{MessageID: "1234@mail.example.com", Subject: "other", Body: "content"}
+	assert.Equal(t, messageGUID("reports", msg), messageGUID("reports", other), "messages are identified by id")
+	assert.NotEqual(t, messageGUID("reports", msg), messageGUID("archive", msg))
+
+	msg.MessageID, other.MessageID = "", ""
+	assert.Equal(t, messageGUID("reports", msg), messageGUID("reports", msg))
+	assert.NotEqual(t, messageGUID("reports", msg), messageGUID("reports", other),
+		"messages without an id are identified by their content")
+
+	withAttachment := msg
+	withAttachment.Attachments = []store.NewAttachment{{Filename: "report.csv", Data: []byte("a,b")}}
+	assert.NotEqual(t, messageGUID("reports", msg), messageGUID("reports", withAttachment))
+}
+
+func TestServer(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var notified []string
+	backend := &Backend{
+		Domain:         "feeds.example.com",
+		AllowedSenders: []string{"alerts@example.com", "@trusted.example.com"},
+		Items:          s,
+		Attachments:    s,
+		OnCreated: []func(ctx context.Context, feed string, items []store.Item){
+			func(ctx context.Context, feed string, items []store.Item) {
+				mu.Lock()
+				defer mu.Unlock()
+				notified = append(notified, feed)
+			},
+		},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := NewServer(backend, 1024*1024)
+	go server.Serve(l)
+	defer server.Close()
+
+	send := func(from string, to []string, body string) error {
+		return smtp.SendMail(l.Addr().String(), nil, from, to, []byte(body))
+	}
+
+	err = send("alerts@example.com", []string{"reports@feeds.example.com", "Archive+nightly@FEEDS.example.com"}, multipartMessage)
+	require.NoError(t, err)
+
+	items, err := s.ListItems(ctx, "reports", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Nightly report ✓", items[0].Title)
+	assert.Equal(t, "All jobs passed.", items[0].Body)
+
+	attachments, err := s.ListAttachments(ctx, "reports", []int64{items[0].ID})
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "report.csv", attachments[0].Filename)
+
+	items, err = s.ListItems(ctx, "archive", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, items, 1, "mail should be delivered to each feed addressed")
+	mu.Lock()
+	assert.Equal(t, []string{"reports", "archive"}, notified)
+	mu.Unlock()
+
+	err = send("anyone@trusted.example.com", []string{"reports@feeds.example.com"}, "Subject: trusted\r\n\r\nbody\r\n")
+	require.NoError(t, err)
+
+	err = send("someone@elsewhere.com", []string{"reports@feeds.example.com"}, "Subject: spam\r\n\r\nbody\r\n")
+	assert.Error(t, err, "senders must be allowed")
+
+	err = send("alerts@example.com", []string{"reports@elsewhere.com"}, "Subject: other domain\r\n\r\nbody\r\n")
+	assert.Error(t, err, "mail for other domains should be rejected")
+
+	err = send("alerts@example.com", []string{"-invalid-@feeds.example.com"}, "Subject: invalid feed\r\n\r\nbody\r\n")
+	assert.Error(t, err, "feed names must be valid")
+
+	err = send("alerts@example.com", []string{"reports@feeds.example.com"}, "Subject: large\r\n\r\n"+strings.Repeat("a", 2*1024*1024)+"\r\n")
+	assert.Error(t, err, "large messages should be rejected")
+
+	items, err = s.ListItems(ctx, "reports", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	// a message sent again after delivery to one of its feeds failed is only stored in the other
+	identified := "Message-ID: <1234@mail.example.com>\r\nSubject: identified\r\n\r\nbody\r\n"
+	err = send("alerts@example.com", []string{"reports@feeds.example.com"}, identified)
+	require.NoError(t, err)
+	err = send("alerts@example.com", []string{"reports@feeds.example.com", "archive@feeds.example.com"}, identified)
+	require.NoError(t, err)
+
+	items, err = s.ListItems(ctx, "reports", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+	items, err = s.ListItems(ctx, "archive", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+	mu.Lock()
+	assert.Equal(t, []string{"reports", "archive", "reports", "reports", "archive"}, notified)
+	mu.Unlock()
+}
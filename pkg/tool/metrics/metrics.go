@@ -0,0 +1,351 @@
+// Package metrics exposes Prometheus metrics for item ingest, feed serving and jobs
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+const namespace = "webhook_rss"
+
+// Metrics holds the tool's collectors. They're registered with their own registry, rather than the global one, so
+// that they're only exposed by the tool's handler.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requestsRejected *prometheus.CounterVec
+	feedGets         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+
+	jobRuns          *prometheus.CounterVec
+	jobDuration      *prometheus.HistogramVec
+	jobLastRun       *prometheus.GaugeVec
+	jobLastSuccess   *prometheus.GaugeVec
+	jobLastSucceeded *prometheus.GaugeVec
+
+	feeds *feedCollector
+}
+
+// New returns metrics for the tool, item counts and ages are loaded from items each time metrics are collected.
+// Metrics labelled by feed leave out private feeds, so their names and activity aren't shown to scrapers.
+func New(items store.ItemStore, secrets store.SecretStore) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+
+		requestsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "item_requests_rejected_total",
+			Help:      "Requests to create items which were rejected, by reason.",
+		}, []string{"reason"}),
+		feedGets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "feed_requests_total",
+			Help:      "Requests to read feeds, by format and response status.",
+		}, []string{"format", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to respond to HTTP requests, by route, method and response status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+
+		jobRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_runs_total",
+			Help:      "Job runs, by job and outcome: success, failure or timeout.",
+		}, []string{"job", "outcome"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_duration_seconds",
+			Help:      "Time taken by job runs, by job.",
+			Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"job"}),
+		jobLastRun: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "job_last_run_timestamp_seconds",
+			Help:      "Time the job last finished, as a unix timestamp.",
+		}, []string{"job"}),
+		jobLastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "job_last_success_timestamp_seconds",
+			Help:      "Time the job last finished without error, as a unix timestamp.",
+		}, []string{"job"}),
+		jobLastSucceeded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "job_last_run_success",
+			Help:      "1 if the job's last run succeeded, otherwise 0.",
+		}, []string{"job"}),
+
+		feeds: &feedCollector{
+			items:   items,
+			secrets: secrets,
+			created: make(map[string]float64),
+			maxAges: make(map[string]float64),
+		},
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestsRejected,
+		m.feedGets,
+		m.requestDuration,
+		m.jobRuns,
+		m.jobDuration,
+		m.jobLastRun,
+		m.jobLastSuccess,
+		m.jobLastSucceeded,
+		m.feeds,
+	)
+
+	return m
+}
+
+// Handler returns the handler serving metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// ItemsCreated counts items created in a feed, it has the signature of an item created hook
+func (m *Metrics) ItemsCreated(ctx context.Context, feed string, items []store.Item) {
+	m.feeds.mu.Lock()
+	defer m.feeds.mu.Unlock()
+
+	m.feeds.created[feed] += float64(len(items))
+}
+
+// RequestRejected counts a request to create items which was rejected
+func (m *Metrics) RequestRejected(reason string) {
+	m.requestsRejected.WithLabelValues(reason).Inc()
+}
+
+// SetFeedMaxAge records the max age configured for a feed, so it can be compared with the age of its newest item
+func (m *Metrics) SetFeedMaxAge(feed string, maxAge time.Duration) {
+	m.feeds.mu.Lock()
+	defer m.feeds.mu.Unlock()
+
+	m.feeds.maxAges[feed] = maxAge.Seconds()
+}
+
+// Middleware records the duration of requests by route. Event streams are skipped, as they're open until the client
+// disconnects.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.Header().Get("Content-Type") == "text/event-stream" {
+			return
+		}
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		m.requestDuration.
+			WithLabelValues(route, r.Method, strconv.Itoa(rec.statusCode())).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// InstrumentFeedGet wraps a handler serving a feed, counting requests by format and status
+func (m *Metrics) InstrumentFeedGet(format string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next(rec, r)
+
+		m.feedGets.WithLabelValues(format, strconv.Itoa(rec.statusCode())).Inc()
+	}
+}
+
+// InstrumentJob wraps a job, recording the outcome and duration of each run
+func (m *Metrics) InstrumentJob(job apis.Job) apis.Job {
+	return &instrumentedJob{Job: job, metrics: m}
+}
+
+type instrumentedJob struct {
+	apis.Job
+	metrics *Metrics
+}
+
+func (j *instrumentedJob) Run(ctx context.Context) error {
+	start := time.Now()
+
+	err := j.Job.Run(ctx)
+
+	name := j.Name()
+	j.metrics.jobRuns.WithLabelValues(name, jobs.Outcome(err)).Inc()
+	j.metrics.jobDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	j.metrics.jobLastRun.WithLabelValues(name).SetToCurrentTime()
+	if err == nil {
+		j.metrics.jobLastSuccess.WithLabelValues(name).SetToCurrentTime()
+		j.metrics.jobLastSucceeded.WithLabelValues(name).Set(1)
+	} else {
+		j.metrics.jobLastSucceeded.WithLabelValues(name).Set(0)
+	}
+
+	return err
+}
+
+// feedCollector reports metrics labelled by feed. Item counts and newest item ages are loaded when metrics are
+// collected so that they're correct on every replica. Feeds are checked when collected too, so that a feed made
+// private is left out from then on.
+type feedCollector struct {
+	items   store.ItemStore
+	secrets store.SecretStore
+
+	// mu guards the items created and max ages recorded by this replica
+	mu      sync.Mutex
+	created map[string]float64
+	maxAges map[string]float64
+}
+
+var (
+	itemsCreatedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "items_created_total"),
+		"Items created, by feed.",
+		[]string{"feed"}, nil,
+	)
+	feedMaxAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "feed_max_age_seconds"),
+		"The max age of a feed's newest item checked by the feed-check job.",
+		[]string{"feed"}, nil,
+	)
+	feedItemsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "feed_items"),
+		"Items in a feed, including those scheduled for the future.",
+		[]string{"feed"}, nil,
+	)
+	feedNewestItemAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "feed_newest_item_age_seconds"),
+		"Time since the date of a feed's newest item.",
+		[]string{"feed"}, nil,
+	)
+)
+
+func (c *feedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- itemsCreatedDesc
+	ch <- feedMaxAgeDesc
+	ch <- feedItemsDesc
+	ch <- feedNewestItemAgeDesc
+}
+
+func (c *feedCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stats, err := c.items.FeedStats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(feedItemsDesc, err)
+		return
+	}
+
+	private := make(map[string]bool)
+	public := func(feed string) bool {
+		p, ok := private[feed]
+		if !ok {
+			var err error
+			p, err = c.secrets.FeedPrivate(ctx, feed)
+			if err != nil {
+				// feeds which can't be checked are left out, rather than risk showing a private one
+				p = true
+			}
+			private[feed] = p
+		}
+		return !p
+	}
+
+	now := time.Now()
+	for _, s := range stats {
+		if !public(s.Feed) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(feedItemsDesc, prometheus.GaugeValue, float64(s.Count), s.Feed)
+		// feeds with only scheduled or expired items have no newest item
+		if s.LatestAt.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			feedNewestItemAgeDesc, prometheus.GaugeValue, now.Sub(s.LatestAt).Seconds(), s.Feed,
+		)
+	}
+
+	// the recorded values are copied so that items can be counted while feeds are checked
+	c.mu.Lock()
+	created := make(map[string]float64, len(c.created))
+	for feed, count := range c.created {
+		created[feed] = count
+	}
+	maxAges := make(map[string]float64, len(c.maxAges))
+	for feed, maxAge := range c.maxAges {
+		maxAges[feed] = maxAge
+	}
+	c.mu.Unlock()
+
+	for feed, count := range created {
+		if public(feed) {
+			ch <- prometheus.MustNewConstMetric(itemsCreatedDesc, prometheus.CounterValue, count, feed)
+		}
+	}
+	for feed, maxAge := range maxAges {
+		if public(feed) {
+			ch <- prometheus.MustNewConstMetric(feedMaxAgeDesc, prometheus.GaugeValue, maxAge, feed)
+		}
+	}
+}
+
+// statusRecorder records the status of a response. It passes flushes through so it can wrap streaming handlers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, so that handlers can find the connection to hijack
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+type testJob struct {
+	err error
+}
+
+func (j *testJob) Name() string                  { return "test" }
+func (j *testJob) Run(ctx context.Context) error { return j.err }
+func (j *testJob) Timeout() time.Duration        { return time.Second }
+func (j *testJob) Schedule() string              { return "0 0 * * * *" }
+
+func TestInstrumentJob(t *testing.T) {
+	s := store.NewMemory()
+	m := New(s, s)
+
+	job := &testJob{}
+	instrumented := m.InstrumentJob(job)
+	assert.Equal(t, "test", instrumented.Name())
+	assert.Equal(t, "0 0 * * * *", instrumented.Schedule())
+
+	require.NoError(t, instrumented.Run(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.jobRuns.WithLabelValues("test", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.jobLastSucceeded.WithLabelValues("test")))
+
+	job.err = errors.New("failed")
+	require.Error(t, instrumented.Run(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.jobRuns.WithLabelValues("test", "failure")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.jobLastSucceeded.WithLabelValues("test")))
+
+	job.err = context.DeadlineExceeded
+	require.Error(t, instrumented.Run(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.jobRuns.WithLabelValues("test", "timeout")))
+}
+
+func TestHandler(t *testing.T) {
+	s := store.NewMemory()
+	_, err := s.InsertItems(context.Background(), "example", []store.NewItem{
+		{GUID: "a", Title: "old", CreatedAt: time.Now().Add(-time.Hour)},
+	}, store.InsertOptions{})
+	require.NoError(t, err)
+
+	m := New(s, s)
+	m.RequestRejected("too_large")
+	m.SetFeedMaxAge("example", 2*time.Hour)
+	m.ItemsCreated(context.Background(), "example", []store.Item{{Feed: "example"}})
+
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.HandleFunc("/feeds/{feed}.rss", m.InstrumentFeedGet("rss", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	router.Handle("/metrics", m.Handler())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/feeds/private.rss", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `webhook_rss_item_requests_rejected_total{reason="too_large"} 1`)
+	assert.Contains(t, body, `webhook_rss_feed_requests_total{format="rss",status="404"} 1`)
+	assert.Contains(t, body, `webhook_rss_http_request_duration_seconds_count{method="GET",route="/feeds/{feed}.rss",status="404"} 1`)
+	assert.Contains(t, body, `webhook_rss_feed_items{feed="example"} 1`)
+	assert.Contains(t, body, `webhook_rss_feed_max_age_seconds{feed="example"} 7200`)
+	assert.Contains(t, body, `webhook_rss_items_created_total{feed="example"} 1`)
+
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, `webhook_rss_feed_newest_item_age_seconds{feed="example"}`) {
+			return
+		}
+	}
+	t.Fatal("newest item age missing")
+}
+
+func TestHandlerPrivateFeeds(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	for _, feed := range []string{"public", "private"} {
+		_, err := s.InsertItems(ctx, feed, []store.NewItem{{GUID: "a", Title: "item"}}, store.InsertOptions{})
+		require.NoError(t, err)
+	}
+	_, err := s.CreateSecret(ctx, "private", "reader", "hash")
+	require.NoError(t, err)
+
+	m := New(s, s)
+	for _, feed := range []string{"public", "private"} {
+		m.SetFeedMaxAge(feed, time.Hour)
+		m.ItemsCreated(ctx, feed, []store.Item{{Feed: feed}})
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `webhook_rss_feed_items{feed="public"} 1`)
+	assert.Contains(t, body, `webhook_rss_items_created_total{feed="public"} 1`)
+	assert.NotContains(t, body, `feed="private"`, "private feeds shouldn't be labelled")
+
+	// feeds made public again are shown
+	require.NoError(t, s.SetFeedPrivate(ctx, "private", false))
+
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `webhook_rss_feed_items{feed="private"} 1`)
+}
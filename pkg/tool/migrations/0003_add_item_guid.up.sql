@@ -0,0 +1,10 @@
+SET search_path TO webhookrss, public;
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS guid TEXT;
+
+-- existing items are given a stable, unique identifier derived from their id
+UPDATE items SET guid = md5(feed || '/' || id::text) WHERE guid IS NULL;
+
+ALTER TABLE items ALTER COLUMN guid SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS feed_guid_idx ON items(feed, guid);
@@ -0,0 +1,11 @@
+SET search_path TO webhookrss, public;
+
+-- feed_usage tracks the bytes ingested by each feed per UTC day so that daily quotas can be enforced
+-- independently of items being removed by the clean job.
+CREATE TABLE IF NOT EXISTS feed_usage (
+  feed TEXT NOT NULL,
+  day DATE NOT NULL,
+  bytes BIGINT NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (feed, day)
+);
@@ -0,0 +1,3 @@
+SET search_path TO webhookrss, public;
+
+DROP TABLE IF EXISTS rate_limit_buckets;
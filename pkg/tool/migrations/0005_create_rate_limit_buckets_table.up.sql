@@ -0,0 +1,8 @@
+SET search_path TO webhookrss, public;
+
+-- rate_limit_buckets holds token bucket state when rate limits are shared between replicas
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+  key TEXT NOT NULL PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
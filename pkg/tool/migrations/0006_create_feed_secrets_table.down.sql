@@ -0,0 +1,4 @@
+SET search_path TO webhookrss, public;
+
+DROP INDEX IF EXISTS feed_secrets_feed_idx;
+DROP TABLE IF EXISTS feed_secrets;
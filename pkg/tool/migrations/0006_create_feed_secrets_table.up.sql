@@ -0,0 +1,15 @@
+SET search_path TO webhookrss, public;
+
+-- feed_secrets holds hashes of the read secrets for private feeds. Feeds without any active secrets are public.
+CREATE TABLE IF NOT EXISTS feed_secrets (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  feed TEXT NOT NULL CONSTRAINT feed_present CHECK ((feed != '') IS TRUE),
+  label TEXT NOT NULL DEFAULT '',
+  secret_hash TEXT NOT NULL UNIQUE,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS feed_secrets_feed_idx ON feed_secrets(feed);
@@ -0,0 +1,19 @@
+SET search_path TO webhookrss, public;
+
+-- websub_subscriptions holds verified WebSub subscriptions, subscribers are sent feed content when items are created
+CREATE TABLE IF NOT EXISTS websub_subscriptions (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  feed TEXT NOT NULL,
+  topic TEXT NOT NULL,
+  callback TEXT NOT NULL,
+  secret TEXT NOT NULL DEFAULT '',
+
+  lease_seconds BIGINT NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  UNIQUE (topic, callback)
+);
+
+CREATE INDEX IF NOT EXISTS websub_subscriptions_feed_idx ON websub_subscriptions(feed);
@@ -0,0 +1,3 @@
+SET search_path TO webhookrss, public;
+
+ALTER TABLE items DROP COLUMN IF EXISTS tags;
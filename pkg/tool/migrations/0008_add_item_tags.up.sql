@@ -0,0 +1,4 @@
+SET search_path TO webhookrss, public;
+
+-- tags are stored as a comma separated list
+ALTER TABLE items ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '';
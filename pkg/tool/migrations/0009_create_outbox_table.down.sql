@@ -0,0 +1,4 @@
+SET search_path TO webhookrss, public;
+
+DROP INDEX IF EXISTS outbox_status_next_attempt_at_idx;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,23 @@
+SET search_path TO webhookrss, public;
+
+-- outbox holds items waiting to be forwarded to fan-out targets, and a record of those which have been sent
+CREATE TABLE IF NOT EXISTS outbox (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  target TEXT NOT NULL,
+  feed TEXT NOT NULL,
+  item_id BIGINT NOT NULL,
+
+  content_type TEXT NOT NULL,
+  body TEXT NOT NULL,
+
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  next_attempt_at TIMESTAMPTZ NOT NULL,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS outbox_status_next_attempt_at_idx ON outbox(status, next_attempt_at);
@@ -0,0 +1,4 @@
+SET search_path TO webhookrss, public;
+
+DROP TABLE IF EXISTS poll_seen_entries;
+DROP TABLE IF EXISTS poll_sources;
@@ -0,0 +1,23 @@
+SET search_path TO webhookrss, public;
+
+-- poll_sources holds the state of each external feed polled into a local feed
+CREATE TABLE IF NOT EXISTS poll_sources (
+  source TEXT NOT NULL PRIMARY KEY,
+
+  etag TEXT NOT NULL DEFAULT '',
+  last_modified TEXT NOT NULL DEFAULT '',
+
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  last_polled_at TIMESTAMPTZ,
+  next_poll_at TIMESTAMPTZ NOT NULL
+);
+
+-- poll_seen_entries records the entries seen in each source, so they're only inserted once
+CREATE TABLE IF NOT EXISTS poll_seen_entries (
+  source TEXT NOT NULL,
+  guid TEXT NOT NULL,
+  seen_at TIMESTAMPTZ NOT NULL,
+
+  PRIMARY KEY (source, guid)
+);
@@ -0,0 +1,18 @@
+SET search_path TO webhookrss, public;
+
+-- item_attachments holds files attached to items, they're linked from feeds as enclosures
+CREATE TABLE IF NOT EXISTS item_attachments (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  feed TEXT NOT NULL,
+  item_id INTEGER NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+
+  filename TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size BIGINT NOT NULL,
+  data BYTEA NOT NULL,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS item_attachments_item_idx ON item_attachments(item_id);
@@ -0,0 +1,9 @@
+SET search_path TO webhookrss, public;
+
+-- digests records how far through its source feed each digest has got
+CREATE TABLE IF NOT EXISTS digests (
+  name TEXT NOT NULL PRIMARY KEY,
+
+  last_item_id BIGINT NOT NULL,
+  last_digest_at TIMESTAMPTZ NOT NULL
+);
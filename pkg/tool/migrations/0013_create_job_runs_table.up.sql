@@ -0,0 +1,16 @@
+SET search_path TO webhookrss, public;
+
+-- job_runs records the history of each job's runs, old runs are pruned by the clean job
+CREATE TABLE IF NOT EXISTS job_runs (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  job TEXT NOT NULL,
+  started_at TIMESTAMPTZ NOT NULL,
+  finished_at TIMESTAMPTZ NOT NULL,
+
+  outcome TEXT NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  stats TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_job_started_at_idx ON job_runs(job, started_at);
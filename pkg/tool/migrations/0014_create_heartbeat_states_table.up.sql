@@ -0,0 +1,9 @@
+SET search_path TO webhookrss, public;
+
+-- heartbeat_states records whether each heartbeat was last up or down, so that changes can be reported
+CREATE TABLE IF NOT EXISTS heartbeat_states (
+  name TEXT NOT NULL PRIMARY KEY,
+
+  status TEXT NOT NULL,
+  changed_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+SET search_path TO webhookrss, public;
+
+DROP INDEX IF EXISTS items_expires_at_idx;
+ALTER TABLE items DROP COLUMN IF EXISTS expires_at;
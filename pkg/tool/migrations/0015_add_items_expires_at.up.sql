@@ -0,0 +1,6 @@
+SET search_path TO webhookrss, public;
+
+-- expires_at is when an item is removed from its feed, it's null for items which don't expire
+ALTER TABLE items ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS items_expires_at_idx ON items(expires_at);
@@ -0,0 +1,4 @@
+SET search_path TO webhookrss, public;
+
+ALTER TABLE items DROP COLUMN IF EXISTS pinned;
+ALTER TABLE items DROP COLUMN IF EXISTS priority;
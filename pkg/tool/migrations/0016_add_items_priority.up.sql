@@ -0,0 +1,6 @@
+SET search_path TO webhookrss, public;
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal';
+
+-- pinned items are listed first in feeds and aren't removed when feeds are trimmed
+ALTER TABLE items ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT false;
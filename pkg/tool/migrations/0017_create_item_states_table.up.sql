@@ -0,0 +1,18 @@
+SET search_path TO webhookrss, public;
+
+-- item_states holds each user's read, starred and archived state for items
+CREATE TABLE IF NOT EXISTS item_states (
+  username TEXT NOT NULL,
+  item_id INTEGER NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  feed TEXT NOT NULL,
+
+  read BOOLEAN NOT NULL DEFAULT false,
+  starred BOOLEAN NOT NULL DEFAULT false,
+  archived BOOLEAN NOT NULL DEFAULT false,
+
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (username, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS item_states_item_idx ON item_states(item_id);
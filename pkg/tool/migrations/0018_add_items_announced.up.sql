@@ -0,0 +1,8 @@
+SET search_path TO webhookrss, public;
+
+-- announced is false for scheduled items until the publish job has run the new item hooks for them
+ALTER TABLE items ADD COLUMN IF NOT EXISTS announced BOOLEAN NOT NULL DEFAULT true;
+
+UPDATE items SET announced = false WHERE created_at > NOW();
+
+CREATE INDEX IF NOT EXISTS items_unannounced_idx ON items(created_at) WHERE NOT announced;
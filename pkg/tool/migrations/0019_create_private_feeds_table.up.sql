@@ -0,0 +1,14 @@
+SET search_path TO webhookrss, public;
+
+-- private_feeds holds the feeds which need a read secret. Feeds are made private when their first secret is issued
+-- and stay private when their secrets are revoked.
+CREATE TABLE IF NOT EXISTS private_feeds (
+  feed TEXT NOT NULL PRIMARY KEY CONSTRAINT feed_present CHECK ((feed != '') IS TRUE),
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- feeds with active secrets were private before this table was added
+INSERT INTO private_feeds (feed)
+SELECT DISTINCT feed FROM feed_secrets WHERE revoked_at IS NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+SET search_path TO webhookrss, public;
+
+ALTER TABLE digests DROP COLUMN IF EXISTS included_item_ids;
@@ -0,0 +1,5 @@
+SET search_path TO webhookrss, public;
+
+-- included_item_ids lists source items after last_item_id which have been included in a digest, these were
+-- published while an earlier item was still scheduled
+ALTER TABLE digests ADD COLUMN IF NOT EXISTS included_item_ids TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,249 @@
+// Package poll mirrors external RSS, Atom and JSON feeds into local feeds
+package poll
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// Source is an external feed which is polled into a local feed
+type Source struct {
+	Name string
+	URL  string
+	// Feed is the local feed new entries are inserted into
+	Feed string
+	// Interval is the time between polls, defaults to 15 minutes. Failing sources are polled less often.
+	Interval time.Duration
+	// Tags are added to each item created from the source
+	Tags []string
+}
+
+// Poller fetches the sources which are due and inserts any new entries
+type Poller struct {
+	Sources []*Source
+	Items   store.ItemStore
+	State   store.PollStore
+
+	// OnCreated is called with the items created from each source
+	OnCreated []func(ctx context.Context, feed string, items []store.Item)
+
+	// InitialItems limits the entries inserted the first time a source is polled, so that adding a source with a
+	// long history doesn't flood the feed. Defaults to 10.
+	InitialItems int
+
+	Client *http.Client
+}
+
+// Poll fetches each source which is due. Failures fetching a source are recorded in its state and delay its next
+// poll, an error is only returned when the state can't be loaded or saved.
+func (p *Poller) Poll(ctx context.Context) error {
+	for _, src := range p.Sources {
+		state, found, err := p.State.GetPollState(ctx, src.Name)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if found && state.NextPollAt.After(now) {
+			continue
+		}
+
+		state.Source = src.Name
+		state.LastPolledAt = &now
+
+		err = p.pollSource(ctx, src, &state, !found)
+		if err != nil {
+			state.Failures++
+			state.LastError = err.Error()
+		} else {
+			state.Failures = 0
+			state.LastError = ""
+		}
+		state.NextPollAt = now.Add(Backoff(src.interval(), state.Failures))
+
+		err = p.State.SavePollState(ctx, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Poller) pollSource(ctx context.Context, src *Source, state *store.PollState, first bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("User-Agent", "webhook-rss")
+	if state.ETag != "" {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	if state.LastModified != "" {
+		req.Header.Set("If-Modified-Since", state.LastModified)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch source: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		// the entries are still present in the source, so they mustn't be forgotten
+		return p.State.TouchSeenEntries(ctx, src.Name, time.Now())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	parsed, err := gofeed.NewParser().Parse(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	state.ETag = resp.Header.Get("ETag")
+	state.LastModified = resp.Header.Get("Last-Modified")
+
+	entries := make(map[string]*gofeed.Item)
+	var guids []string
+	for _, entry := range parsed.Items {
+		guid := entryGUID(src.Name, entry)
+		if _, ok := entries[guid]; ok {
+			continue
+		}
+		entries[guid] = entry
+		guids = append(guids, guid)
+	}
+
+	unseen, err := p.State.UnseenEntries(ctx, src.Name, guids)
+	if err != nil {
+		return err
+	}
+
+	var newItems []store.NewItem
+	for _, guid := range unseen {
+		newItems = append(newItems, newItem(guid, entries[guid], src.Tags))
+	}
+
+	// entries are inserted oldest first, so item ids follow the order of the source
+	sort.SliceStable(newItems, func(i, j int) bool {
+		return newItems[i].CreatedAt.Before(newItems[j].CreatedAt)
+	})
+
+	initialItems := p.InitialItems
+	if initialItems == 0 {
+		initialItems = 10
+	}
+	if first && len(newItems) > initialItems {
+		newItems = newItems[len(newItems)-initialItems:]
+	}
+
+	if len(newItems) > 0 {
+		created, err := p.Items.InsertItems(ctx, src.Feed, newItems, store.InsertOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to insert items: %w", err)
+		}
+
+		for _, fn := range p.OnCreated {
+			fn(ctx, src.Feed, created)
+		}
+	}
+
+	return p.State.MarkEntriesSeen(ctx, src.Name, guids, time.Now())
+}
+
+func (s *Source) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return 15 * time.Minute
+}
+
+// Backoff returns the time until a source is next polled. The interval is doubled for each consecutive failure, up
+// to a day, or the interval if that's longer.
+func Backoff(interval time.Duration, failures int) time.Duration {
+	limit := 24 * time.Hour
+	if interval > limit {
+		limit = interval
+	}
+
+	delay := interval
+	for i := 0; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+
+	return delay
+}
+
+// entryGUID returns a stable identifier for an entry, used as the guid of the item created from it. Entries are
+// identified by their guid, falling back to their link, and finally their title and date.
+func entryGUID(source string, entry *gofeed.Item) string {
+	id := entry.GUID
+	if id == "" {
+		id = entry.Link
+	}
+	if id == "" {
+		id = entry.Title + "\n" + entry.Published + entry.Updated
+	}
+
+	sum := sha256.Sum256([]byte(source + "\n" + id))
+
+	return hex.EncodeToString(sum[:16])
+}
+
+// newItem converts an entry into an item, keeping within the limits applied to items sent to the create handler
+func newItem(guid string, entry *gofeed.Item, tags []string) store.NewItem {
+	title := strings.TrimSpace(entry.Title)
+	if title == "" {
+		title = entry.Link
+	}
+	if title == "" {
+		title = "Untitled"
+	}
+
+	body := entry.Description
+	if body == "" {
+		body = entry.Content
+	}
+
+	item := store.NewItem{
+		GUID:      guid,
+		Title:     store.Truncate(title, store.MaxTitleBytes),
+		Body:      store.Truncate(body, store.MaxBodyBytes),
+		URL:       entry.Link,
+		Tags:      append(store.Tags{}, tags...),
+		CreatedAt: time.Now(),
+	}
+
+	if entry.PublishedParsed != nil {
+		item.CreatedAt = *entry.PublishedParsed
+	} else if entry.UpdatedParsed != nil {
+		item.CreatedAt = *entry.UpdatedParsed
+	}
+
+	return item
+}
@@ -0,0 +1,135 @@
+package poll
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+func rssFeed(entries ...string) string {
+	var items strings.Builder
+	for i, entry := range entries {
+		fmt.Fprintf(
+			&items,
+			"<item><title>%s</title><guid>%s</guid><link>https://example.com/%s</link><pubDate>%s</pubDate></item>",
+			entry,
+			entry,
+			entry,
+			time.Date(2022, 10, 1+i, 0, 0, 0, 0, time.UTC).Format(time.RFC1123Z),
+		)
+	}
+
+	return `<?xml version="1.0"?><rss version="2.0"><channel><title>upstream</title>` + items.String() +
+		`</channel></rss>`
+}
+
+func TestPoller(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	body := rssFeed("a", "b", "c")
+	status := http.StatusOK
+	var conditional bool
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		etag := fmt.Sprintf(`"%d"`, len(body))
+		if r.Header.Get("If-None-Match") == etag {
+			conditional = true
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	var hookItems []store.Item
+	src := &Source{Name: "upstream", URL: upstream.URL, Feed: "mirror", Interval: time.Minute, Tags: []string{"mirrored"}}
+	poller := &Poller{
+		Sources:      []*Source{src},
+		Items:        s,
+		State:        s,
+		InitialItems: 2,
+		OnCreated: []func(ctx context.Context, feed string, items []store.Item){
+			func(ctx context.Context, feed string, items []store.Item) { hookItems = append(hookItems, items...) },
+		},
+	}
+
+	// due is used to make the source due for polling again
+	due := func() {
+		state, _, err := s.GetPollState(ctx, "upstream")
+		require.NoError(t, err)
+		state.NextPollAt = time.Now().Add(-time.Second)
+		require.NoError(t, s.SavePollState(ctx, state))
+	}
+
+	require.NoError(t, poller.Poll(ctx))
+
+	items, err := s.ListItems(ctx, "mirror", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 2, "only the newest entries should be inserted on the first poll")
+	assert.Equal(t, "c", items[0].Title)
+	assert.Equal(t, "https://example.com/c", items[0].URL)
+	assert.Equal(t, store.Tags{"mirrored"}, items[0].Tags)
+	assert.Equal(t, time.Date(2022, 10, 3, 0, 0, 0, 0, time.UTC), items[0].CreatedAt)
+	assert.Len(t, hookItems, 2)
+
+	// sources aren't polled again until they're due
+	mu.Lock()
+	body = rssFeed("a", "b", "c", "d")
+	mu.Unlock()
+	require.NoError(t, poller.Poll(ctx))
+	items, err = s.ListItems(ctx, "mirror", store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	due()
+	require.NoError(t, poller.Poll(ctx))
+	items, err = s.ListItems(ctx, "mirror", store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, items, 3, "only new entries should be inserted")
+	assert.Equal(t, "d", items[0].Title)
+
+	due()
+	require.NoError(t, poller.Poll(ctx))
+	assert.True(t, conditional, "the etag should be sent")
+
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+
+	due()
+	require.NoError(t, poller.Poll(ctx))
+	state, _, err := s.GetPollState(ctx, "upstream")
+	require.NoError(t, err)
+	assert.Equal(t, 1, state.Failures)
+	assert.Contains(t, state.LastError, "500")
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), state.NextPollAt, 5*time.Second, "failing sources should back off")
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 15*time.Minute, Backoff(15*time.Minute, 0))
+	assert.Equal(t, 60*time.Minute, Backoff(15*time.Minute, 2))
+	assert.Equal(t, 24*time.Hour, Backoff(15*time.Minute, 20))
+	assert.Equal(t, 48*time.Hour, Backoff(48*time.Hour, 3))
+}
@@ -0,0 +1,169 @@
+// Package poster sends items to webhook-rss feeds over HTTP. It's used by the jobs which post pulses and alerts, so
+// that they share timeouts, retries and authentication.
+package poster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Poster posts to HTTP endpoints. Any 2xx response is a success. Network errors and 5xx responses are retried with
+// backoff until the attempts run out or the context is done. The zero value is ready to use.
+type Poster struct {
+	// Client sends the requests, defaults to http.DefaultClient. Each attempt is limited by Timeout.
+	Client *http.Client
+	// Timeout limits each attempt, defaults to 10 seconds
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts made before giving up, defaults to 3
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry, defaults to 1 second. It doubles for each retry and up to
+	// half again is added at random.
+	RetryDelay time.Duration
+	// Token is sent as a bearer token when set, for endpoints which require authentication
+	Token string
+}
+
+// Item is an item posted to a feed's items endpoint
+type Item struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+}
+
+// StatusError is returned when an endpoint responds with a status other than 2xx
+type StatusError struct {
+	StatusCode int
+	// Body is the start of the response body, it often explains the failure
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected response status: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected response status: %d: %s", e.StatusCode, e.Body)
+}
+
+// PostItems posts items as JSON to a feed's items endpoint
+func (p *Poster) PostItems(ctx context.Context, endpoint string, items ...Item) error {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to form item JSON: %w", err)
+	}
+
+	return p.Post(ctx, endpoint, "application/json; charset=utf-8", b)
+}
+
+// Post sends body to the endpoint, retrying network errors and 5xx responses
+func (p *Poster) Post(ctx context.Context, endpoint, contentType string, body []byte) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = p.post(ctx, endpoint, contentType, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) || attempt >= p.maxAttempts() {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w, giving up: %s", err, ctx.Err())
+		case <-time.After(p.backoff(attempt)):
+		}
+	}
+
+	return err
+}
+
+func (p *Poster) post(ctx context.Context, endpoint, contentType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to build request: %w", err)}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		// the body is drained so that the connection can be reused
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+	}
+
+	return nil
+}
+
+// permanentError wraps errors which won't be fixed by retrying
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func retryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500
+	}
+
+	return true
+}
+
+func (p *Poster) backoff(attempt int) time.Duration {
+	delay := p.RetryDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (p *Poster) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return 10 * time.Second
+}
+
+func (p *Poster) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
@@ -0,0 +1,91 @@
+package poster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostItems(t *testing.T) {
+	var body, auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	p := &Poster{Token: "abc123"}
+	err := p.PostItems(context.Background(), srv.URL, Item{Title: "Dead Man Pulse"})
+	require.NoError(t, err, "any 2xx response should be a success")
+
+	assert.JSONEq(t, `[{"title": "Dead Man Pulse", "body": "", "url": ""}]`, body)
+	assert.Equal(t, "Bearer abc123", auth)
+	assert.Equal(t, "application/json; charset=utf-8", contentType)
+}
+
+func TestPostRetries(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	p := &Poster{RetryDelay: time.Millisecond}
+	require.NoError(t, p.Post(context.Background(), srv.URL, "text/plain", []byte("a")))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&attempts, -10)
+	err := p.Post(context.Background(), srv.URL, "text/plain", []byte("a"))
+	var status *StatusError
+	require.True(t, errors.As(err, &status))
+	assert.Equal(t, http.StatusServiceUnavailable, status.StatusCode)
+	assert.Equal(t, "unavailable", status.Body)
+	assert.Equal(t, int32(-7), atomic.LoadInt32(&attempts), "attempts should be limited")
+}
+
+func TestPostDoesNotRetryClientErrors(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	p := &Poster{RetryDelay: time.Millisecond}
+	err := p.Post(context.Background(), srv.URL, "text/plain", []byte("a"))
+	assert.EqualError(t, err, "unexpected response status: 401")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestPostContext(t *testing.T) {
+	release := make(chan bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	p := &Poster{RetryDelay: time.Second}
+	err := p.Post(ctx, srv.URL, "text/plain", []byte("a"))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second, "the request should be cancelled with the context")
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Memory is a Limiter which holds bucket state in process. It's suitable when only a single replica of the tool
+// is running.
+type Memory struct {
+	rate Rate
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+
+	// now is used in place of time.Now in tests
+	now func() time.Time
+}
+
+// NewMemory returns an in process Limiter with the given rate
+func NewMemory(rate Rate) *Memory {
+	return &Memory{
+		rate:    rate,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	m.prune(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: m.rate.Burst, updatedAt: now}
+		m.buckets[key] = b
+	}
+
+	tokens, allowed, wait := m.rate.take(b.tokens, now.Sub(b.updatedAt))
+	b.tokens = tokens
+	b.updatedAt = now
+
+	return allowed, wait, nil
+}
+
+func (m *Memory) Peek(ctx context.Context, key string) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		allowed, wait := m.rate.available(m.rate.Burst)
+		return allowed, wait, nil
+	}
+
+	allowed, wait := m.rate.peek(b.tokens, m.now().Sub(b.updatedAt))
+
+	return allowed, wait, nil
+}
+
+// prune removes buckets which would have refilled completely, these are equivalent to new buckets
+func (m *Memory) prune(now time.Time) {
+	if now.Sub(m.pruned) < time.Minute {
+		return
+	}
+	m.pruned = now
+
+	for key, b := range m.buckets {
+		tokens, _, _ := m.rate.take(b.tokens, now.Sub(b.updatedAt))
+		if tokens+1 >= m.rate.Burst {
+			delete(m.buckets, key)
+		}
+	}
+}
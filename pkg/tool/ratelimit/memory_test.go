@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC)
+
+	limiter := NewMemory(Rate{PerMinute: 60, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	// the bucket starts full, so the burst is allowed immediately
+	for i := 0; i < 3; i++ {
+		allowed, _, err := limiter.Allow(ctx, "feed:example")
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d should be allowed", i)
+	}
+
+	allowed, wait, err := limiter.Allow(ctx, "feed:example")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, wait)
+
+	// other keys have their own bucket
+	allowed, _, err = limiter.Allow(ctx, "feed:other")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// one token is added each second
+	now = now.Add(time.Second)
+	allowed, _, err = limiter.Allow(ctx, "feed:example")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, _, err = limiter.Allow(ctx, "feed:example")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// peeking doesn't take a token
+	now = now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		allowed, _, err = limiter.Peek(ctx, "feed:example")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	allowed, _, err = limiter.Allow(ctx, "feed:example")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, wait, err = limiter.Peek(ctx, "feed:example")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, wait)
+
+	allowed, _, err = limiter.Peek(ctx, "feed:new")
+	require.NoError(t, err)
+	assert.True(t, allowed, "new buckets start full")
+}
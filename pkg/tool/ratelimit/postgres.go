@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Postgres is a Limiter which holds bucket state in the database so that limits are shared between replicas
+type Postgres struct {
+	rate Rate
+	db   *sql.DB
+}
+
+// NewPostgres returns a Limiter with the given rate backed by the webhookrss.rate_limit_buckets table
+func NewPostgres(db *sql.DB, rate Rate) *Postgres {
+	return &Postgres{
+		rate: rate,
+		db:   db,
+	}
+}
+
+func (p *Postgres) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// new buckets start full
+	_, err = tx.ExecContext(ctx, `
+insert into webhookrss.rate_limit_buckets (key, tokens, updated_at)
+values ($1, $2, now())
+on conflict (key) do nothing`, key, p.rate.Burst)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to create bucket: %w", err)
+	}
+
+	// the database clock is used so that replicas agree on the time elapsed
+	var tokens, elapsedSeconds float64
+	err = tx.QueryRowContext(ctx, `
+select tokens, extract(epoch from (now() - updated_at))
+from webhookrss.rate_limit_buckets
+where key = $1
+for update`, key).Scan(&tokens, &elapsedSeconds)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to load bucket: %w", err)
+	}
+
+	tokens, allowed, wait := p.rate.take(tokens, time.Duration(elapsedSeconds*float64(time.Second)))
+
+	_, err = tx.ExecContext(ctx, `
+update webhookrss.rate_limit_buckets set tokens = $2, updated_at = now() where key = $1`, key, tokens)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to update bucket: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to commit bucket: %w", err)
+	}
+
+	return allowed, wait, nil
+}
+
+func (p *Postgres) Peek(ctx context.Context, key string) (bool, time.Duration, error) {
+	var tokens, elapsedSeconds float64
+	err := p.db.QueryRowContext(ctx, `
+select tokens, extract(epoch from (now() - updated_at))
+from webhookrss.rate_limit_buckets
+where key = $1`, key).Scan(&tokens, &elapsedSeconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		// buckets which don't exist yet would be created full
+		allowed, wait := p.rate.available(p.rate.Burst)
+		return allowed, wait, nil
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to load bucket: %w", err)
+	}
+
+	allowed, wait := p.rate.peek(tokens, time.Duration(elapsedSeconds*float64(time.Second)))
+
+	return allowed, wait, nil
+}
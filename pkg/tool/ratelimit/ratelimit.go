@@ -0,0 +1,64 @@
+// Package ratelimit provides token bucket rate limiters used to protect the ingest endpoint.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limiter decides if an action identified by a key may proceed
+type Limiter interface {
+	// Allow takes a token from the bucket for key. When the bucket is empty, false is returned along with the
+	// time until the next token is available.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+	// Peek is like Allow, but doesn't take a token. It's used to check several limits before spending from any.
+	Peek(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// Rate configures a token bucket. Buckets start full and are refilled at PerMinute tokens per minute up to Burst.
+type Rate struct {
+	PerMinute float64
+	Burst     float64
+}
+
+func (r Rate) perSecond() float64 {
+	return r.PerMinute / 60
+}
+
+// take applies the token bucket algorithm to a bucket with tokens remaining after elapsed has passed since it
+// was last updated. It returns the new number of tokens, if a token was taken and how long until one is available.
+func (r Rate) take(tokens float64, elapsed time.Duration) (float64, bool, time.Duration) {
+	tokens = r.refill(tokens, elapsed)
+
+	allowed, wait := r.available(tokens)
+	if allowed {
+		return tokens - 1, true, 0
+	}
+
+	return tokens, false, wait
+}
+
+// peek returns if a token could be taken from a bucket with tokens remaining after elapsed has passed since it
+// was last updated, and if not, how long until one is available
+func (r Rate) peek(tokens float64, elapsed time.Duration) (bool, time.Duration) {
+	return r.available(r.refill(tokens, elapsed))
+}
+
+// refill returns the tokens in a bucket after elapsed has passed, up to the burst
+func (r Rate) refill(tokens float64, elapsed time.Duration) float64 {
+	return math.Min(r.Burst, tokens+elapsed.Seconds()*r.perSecond())
+}
+
+// available returns if a bucket holding tokens has one to take, and if not, how long until it will
+func (r Rate) available(tokens float64) (bool, time.Duration) {
+	if tokens >= 1 {
+		return true, 0
+	}
+
+	if r.perSecond() <= 0 {
+		return false, time.Hour
+	}
+
+	return false, time.Duration((1 - tokens) / r.perSecond() * float64(time.Second))
+}
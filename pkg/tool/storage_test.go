@@ -0,0 +1,113 @@
+package tool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestToolWithoutDatabase checks that the tool can be run using the storage backends which don't need the
+// toolbelt database
+func TestToolWithoutDatabase(t *testing.T) {
+	for _, backend := range []string{"memory", "sqlite"} {
+		t.Run(backend, func(t *testing.T) {
+			config := map[string]interface{}{
+				"webhook-rss": map[string]interface{}{
+					"storage": map[string]interface{}{
+						"backend": backend,
+						"sqlite": map[string]interface{}{
+							"path": filepath.Join(t.TempDir(), "webhook-rss.db"),
+						},
+					},
+					"jobs": map[string]interface{}{
+						"deadman": map[string]interface{}{
+							"schedule": "0 0 * * * *",
+							"endpoint": "http://localhost:9032/webhook-rss/feeds/deadman/items",
+						},
+						"deadman-check": map[string]interface{}{
+							"schedule":       "0 0 * * * *",
+							"pushover_token": "abc123",
+							"pushover_app":   "abc123",
+						},
+						"clean": map[string]interface{}{
+							"schedule": "0 0 * * * *",
+						},
+						"clean-check": map[string]interface{}{
+							"schedule": "0 0 * * * *",
+							"endpoint": "http://localhost:9032/webhook-rss/feeds/alerts/items",
+						},
+						"feed-check": map[string]interface{}{
+							"schedule": "0 0 * * * *",
+							"endpoint": "http://localhost:9032/webhook-rss/feeds/alerts/items",
+							"feeds":    []interface{}{},
+						},
+					},
+				},
+			}
+
+			tb := tool.NewBelt()
+			tb.SetConfig(config)
+
+			webhookRSSTool := &WebhookRSS{}
+			err := tb.AddTool(webhookRSSTool)
+			require.NoError(t, err)
+			defer webhookRSSTool.store.Close()
+
+			req := httptest.NewRequest("POST", "/webhook-rss/feeds/example/items", strings.NewReader(`{"title": "item1"}`))
+			rec := httptest.NewRecorder()
+			tb.Router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			req = httptest.NewRequest("GET", "/webhook-rss/feeds/example.rss", nil)
+			rec = httptest.NewRecorder()
+			tb.Router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			assert.Contains(t, rec.Body.String(), "<title>item1</title>")
+
+			req = httptest.NewRequest("GET", "/webhook-rss/metrics", nil)
+			rec = httptest.NewRecorder()
+			tb.Router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			assert.Contains(t, rec.Body.String(), `webhook_rss_items_created_total{feed="example"} 1`)
+			assert.Contains(t, rec.Body.String(), `webhook_rss_feed_requests_total{format="rss",status="200"} 1`)
+			assert.Contains(t, rec.Body.String(), `webhook_rss_feed_items{feed="example"} 1`)
+
+			for _, path := range []string{"/webhook-rss/healthz", "/webhook-rss/readyz", "/webhook-rss/status", "/webhook-rss/ping/deadman"} {
+				req = httptest.NewRequest("GET", path, nil)
+				rec = httptest.NewRecorder()
+				tb.Router.ServeHTTP(rec, req)
+				assert.Equal(t, http.StatusOK, rec.Code, path)
+			}
+		})
+	}
+}
+
+// TestHTTPAttachWithoutFeedCheck checks that the routes can be registered without any job config, for deployments
+// which only serve feeds
+func TestHTTPAttachWithoutFeedCheck(t *testing.T) {
+	webhookRSSTool := &WebhookRSS{}
+	err := webhookRSSTool.SetConfig(map[string]any{
+		"storage": map[string]any{"backend": "memory"},
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	require.NoError(t, webhookRSSTool.HTTPAttach(router))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/ping/deadman", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ItemIDs is a list of item ids, stored as a comma separated list
+type ItemIDs []int64
+
+// Has returns true when the list contains the id
+func (ids ItemIDs) Has(id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ids ItemIDs) Value() (driver.Value, error) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+
+	return strings.Join(parts, ","), nil
+}
+
+func (ids *ItemIDs) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("unsupported type for item ids: %T", src)
+	}
+
+	*ids = ItemIDs{}
+	if s == "" {
+		return nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid item id %q: %w", part, err)
+		}
+		*ids = append(*ids, id)
+	}
+
+	return nil
+}
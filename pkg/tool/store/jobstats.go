@@ -0,0 +1,43 @@
+package store
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JobStats are counters reported by a job run, such as the number of rows removed. They're stored as a JSON object.
+type JobStats map[string]int64
+
+func (s JobStats) Value() (driver.Value, error) {
+	if s == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job stats: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func (s *JobStats) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("unsupported type for job stats: %T", src)
+	}
+
+	*s = JobStats{}
+	if len(b) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(b, s)
+}
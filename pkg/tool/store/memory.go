@@ -0,0 +1,1197 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store which holds all data in process. It's intended for tests and short lived instances, data is
+// lost when the process exits.
+type Memory struct {
+	mu sync.Mutex
+
+	items  []Item
+	itemID int64
+
+	// usage holds the bytes used by each feed, keyed by feed then day
+	usage map[string]map[string]int64
+
+	secrets  []Secret
+	secretID int64
+	// privateFeeds holds the names of feeds which need a read secret
+	privateFeeds map[string]bool
+
+	subscriptions  []Subscription
+	subscriptionID int64
+
+	deliveries []Delivery
+	deliveryID int64
+
+	pollStates map[string]PollState
+	// seenEntries holds the time each entry guid was last seen, by source
+	seenEntries map[string]map[string]time.Time
+
+	attachments  []Attachment
+	attachmentID int64
+
+	digestStates map[string]DigestState
+
+	jobRuns  []JobRun
+	jobRunID int64
+
+	heartbeatStates map[string]HeartbeatState
+
+	// itemStates holds each user's item states, keyed by user then item id
+	itemStates map[string]map[int64]ItemState
+}
+
+// NewMemory returns an empty in memory Store
+func NewMemory() *Memory {
+	return &Memory{
+		usage:       make(map[string]map[string]int64),
+		pollStates:  make(map[string]PollState),
+		seenEntries: make(map[string]map[string]time.Time),
+
+		privateFeeds:    make(map[string]bool),
+		digestStates:    make(map[string]DigestState),
+		heartbeatStates: make(map[string]HeartbeatState),
+		itemStates:      make(map[string]map[int64]ItemState),
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) InsertItems(ctx context.Context, feed string, items []NewItem, opts InsertOptions) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertItems(feed, items, opts)
+}
+
+// insertItems inserts items, the caller must hold the lock
+func (m *Memory) insertItems(feed string, items []NewItem, opts InsertOptions) ([]Item, error) {
+	now := opts.now()
+
+	guids := make(map[string]bool)
+	for _, item := range m.items {
+		if item.Feed == feed {
+			guids[item.GUID] = true
+		}
+	}
+
+	var size int64
+	for _, item := range items {
+		if guids[item.GUID] {
+			return nil, fmt.Errorf("failed to insert items: duplicate guid %q in feed %s", item.GUID, feed)
+		}
+		guids[item.GUID] = true
+		size += item.size()
+	}
+
+	if opts.MaxFeedBytesPerDay > 0 {
+		if m.usage[feed] == nil {
+			m.usage[feed] = make(map[string]int64)
+		}
+
+		day := usageDay(now)
+		if m.usage[feed][day]+size > opts.MaxFeedBytesPerDay {
+			return nil, ErrQuotaExceeded
+		}
+		m.usage[feed][day] += size
+	}
+
+	created := []Item{}
+	for _, item := range items {
+		createdAt := item.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+
+		m.itemID++
+		stored := Item{
+			ID:        m.itemID,
+			Feed:      feed,
+			GUID:      item.GUID,
+			Title:     item.Title,
+			Body:      item.Body,
+			URL:       item.URL,
+			Tags:      append(Tags{}, item.Tags...),
+			CreatedAt: createdAt.UTC(),
+			ExpiresAt: utcOrNil(item.ExpiresAt),
+			Priority:  item.priority(),
+			Pinned:    item.Pinned,
+			Announced: !createdAt.After(now),
+		}
+
+		m.items = append(m.items, stored)
+		created = append(created, stored)
+	}
+
+	return created, nil
+}
+
+// published returns true when the item's date has passed and it hasn't expired
+func published(item Item, now time.Time) bool {
+	expired := item.ExpiresAt != nil && !item.ExpiresAt.After(now)
+	return !item.CreatedAt.After(now) && !expired
+}
+
+// sortNewestFirst orders items in the same way as the SQL store
+func sortNewestFirst(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return items[i].ID > items[j].ID
+	})
+}
+
+func (m *Memory) ListItems(ctx context.Context, feed string, opts ListOptions) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	items := []Item{}
+	for _, item := range m.items {
+		expired := item.ExpiresAt != nil && !item.ExpiresAt.After(now)
+		if item.Feed == feed && item.CreatedAt.Before(now) && !expired && !m.readBy(opts.UnreadBy, item.ID) {
+			items = append(items, item)
+		}
+	}
+
+	sortNewestFirst(items)
+	if opts.PinnedFirst {
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Pinned && !items[j].Pinned
+		})
+	}
+
+	if opts.Offset > 0 {
+		if opts.Offset >= len(items) {
+			return []Item{}, nil
+		}
+		items = items[opts.Offset:]
+	}
+
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		items = items[:opts.Limit]
+	}
+
+	return items, nil
+}
+
+func (m *Memory) ListScheduledItems(ctx context.Context, feed string) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	items := []Item{}
+	for _, item := range m.items {
+		if item.Feed == feed && !item.CreatedAt.Before(now) {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+func (m *Memory) ListItemsAfter(ctx context.Context, feed string, afterID int64, limit int) ([]Item, error) {
+	now := time.Now()
+
+	return m.listItemsAfter(feed, afterID, limit, func(item Item) bool {
+		return published(item, now)
+	})
+}
+
+func (m *Memory) ListAllItemsAfter(ctx context.Context, feed string, afterID int64, limit int) ([]Item, error) {
+	return m.listItemsAfter(feed, afterID, limit, func(item Item) bool {
+		return true
+	})
+}
+
+// listItemsAfter lists the items in a feed after afterID for which include returns true
+func (m *Memory) listItemsAfter(feed string, afterID int64, limit int, include func(item Item) bool) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// items are held in insertion order, so are already ordered by id
+	items := []Item{}
+	for _, item := range m.items {
+		if item.Feed == feed && item.ID > afterID && include(item) {
+			items = append(items, item)
+		}
+		if limit > 0 && len(items) == limit {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+func (m *Memory) ClaimPublishedItems(ctx context.Context, now time.Time) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claimed := []Item{}
+	for i, item := range m.items {
+		if !item.Announced && !item.CreatedAt.After(now) {
+			m.items[i].Announced = true
+			claimed = append(claimed, m.items[i])
+		}
+	}
+
+	return claimed, nil
+}
+
+func (m *Memory) ExistingGUIDs(ctx context.Context, feed string, guids []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wanted := make(map[string]bool)
+	for _, guid := range guids {
+		wanted[guid] = true
+	}
+
+	existing := []string{}
+	for _, item := range m.items {
+		if item.Feed == feed && wanted[item.GUID] {
+			existing = append(existing, item.GUID)
+		}
+	}
+
+	return existing, nil
+}
+
+func (m *Memory) LatestItemID(ctx context.Context, feed string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var id int64
+	for _, item := range m.items {
+		if item.Feed == feed && item.ID > id {
+			id = item.ID
+		}
+	}
+
+	return id, nil
+}
+
+func (m *Memory) LatestItem(ctx context.Context, feed string) (Item, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	var items []Item
+	for _, item := range m.items {
+		if item.Feed == feed && published(item, now) {
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return Item{}, false, nil
+	}
+
+	sortNewestFirst(items)
+
+	return items[0], true, nil
+}
+
+func (m *Memory) DeleteItems(ctx context.Context, feed string, ids []int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	remove := make(map[int64]bool)
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	return m.deleteWhere(func(item Item) bool {
+		return item.Feed == feed && remove[item.ID]
+	}), nil
+}
+
+func (m *Memory) DeleteFeed(ctx context.Context, feed string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteWhere(func(item Item) bool {
+		return item.Feed == feed
+	}), nil
+}
+
+func (m *Memory) SetItemPinned(ctx context.Context, feed string, id int64, pinned bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, item := range m.items {
+		if item.Feed == feed && item.ID == id {
+			m.items[i].Pinned = pinned
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *Memory) DeleteExpiredItems(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteWhere(func(item Item) bool {
+		return item.ExpiresAt != nil && item.ExpiresAt.Before(before) && !m.starred(item.ID)
+	}), nil
+}
+
+// deleteWhere removes the items matching fn, and their attachments, and returns the number removed. m.mu must be
+// held.
+func (m *Memory) deleteWhere(fn func(item Item) bool) int64 {
+	var kept []Item
+	removedIDs := make(map[int64]bool)
+
+	for _, item := range m.items {
+		if fn(item) {
+			removedIDs[item.ID] = true
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	m.items = kept
+
+	var keptAttachments []Attachment
+	for _, a := range m.attachments {
+		if !removedIDs[a.ItemID] {
+			keptAttachments = append(keptAttachments, a)
+		}
+	}
+	m.attachments = keptAttachments
+
+	for _, states := range m.itemStates {
+		for id := range removedIDs {
+			delete(states, id)
+		}
+	}
+
+	return int64(len(removedIDs))
+}
+
+func (m *Memory) FeedStats(ctx context.Context) ([]FeedStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	byFeed := make(map[string]*FeedStats)
+	for _, item := range m.items {
+		s, ok := byFeed[item.Feed]
+		if !ok {
+			s = &FeedStats{Feed: item.Feed}
+			byFeed[item.Feed] = s
+		}
+
+		s.Count++
+		if published(item, now) && item.CreatedAt.After(s.LatestAt) {
+			s.LatestAt = item.CreatedAt
+		}
+	}
+
+	stats := []FeedStats{}
+	for _, s := range byFeed {
+		stats = append(stats, *s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Feed < stats[j].Feed
+	})
+
+	return stats, nil
+}
+
+func (m *Memory) TrimFeeds(ctx context.Context, keep int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := make([]Item, len(m.items))
+	copy(items, m.items)
+	sortNewestFirst(items)
+
+	var candidates []Item
+	counts := make(map[string]int)
+	for _, item := range items {
+		if item.Pinned || m.starred(item.ID) {
+			continue
+		}
+		candidates = append(candidates, item)
+		counts[item.Feed]++
+	}
+
+	position := 0
+	remove := make(map[int64]bool)
+	for _, item := range candidates {
+		if counts[item.Feed] <= keep {
+			continue
+		}
+		position++
+		if position > keep {
+			remove[item.ID] = true
+		}
+	}
+
+	return m.deleteWhere(func(item Item) bool {
+		return remove[item.ID]
+	}), nil
+}
+
+func (m *Memory) PruneUsage(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := usageDay(before)
+	for _, days := range m.usage {
+		for day := range days {
+			if day < cutoff {
+				delete(days, day)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) CreateSecret(ctx context.Context, feed, label, secretHash string) (Secret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, secret := range m.secrets {
+		if secret.SecretHash == secretHash {
+			return Secret{}, fmt.Errorf("failed to insert secret: duplicate secret")
+		}
+	}
+
+	m.secretID++
+	secret := Secret{
+		ID:         m.secretID,
+		Feed:       feed,
+		Label:      label,
+		SecretHash: secretHash,
+		CreatedAt:  time.Now().UTC(),
+	}
+	m.secrets = append(m.secrets, secret)
+	m.privateFeeds[feed] = true
+
+	return secret, nil
+}
+
+func (m *Memory) ListSecrets(ctx context.Context, feed string) ([]Secret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secrets := []Secret{}
+	for _, secret := range m.secrets {
+		if secret.Feed == feed {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return secrets, nil
+}
+
+func (m *Memory) RevokeSecret(ctx context.Context, feed string, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, secret := range m.secrets {
+		if secret.ID == id && secret.Feed == feed && secret.RevokedAt == nil {
+			now := time.Now().UTC()
+			m.secrets[i].RevokedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *Memory) ActiveSecretHashes(ctx context.Context, feed string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var hashes []string
+	for _, secret := range m.secrets {
+		if secret.Feed == feed && secret.RevokedAt == nil {
+			hashes = append(hashes, secret.SecretHash)
+		}
+	}
+
+	return hashes, nil
+}
+
+func (m *Memory) FeedPrivate(ctx context.Context, feed string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.privateFeeds[feed], nil
+}
+
+func (m *Memory) SetFeedPrivate(ctx context.Context, feed string, private bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if private {
+		m.privateFeeds[feed] = true
+	} else {
+		delete(m.privateFeeds, feed)
+	}
+
+	return nil
+}
+
+func (m *Memory) UpsertSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub.ExpiresAt = sub.ExpiresAt.UTC()
+
+	for i, existing := range m.subscriptions {
+		if existing.Topic == sub.Topic && existing.Callback == sub.Callback {
+			m.subscriptions[i].Secret = sub.Secret
+			m.subscriptions[i].LeaseSeconds = sub.LeaseSeconds
+			m.subscriptions[i].ExpiresAt = sub.ExpiresAt
+			return m.subscriptions[i], nil
+		}
+	}
+
+	m.subscriptionID++
+	sub.ID = m.subscriptionID
+	sub.CreatedAt = time.Now().UTC()
+	m.subscriptions = append(m.subscriptions, sub)
+
+	return sub, nil
+}
+
+func (m *Memory) DeleteSubscription(ctx context.Context, topic, callback string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteSubscriptionsWhere(func(sub Subscription) bool {
+		return sub.Topic == topic && sub.Callback == callback
+	})
+
+	return nil
+}
+
+// deleteSubscriptionsWhere removes the subscriptions matching fn, m.mu must be held
+func (m *Memory) deleteSubscriptionsWhere(fn func(sub Subscription) bool) int64 {
+	var kept []Subscription
+	var removed int64
+
+	for _, sub := range m.subscriptions {
+		if fn(sub) {
+			removed++
+			continue
+		}
+		kept = append(kept, sub)
+	}
+
+	m.subscriptions = kept
+
+	return removed
+}
+
+func (m *Memory) ListSubscriptions(ctx context.Context, feed string) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	subs := []Subscription{}
+	for _, sub := range m.subscriptions {
+		if sub.Feed == feed && sub.ExpiresAt.After(now) {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs, nil
+}
+
+func (m *Memory) ListExpiringSubscriptions(ctx context.Context, before time.Time) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	subs := []Subscription{}
+	for _, sub := range m.subscriptions {
+		if sub.ExpiresAt.After(now) && sub.ExpiresAt.Before(before) {
+			subs = append(subs, sub)
+		}
+	}
+
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].ExpiresAt.Before(subs[j].ExpiresAt)
+	})
+
+	return subs, nil
+}
+
+func (m *Memory) DeleteExpiredSubscriptions(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteSubscriptionsWhere(func(sub Subscription) bool {
+		return sub.ExpiresAt.Before(before)
+	}), nil
+}
+
+func (m *Memory) EnqueueDeliveries(ctx context.Context, deliveries []NewDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	for _, d := range deliveries {
+		m.deliveryID++
+		m.deliveries = append(m.deliveries, Delivery{
+			ID:            m.deliveryID,
+			Target:        d.Target,
+			Feed:          d.Feed,
+			ItemID:        d.ItemID,
+			ContentType:   d.ContentType,
+			Body:          d.Body,
+			Status:        DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+
+	return nil
+}
+
+func (m *Memory) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	var due []int
+	for i, d := range m.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return m.deliveries[due[i]].NextAttemptAt.Before(m.deliveries[due[j]].NextAttemptAt)
+	})
+
+	claimed := []Delivery{}
+	for _, i := range due {
+		if len(claimed) == limit {
+			break
+		}
+
+		m.deliveries[i].Attempts++
+		m.deliveries[i].NextAttemptAt = now.Add(lease)
+		m.deliveries[i].UpdatedAt = now
+		claimed = append(claimed, m.deliveries[i])
+	}
+
+	return claimed, nil
+}
+
+// updateDelivery applies fn to the delivery with the given id, m.mu must be held
+func (m *Memory) updateDelivery(id int64, fn func(d *Delivery)) bool {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id {
+			fn(&m.deliveries[i])
+			m.deliveries[i].UpdatedAt = time.Now().UTC()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Memory) CompleteDelivery(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateDelivery(id, func(d *Delivery) {
+		d.Status = DeliveryDelivered
+		d.LastError = ""
+	})
+
+	return nil
+}
+
+func (m *Memory) RetryDelivery(ctx context.Context, id int64, reason string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateDelivery(id, func(d *Delivery) {
+		d.LastError = reason
+		d.NextAttemptAt = at.UTC()
+	})
+
+	return nil
+}
+
+func (m *Memory) DeadLetterDelivery(ctx context.Context, id int64, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateDelivery(id, func(d *Delivery) {
+		d.Status = DeliveryDead
+		d.LastError = reason
+	})
+
+	return nil
+}
+
+func (m *Memory) RequeueDelivery(ctx context.Context, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id && m.deliveries[i].Status == DeliveryDead {
+			now := time.Now().UTC()
+			m.deliveries[i].Status = DeliveryPending
+			m.deliveries[i].Attempts = 0
+			m.deliveries[i].NextAttemptAt = now
+			m.deliveries[i].UpdatedAt = now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *Memory) ListDeliveries(ctx context.Context, status string, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := []Delivery{}
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		if limit > 0 && len(deliveries) == limit {
+			break
+		}
+		if m.deliveries[i].Status == status {
+			deliveries = append(deliveries, m.deliveries[i])
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (m *Memory) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var kept []Delivery
+	var removed int64
+
+	for _, d := range m.deliveries {
+		if d.Status == DeliveryDelivered && d.UpdatedAt.Before(before) {
+			removed++
+			continue
+		}
+		kept = append(kept, d)
+	}
+
+	m.deliveries = kept
+
+	return removed, nil
+}
+
+func (m *Memory) GetPollState(ctx context.Context, source string) (PollState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, found := m.pollStates[source]
+
+	return state, found, nil
+}
+
+func (m *Memory) SavePollState(ctx context.Context, state PollState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pollStates[state.Source] = state
+
+	return nil
+}
+
+func (m *Memory) UnseenEntries(ctx context.Context, source string, guids []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	unseen := []string{}
+	for _, guid := range guids {
+		if _, ok := m.seenEntries[source][guid]; !ok {
+			unseen = append(unseen, guid)
+		}
+	}
+
+	return unseen, nil
+}
+
+func (m *Memory) MarkEntriesSeen(ctx context.Context, source string, guids []string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.seenEntries[source] == nil {
+		m.seenEntries[source] = make(map[string]time.Time)
+	}
+	for _, guid := range guids {
+		m.seenEntries[source][guid] = at
+	}
+
+	return nil
+}
+
+func (m *Memory) PruneSeenEntries(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entries := range m.seenEntries {
+		for guid, seenAt := range entries {
+			if seenAt.Before(before) {
+				delete(entries, guid)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) TouchSeenEntries(ctx context.Context, source string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for guid := range m.seenEntries[source] {
+		m.seenEntries[source][guid] = at
+	}
+
+	return nil
+}
+
+func (m *Memory) AddAttachments(ctx context.Context, feed string, itemID int64, attachments []NewAttachment) ([]Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for _, item := range m.items {
+		if item.ID == itemID && item.Feed == feed {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to insert attachments: item %d not found in feed %s", itemID, feed)
+	}
+
+	return m.addAttachments(feed, itemID, attachments), nil
+}
+
+func (m *Memory) InsertItemWithAttachments(
+	ctx context.Context, feed string, item NewItem, attachments []NewAttachment, opts InsertOptions,
+) (Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created, err := m.insertItems(feed, []NewItem{item}, opts)
+	if err != nil {
+		return Item{}, err
+	}
+
+	m.addAttachments(feed, created[0].ID, attachments)
+
+	return created[0], nil
+}
+
+// addAttachments stores attachments on an item, the caller must hold the lock
+func (m *Memory) addAttachments(feed string, itemID int64, attachments []NewAttachment) []Attachment {
+	now := time.Now().UTC()
+
+	added := []Attachment{}
+	for _, a := range attachments {
+		m.attachmentID++
+		attachment := Attachment{
+			ID:          m.attachmentID,
+			Feed:        feed,
+			ItemID:      itemID,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        int64(len(a.Data)),
+			CreatedAt:   now,
+			Data:        append([]byte{}, a.Data...),
+		}
+		m.attachments = append(m.attachments, attachment)
+
+		attachment.Data = nil
+		added = append(added, attachment)
+	}
+
+	return added
+}
+
+func (m *Memory) GetAttachment(ctx context.Context, feed string, itemID, id int64) (Attachment, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range m.attachments {
+		if a.ID == id && a.ItemID == itemID && a.Feed == feed {
+			a.Data = append([]byte{}, a.Data...)
+			return a, true, nil
+		}
+	}
+
+	return Attachment{}, false, nil
+}
+
+func (m *Memory) ListAttachments(ctx context.Context, feed string, itemIDs []int64) ([]Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make(map[int64]bool)
+	for _, id := range itemIDs {
+		ids[id] = true
+	}
+
+	attachments := []Attachment{}
+	for _, a := range m.attachments {
+		if a.Feed == feed && ids[a.ItemID] {
+			a.Data = nil
+			attachments = append(attachments, a)
+		}
+	}
+
+	return attachments, nil
+}
+
+func (m *Memory) GetDigestState(ctx context.Context, name string) (DigestState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, found := m.digestStates[name]
+
+	return state, found, nil
+}
+
+func (m *Memory) SaveDigestState(ctx context.Context, state DigestState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.digestStates[state.Name] = state
+
+	return nil
+}
+
+func (m *Memory) InsertDigestItem(ctx context.Context, feed string, item NewItem, state DigestState) (Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created, err := m.insertItems(feed, []NewItem{item}, InsertOptions{})
+	if err != nil {
+		return Item{}, err
+	}
+
+	m.digestStates[state.Name] = state
+
+	return created[0], nil
+}
+
+func (m *Memory) RecordJobRun(ctx context.Context, run JobRun) (JobRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobRunID++
+	run.ID = m.jobRunID
+	run.StartedAt = run.StartedAt.UTC()
+	run.FinishedAt = run.FinishedAt.UTC()
+
+	stats := JobStats{}
+	for k, v := range run.Stats {
+		stats[k] = v
+	}
+	run.Stats = stats
+
+	m.jobRuns = append(m.jobRuns, run)
+
+	return run, nil
+}
+
+func (m *Memory) ListJobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := []JobRun{}
+	for _, r := range m.jobRuns {
+		if r.Job == job {
+			runs = append(runs, r)
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].ID > runs[j].ID
+		}
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+func (m *Memory) PruneJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var kept []JobRun
+	var pruned int64
+	for _, r := range m.jobRuns {
+		if r.StartedAt.Before(before) {
+			pruned++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	m.jobRuns = kept
+
+	return pruned, nil
+}
+
+func (m *Memory) GetHeartbeatState(ctx context.Context, name string) (HeartbeatState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, found := m.heartbeatStates[name]
+
+	return state, found, nil
+}
+
+func (m *Memory) SaveHeartbeatState(ctx context.Context, state HeartbeatState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state.ChangedAt = state.ChangedAt.UTC()
+	m.heartbeatStates[state.Name] = state
+
+	return nil
+}
+
+// starred returns true if any user has starred the item. m.mu must be held.
+func (m *Memory) starred(id int64) bool {
+	for _, states := range m.itemStates {
+		if states[id].Starred {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readBy returns true if the user has read or archived the item, it's false when user is blank. m.mu must be held.
+func (m *Memory) readBy(user string, id int64) bool {
+	state := m.itemStates[user][id]
+
+	return state.Read || state.Archived
+}
+
+func (m *Memory) UpdateItemState(
+	ctx context.Context,
+	user, feed string,
+	id int64,
+	update ItemStateUpdate,
+) (ItemState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for _, item := range m.items {
+		if item.Feed == feed && item.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ItemState{}, false, nil
+	}
+
+	if m.itemStates[user] == nil {
+		m.itemStates[user] = make(map[int64]ItemState)
+	}
+
+	state := update.apply(m.itemStates[user][id])
+	state.User = user
+	state.ItemID = id
+	state.Feed = feed
+	state.UpdatedAt = time.Now().UTC()
+	m.itemStates[user][id] = state
+
+	return state, true, nil
+}
+
+func (m *Memory) ListItemStates(ctx context.Context, user, feed string, ids []int64) ([]ItemState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	states := []ItemState{}
+	for _, id := range ids {
+		state, ok := m.itemStates[user][id]
+		if ok && state.Feed == feed {
+			states = append(states, state)
+		}
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ItemID < states[j].ItemID
+	})
+
+	return states, nil
+}
+
+func (m *Memory) UnreadCounts(ctx context.Context, user string) ([]UnreadCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	byFeed := make(map[string]int64)
+	for _, item := range m.items {
+		expired := item.ExpiresAt != nil && !item.ExpiresAt.After(now)
+		if item.CreatedAt.Before(now) && !expired && !m.readBy(user, item.ID) {
+			byFeed[item.Feed]++
+		}
+	}
+
+	counts := []UnreadCount{}
+	for feed, count := range byFeed {
+		counts = append(counts, UnreadCount{Feed: feed, Count: count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Feed < counts[j].Feed
+	})
+
+	return counts, nil
+}
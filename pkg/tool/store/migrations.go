@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationsTable is used by both the toolbelt, for the postgres migrations, and OpenSQLite
+const migrationsTable = "schema_migrations_webhook_rss"
+
+// LatestMigration returns the version of the newest migration in dir
+func LatestMigration(fsys fs.FS, dir string) (uint, error) {
+	source, err := iofs.New(fsys, dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find first migration: %w", err)
+	}
+
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to find next migration: %w", err)
+		}
+		version = next
+	}
+}
+
+// LatestSQLiteMigration returns the version of the newest SQLite migration
+func LatestSQLiteMigration() (uint, error) {
+	return LatestMigration(sqliteMigrations, "migrations/sqlite")
+}
+
+// Ping checks that the database can be reached
+func (s *SQL) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// MigrationVersion returns the version of the last migration run, and whether it failed part way through. The
+// version is 0 when no migrations have been run.
+func (s *SQL) MigrationVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	var row struct {
+		Version int64 `db:"version"`
+		Dirty   bool  `db:"dirty"`
+	}
+
+	// the migrations table isn't in the tool's schema
+	found, err := s.goquDB.From(goqu.T(migrationsTable)).Prepared(true).
+		Select("version", "dirty").
+		Limit(1).
+		ScanStructContext(ctx, &row)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get migration version: %w", err)
+	}
+	if !found {
+		return 0, false, nil
+	}
+
+	return uint(row.Version), row.Dirty, nil
+}
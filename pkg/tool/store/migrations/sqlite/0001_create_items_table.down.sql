@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS feed_guid_idx;
+DROP INDEX IF EXISTS feed_idx;
+DROP TABLE IF EXISTS items;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS items (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+
+  feed TEXT NOT NULL CHECK (feed != ''),
+  guid TEXT NOT NULL,
+
+  title TEXT NOT NULL CHECK (title != ''),
+  url TEXT NOT NULL,
+  body TEXT NOT NULL,
+
+  created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS feed_idx ON items(feed);
+CREATE UNIQUE INDEX IF NOT EXISTS feed_guid_idx ON items(feed, guid);
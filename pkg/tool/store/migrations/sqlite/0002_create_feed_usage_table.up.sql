@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS feed_usage (
+  feed TEXT NOT NULL,
+  day TEXT NOT NULL,
+  bytes INTEGER NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (feed, day)
+);
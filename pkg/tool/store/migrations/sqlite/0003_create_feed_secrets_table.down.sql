@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS feed_secrets_feed_idx;
+DROP TABLE IF EXISTS feed_secrets;
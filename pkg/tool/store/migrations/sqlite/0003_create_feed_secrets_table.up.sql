@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS feed_secrets (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+
+  feed TEXT NOT NULL CHECK (feed != ''),
+  label TEXT NOT NULL DEFAULT '',
+  secret_hash TEXT NOT NULL UNIQUE,
+
+  created_at DATETIME NOT NULL,
+  revoked_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS feed_secrets_feed_idx ON feed_secrets(feed);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS websub_subscriptions_feed_idx;
+DROP TABLE IF EXISTS websub_subscriptions;
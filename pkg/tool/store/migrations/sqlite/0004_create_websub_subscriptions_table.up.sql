@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS websub_subscriptions (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+
+  feed TEXT NOT NULL,
+  topic TEXT NOT NULL,
+  callback TEXT NOT NULL,
+  secret TEXT NOT NULL DEFAULT '',
+
+  lease_seconds INTEGER NOT NULL,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+
+  UNIQUE (topic, callback)
+);
+
+CREATE INDEX IF NOT EXISTS websub_subscriptions_feed_idx ON websub_subscriptions(feed);
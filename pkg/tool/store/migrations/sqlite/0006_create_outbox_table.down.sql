@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS outbox_status_next_attempt_at_idx;
+DROP TABLE IF EXISTS outbox;
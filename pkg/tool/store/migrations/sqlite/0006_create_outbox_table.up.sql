@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+
+  target TEXT NOT NULL,
+  feed TEXT NOT NULL,
+  item_id INTEGER NOT NULL,
+
+  content_type TEXT NOT NULL,
+  body TEXT NOT NULL,
+
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  next_attempt_at DATETIME NOT NULL,
+
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_status_next_attempt_at_idx ON outbox(status, next_attempt_at);
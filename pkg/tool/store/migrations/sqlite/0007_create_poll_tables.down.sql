@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS poll_seen_entries;
+DROP TABLE IF EXISTS poll_sources;
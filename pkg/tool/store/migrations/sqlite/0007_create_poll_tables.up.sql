@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS poll_sources (
+  source TEXT NOT NULL PRIMARY KEY,
+
+  etag TEXT NOT NULL DEFAULT '',
+  last_modified TEXT NOT NULL DEFAULT '',
+
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  last_polled_at DATETIME,
+  next_poll_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS poll_seen_entries (
+  source TEXT NOT NULL,
+  guid TEXT NOT NULL,
+  seen_at DATETIME NOT NULL,
+
+  PRIMARY KEY (source, guid)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS item_attachments (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+
+  feed TEXT NOT NULL,
+  item_id INTEGER NOT NULL,
+
+  filename TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  data BLOB NOT NULL,
+
+  created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS item_attachments_item_idx ON item_attachments(item_id);
+
+-- foreign keys aren't enforced unless enabled on each connection, so attachments are removed with a trigger
+CREATE TRIGGER IF NOT EXISTS item_attachments_delete AFTER DELETE ON items
+BEGIN
+  DELETE FROM item_attachments WHERE item_id = old.id;
+END;
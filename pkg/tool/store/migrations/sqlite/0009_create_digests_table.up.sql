@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS digests (
+  name TEXT NOT NULL PRIMARY KEY,
+
+  last_item_id INTEGER NOT NULL,
+  last_digest_at DATETIME NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS job_runs (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+
+  job TEXT NOT NULL,
+  started_at DATETIME NOT NULL,
+  finished_at DATETIME NOT NULL,
+
+  outcome TEXT NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  stats TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_job_started_at_idx ON job_runs(job, started_at);
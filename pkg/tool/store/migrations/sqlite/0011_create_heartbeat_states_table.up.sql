@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS heartbeat_states (
+  name TEXT NOT NULL PRIMARY KEY,
+
+  status TEXT NOT NULL,
+  changed_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS items_expires_at_idx;
+ALTER TABLE items DROP COLUMN expires_at;
@@ -0,0 +1,3 @@
+ALTER TABLE items ADD COLUMN expires_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS items_expires_at_idx ON items(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE items DROP COLUMN pinned;
+ALTER TABLE items DROP COLUMN priority;
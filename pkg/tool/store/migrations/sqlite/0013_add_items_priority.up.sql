@@ -0,0 +1,2 @@
+ALTER TABLE items ADD COLUMN priority TEXT NOT NULL DEFAULT 'normal';
+ALTER TABLE items ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT false;
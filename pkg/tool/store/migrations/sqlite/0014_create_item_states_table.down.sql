@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS item_states_delete;
+DROP TABLE IF EXISTS item_states;
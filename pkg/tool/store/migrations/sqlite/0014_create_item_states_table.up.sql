@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS item_states (
+  username TEXT NOT NULL,
+  item_id INTEGER NOT NULL,
+  feed TEXT NOT NULL,
+
+  read BOOLEAN NOT NULL DEFAULT false,
+  starred BOOLEAN NOT NULL DEFAULT false,
+  archived BOOLEAN NOT NULL DEFAULT false,
+
+  updated_at DATETIME NOT NULL,
+
+  PRIMARY KEY (username, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS item_states_item_idx ON item_states(item_id);
+
+-- foreign keys aren't enforced unless enabled on each connection, so states are removed with a trigger
+CREATE TRIGGER IF NOT EXISTS item_states_delete AFTER DELETE ON items
+BEGIN
+  DELETE FROM item_states WHERE item_id = old.id;
+END;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS items_unannounced_idx;
+ALTER TABLE items DROP COLUMN announced;
@@ -0,0 +1,5 @@
+ALTER TABLE items ADD COLUMN announced BOOLEAN NOT NULL DEFAULT true;
+
+UPDATE items SET announced = false WHERE created_at > strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+CREATE INDEX IF NOT EXISTS items_unannounced_idx ON items(created_at) WHERE NOT announced;
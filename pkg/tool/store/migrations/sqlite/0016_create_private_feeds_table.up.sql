@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS private_feeds (
+  feed TEXT NOT NULL PRIMARY KEY CHECK (feed != ''),
+
+  created_at DATETIME NOT NULL
+);
+
+INSERT OR IGNORE INTO private_feeds (feed, created_at)
+SELECT DISTINCT feed, strftime('%Y-%m-%d %H:%M:%f', 'now') FROM feed_secrets WHERE revoked_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE digests DROP COLUMN included_item_ids;
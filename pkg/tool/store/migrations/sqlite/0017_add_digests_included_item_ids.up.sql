@@ -0,0 +1 @@
+ALTER TABLE digests ADD COLUMN included_item_ids TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,1484 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	// dialects for the supported databases
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	_ "github.com/doug-martin/goqu/v9/dialect/sqlite3"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// SQL is a Store backed by a SQL database. The same queries are used for Postgres and SQLite, with goqu handling
+// the differences in dialect.
+type SQL struct {
+	db     *sql.DB
+	goquDB *goqu.Database
+
+	// schema is the schema holding the tool's tables, SQLite databases don't use one
+	schema string
+	// closeDB is set when the store owns the connection
+	closeDB bool
+	// notifyChannel is the Postgres channel notified with the feed name when items are inserted
+	notifyChannel string
+}
+
+// ItemsChannel is the Postgres notification channel used to announce new items, the payload is the feed name
+const ItemsChannel = "webhookrss_items"
+
+// NewPostgres returns a Store using the tables in the webhookrss schema, created by the tool's migrations
+func NewPostgres(db *sql.DB) *SQL {
+	return &SQL{
+		db:     db,
+		goquDB: goqu.New("postgres", db),
+		schema: "webhookrss",
+
+		notifyChannel: ItemsChannel,
+	}
+}
+
+func (s *SQL) Close() error {
+	if s.closeDB {
+		return s.db.Close()
+	}
+
+	return nil
+}
+
+// table returns the identifier for one of the tool's tables
+func (s *SQL) table(name string) exp.IdentifierExpression {
+	if s.schema == "" {
+		return goqu.T(name)
+	}
+
+	return goqu.S(s.schema).Table(name)
+}
+
+// tableName returns the name of one of the tool's tables for use in raw SQL
+func (s *SQL) tableName(name string) string {
+	if s.schema == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s.%s", s.schema, name)
+}
+
+// queryer is implemented by both *goqu.Database and *goqu.TxDatabase
+type queryer interface {
+	From(from ...interface{}) *goqu.SelectDataset
+	Insert(table interface{}) *goqu.InsertDataset
+	Update(table interface{}) *goqu.UpdateDataset
+	Delete(table interface{}) *goqu.DeleteDataset
+}
+
+// withTx runs fn in a transaction, committing if it returns no error
+func (s *SQL) withTx(ctx context.Context, fn func(tx *goqu.TxDatabase) error) error {
+	tx, err := s.goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL) InsertItems(ctx context.Context, feed string, items []NewItem, opts InsertOptions) ([]Item, error) {
+	if len(items) == 0 {
+		return []Item{}, nil
+	}
+
+	var created []Item
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		var err error
+		created, err = s.insertItems(ctx, tx, feed, items, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// insertItems inserts items in a transaction, returning them in the order they were inserted
+func (s *SQL) insertItems(ctx context.Context, tx *goqu.TxDatabase, feed string, items []NewItem, opts InsertOptions) ([]Item, error) {
+	now := opts.now()
+
+	var records []goqu.Record
+	var guids []string
+	var size int64
+	for _, item := range items {
+		createdAt := item.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+
+		records = append(records, goqu.Record{
+			"feed":       feed,
+			"guid":       item.GUID,
+			"title":      item.Title,
+			"body":       item.Body,
+			"url":        item.URL,
+			"tags":       item.Tags,
+			"created_at": createdAt.UTC(),
+			"expires_at": utcOrNil(item.ExpiresAt),
+			"priority":   item.priority(),
+			"pinned":     item.Pinned,
+			// scheduled items are announced by ClaimPublishedItems once they're published
+			"announced": !createdAt.After(now),
+		})
+		guids = append(guids, item.GUID)
+		size += item.size()
+	}
+
+	if opts.MaxFeedBytesPerDay > 0 {
+		used, err := s.recordUsage(ctx, tx, feed, usageDay(now), size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record feed usage: %w", err)
+		}
+
+		if used > opts.MaxFeedBytesPerDay {
+			return nil, ErrQuotaExceeded
+		}
+	}
+
+	_, err := tx.Insert(s.table("items")).Prepared(true).Rows(records).Executor().ExecContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert items: %w", err)
+	}
+
+	// guids are unique within a feed, so these identify the rows just inserted
+	var created []Item
+	err = tx.From(s.table("items")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed), goqu.C("guid").In(guids)).
+		Order(goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inserted items: %w", err)
+	}
+
+	// notifications are only delivered to listeners once the transaction commits
+	if s.notifyChannel != "" {
+		_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", s.notifyChannel, feed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to notify listeners: %w", err)
+		}
+	}
+
+	return created, nil
+}
+
+// recordUsage adds bytes to a feed's usage for the day, returning the new total
+func (s *SQL) recordUsage(ctx context.Context, q queryer, feed, day string, bytes int64) (int64, error) {
+	_, err := q.Insert(s.table("feed_usage")).Prepared(true).
+		Rows(goqu.Record{
+			"feed":  feed,
+			"day":   day,
+			"bytes": bytes,
+		}).
+		OnConflict(goqu.DoUpdate("feed, day", goqu.Record{
+			"bytes": goqu.L("feed_usage.bytes + excluded.bytes"),
+		})).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var used int64
+	_, err = q.From(s.table("feed_usage")).Prepared(true).
+		Select("bytes").
+		Where(goqu.C("feed").Eq(feed), goqu.C("day").Eq(day)).
+		ScanValContext(ctx, &used)
+	if err != nil {
+		return 0, err
+	}
+
+	return used, nil
+}
+
+func (s *SQL) ListItems(ctx context.Context, feed string, opts ListOptions) ([]Item, error) {
+	now := time.Now().UTC()
+
+	sel := s.goquDB.From(s.table("items")).Prepared(true).
+		Where(
+			goqu.C("feed").Eq(feed),
+			goqu.C("created_at").Lt(now),
+			goqu.Or(goqu.C("expires_at").IsNull(), goqu.C("expires_at").Gt(now)),
+		).
+		Order(goqu.C("created_at").Desc(), goqu.C("id").Desc())
+	if opts.UnreadBy != "" {
+		sel = sel.Where(goqu.C("id").NotIn(s.readItemIDs(opts.UnreadBy)))
+	}
+	if opts.PinnedFirst {
+		sel = sel.Order(goqu.C("pinned").Desc(), goqu.C("created_at").Desc(), goqu.C("id").Desc())
+	}
+	if opts.Limit > 0 {
+		sel = sel.Limit(uint(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		// sqlite only supports an offset with a limit
+		if opts.Limit == 0 {
+			sel = sel.Limit(math.MaxInt32)
+		}
+		sel = sel.Offset(uint(opts.Offset))
+	}
+
+	items := []Item{}
+	err := sel.ScanStructsContext(ctx, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+
+	return items, nil
+}
+
+func (s *SQL) ListScheduledItems(ctx context.Context, feed string) ([]Item, error) {
+	items := []Item{}
+
+	err := s.goquDB.From(s.table("items")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed), goqu.C("created_at").Gte(time.Now().UTC())).
+		Order(goqu.C("created_at").Asc(), goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled items: %w", err)
+	}
+
+	return items, nil
+}
+
+func (s *SQL) ListItemsAfter(ctx context.Context, feed string, afterID int64, limit int) ([]Item, error) {
+	now := time.Now().UTC()
+
+	return s.listItemsAfter(ctx, feed, afterID, limit,
+		goqu.C("created_at").Lte(now),
+		goqu.Or(goqu.C("expires_at").IsNull(), goqu.C("expires_at").Gt(now)),
+	)
+}
+
+func (s *SQL) ListAllItemsAfter(ctx context.Context, feed string, afterID int64, limit int) ([]Item, error) {
+	return s.listItemsAfter(ctx, feed, afterID, limit)
+}
+
+// listItemsAfter lists the items in a feed after afterID which match the filters, in the order they were inserted
+func (s *SQL) listItemsAfter(
+	ctx context.Context,
+	feed string,
+	afterID int64,
+	limit int,
+	filters ...exp.Expression,
+) ([]Item, error) {
+	sel := s.goquDB.From(s.table("items")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed), goqu.C("id").Gt(afterID)).
+		Where(filters...).
+		Order(goqu.C("id").Asc())
+	if limit > 0 {
+		sel = sel.Limit(uint(limit))
+	}
+
+	items := []Item{}
+	err := sel.ScanStructsContext(ctx, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+
+	return items, nil
+}
+
+func (s *SQL) ClaimPublishedItems(ctx context.Context, now time.Time) ([]Item, error) {
+	claimed := []Item{}
+
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		sel := tx.From(s.table("items")).Prepared(true).
+			Where(goqu.C("announced").Eq(false), goqu.C("created_at").Lte(now.UTC())).
+			Order(goqu.C("id").Asc())
+		// replicas skip items being claimed by others, sqlite only has a single writer
+		if s.goquDB.Dialect() == "postgres" {
+			sel = sel.ForUpdate(exp.SkipLocked)
+		}
+
+		err := sel.ScanStructsContext(ctx, &claimed)
+		if err != nil {
+			return fmt.Errorf("failed to select published items: %w", err)
+		}
+
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		var ids []int64
+		feeds := make(map[string]bool)
+		for _, item := range claimed {
+			ids = append(ids, item.ID)
+			feeds[item.Feed] = true
+		}
+
+		_, err = tx.Update(s.table("items")).Prepared(true).
+			Set(goqu.Record{"announced": true}).
+			Where(goqu.C("id").In(ids)).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to claim published items: %w", err)
+		}
+		for i := range claimed {
+			claimed[i].Announced = true
+		}
+
+		// event streams on every replica are woken for the newly published items
+		if s.notifyChannel != "" {
+			for feed := range feeds {
+				_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", s.notifyChannel, feed)
+				if err != nil {
+					return fmt.Errorf("failed to notify listeners: %w", err)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+func (s *SQL) ExistingGUIDs(ctx context.Context, feed string, guids []string) ([]string, error) {
+	existing := []string{}
+	if len(guids) == 0 {
+		return existing, nil
+	}
+
+	err := s.goquDB.From(s.table("items")).Prepared(true).
+		Select("guid").
+		Where(goqu.C("feed").Eq(feed), goqu.C("guid").In(guids)).
+		ScanValsContext(ctx, &existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing guids: %w", err)
+	}
+
+	return existing, nil
+}
+
+func (s *SQL) LatestItemID(ctx context.Context, feed string) (int64, error) {
+	var id sql.NullInt64
+
+	_, err := s.goquDB.From(s.table("items")).Prepared(true).
+		Select(goqu.MAX("id")).
+		Where(goqu.C("feed").Eq(feed)).
+		ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest item id: %w", err)
+	}
+
+	return id.Int64, nil
+}
+
+func (s *SQL) LatestItem(ctx context.Context, feed string) (Item, bool, error) {
+	var item Item
+	now := time.Now().UTC()
+
+	found, err := s.goquDB.From(s.table("items")).Prepared(true).
+		Where(
+			goqu.C("feed").Eq(feed),
+			goqu.C("created_at").Lte(now),
+			goqu.Or(goqu.C("expires_at").IsNull(), goqu.C("expires_at").Gt(now)),
+		).
+		Order(goqu.C("created_at").Desc(), goqu.C("id").Desc()).
+		Limit(1).
+		ScanStructContext(ctx, &item)
+	if err != nil {
+		return item, false, fmt.Errorf("failed to get latest item: %w", err)
+	}
+
+	return item, found, nil
+}
+
+func (s *SQL) DeleteItems(ctx context.Context, feed string, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	res, err := s.goquDB.Delete(s.table("items")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed), goqu.C("id").In(ids)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete items: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) DeleteFeed(ctx context.Context, feed string) (int64, error) {
+	res, err := s.goquDB.Delete(s.table("items")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete feed: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) SetItemPinned(ctx context.Context, feed string, id int64, pinned bool) (bool, error) {
+	res, err := s.goquDB.Update(s.table("items")).Prepared(true).
+		Set(goqu.Record{"pinned": pinned}).
+		Where(goqu.C("feed").Eq(feed), goqu.C("id").Eq(id)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to update item: %w", err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update item: %w", err)
+	}
+
+	return updated > 0, nil
+}
+
+func (s *SQL) FeedStats(ctx context.Context) ([]FeedStats, error) {
+	var rows []struct {
+		Feed     string `db:"feed"`
+		Count    int64  `db:"count"`
+		LatestID int64  `db:"latest_id"`
+	}
+
+	now := time.Now().UTC()
+
+	// the newest item is found by id and then loaded, rather than selecting MAX(created_at), as SQLite returns
+	// aggregated times as text which can't be scanned into a time.Time. Scheduled and expired items aren't counted
+	// as the newest, and feeds with only those have no latest item.
+	err := s.goquDB.From(s.table("items")).Prepared(true).
+		Select(
+			goqu.C("feed"),
+			goqu.COUNT("id").As("count"),
+			goqu.L(fmt.Sprintf(
+				"COALESCE((SELECT latest.id FROM %s AS latest WHERE latest.feed = %s.feed "+
+					"AND latest.created_at <= ? AND (latest.expires_at IS NULL OR latest.expires_at > ?) "+
+					"ORDER BY latest.created_at DESC, latest.id DESC LIMIT 1), 0)",
+				s.tableName("items"),
+				s.tableName("items"),
+			), now, now).As("latest_id"),
+		).
+		GroupBy("feed").
+		Order(goqu.C("feed").Asc()).
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed stats: %w", err)
+	}
+
+	var ids []int64
+	for _, row := range rows {
+		ids = append(ids, row.LatestID)
+	}
+
+	latestAt := make(map[int64]time.Time)
+	if len(ids) > 0 {
+		var latest []Item
+		err = s.goquDB.From(s.table("items")).Prepared(true).
+			Where(goqu.C("id").In(ids)).
+			ScanStructsContext(ctx, &latest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest items: %w", err)
+		}
+		for _, item := range latest {
+			latestAt[item.ID] = item.CreatedAt
+		}
+	}
+
+	stats := []FeedStats{}
+	for _, row := range rows {
+		stats = append(stats, FeedStats{
+			Feed:     row.Feed,
+			Count:    row.Count,
+			LatestAt: latestAt[row.LatestID],
+		})
+	}
+
+	return stats, nil
+}
+
+func (s *SQL) TrimFeeds(ctx context.Context, keep int) (int64, error) {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+delete from %[1]s where id in (
+  select id from (
+    select id, row_number() over (order by created_at desc, id desc) as position
+    from %[1]s
+    where not pinned and id not in (select item_id from %[3]s where starred) and feed in (
+      select feed from %[1]s
+      where not pinned and id not in (select item_id from %[3]s where starred)
+      group by feed
+      having count(id) > %[2]d
+    )
+  ) as ranked
+  where position > %[2]d
+)`, s.tableName("items"), keep, s.tableName("item_states")))
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim feeds: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) DeleteExpiredItems(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.goquDB.Delete(s.table("items")).Prepared(true).
+		Where(goqu.C("expires_at").Lt(before.UTC()), goqu.C("id").NotIn(s.starredItemIDs())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired items: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) PruneUsage(ctx context.Context, before time.Time) error {
+	_, err := s.goquDB.Delete(s.table("feed_usage")).Prepared(true).
+		Where(goqu.C("day").Lt(usageDay(before))).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to prune usage: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) CreateSecret(ctx context.Context, feed, label, secretHash string) (Secret, error) {
+	var secret Secret
+
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		_, err := tx.Insert(s.table("feed_secrets")).Prepared(true).
+			Rows(goqu.Record{
+				"feed":        feed,
+				"label":       label,
+				"secret_hash": secretHash,
+				"created_at":  time.Now().UTC(),
+			}).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert secret: %w", err)
+		}
+
+		err = s.setFeedPrivate(ctx, tx, feed)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.From(s.table("feed_secrets")).Prepared(true).
+			Where(goqu.C("secret_hash").Eq(secretHash)).
+			ScanStructContext(ctx, &secret)
+		if err != nil {
+			return fmt.Errorf("failed to load secret: %w", err)
+		}
+
+		return nil
+	})
+
+	return secret, err
+}
+
+func (s *SQL) ListSecrets(ctx context.Context, feed string) ([]Secret, error) {
+	secrets := []Secret{}
+
+	err := s.goquDB.From(s.table("feed_secrets")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed)).
+		Order(goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	return secrets, nil
+}
+
+func (s *SQL) RevokeSecret(ctx context.Context, feed string, id int64) (bool, error) {
+	res, err := s.goquDB.Update(s.table("feed_secrets")).Prepared(true).
+		Set(goqu.Record{"revoked_at": time.Now().UTC()}).
+		Where(
+			goqu.C("id").Eq(id),
+			goqu.C("feed").Eq(feed),
+			goqu.C("revoked_at").IsNull(),
+		).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke secret: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke secret: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (s *SQL) ActiveSecretHashes(ctx context.Context, feed string) ([]string, error) {
+	var hashes []string
+
+	err := s.goquDB.From(s.table("feed_secrets")).Prepared(true).
+		Select("secret_hash").
+		Where(goqu.C("feed").Eq(feed), goqu.C("revoked_at").IsNull()).
+		ScanValsContext(ctx, &hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	return hashes, nil
+}
+
+func (s *SQL) FeedPrivate(ctx context.Context, feed string) (bool, error) {
+	var count int64
+
+	_, err := s.goquDB.From(s.table("private_feeds")).Prepared(true).
+		Select(goqu.COUNT("feed")).
+		Where(goqu.C("feed").Eq(feed)).
+		ScanValContext(ctx, &count)
+	if err != nil {
+		return false, fmt.Errorf("failed to load feed privacy: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (s *SQL) SetFeedPrivate(ctx context.Context, feed string, private bool) error {
+	if private {
+		return s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+			return s.setFeedPrivate(ctx, tx, feed)
+		})
+	}
+
+	_, err := s.goquDB.Delete(s.table("private_feeds")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to make feed public: %w", err)
+	}
+
+	return nil
+}
+
+// setFeedPrivate marks a feed as private, feeds which are already private are unchanged
+func (s *SQL) setFeedPrivate(ctx context.Context, tx *goqu.TxDatabase, feed string) error {
+	_, err := tx.Insert(s.table("private_feeds")).Prepared(true).
+		Rows(goqu.Record{"feed": feed, "created_at": time.Now().UTC()}).
+		OnConflict(goqu.DoNothing()).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to make feed private: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) UpsertSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	var stored Subscription
+
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		_, err := tx.Insert(s.table("websub_subscriptions")).Prepared(true).
+			Rows(goqu.Record{
+				"feed":          sub.Feed,
+				"topic":         sub.Topic,
+				"callback":      sub.Callback,
+				"secret":        sub.Secret,
+				"lease_seconds": sub.LeaseSeconds,
+				"expires_at":    sub.ExpiresAt.UTC(),
+				"created_at":    time.Now().UTC(),
+			}).
+			OnConflict(goqu.DoUpdate("topic, callback", goqu.Record{
+				"secret":        sub.Secret,
+				"lease_seconds": sub.LeaseSeconds,
+				"expires_at":    sub.ExpiresAt.UTC(),
+			})).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to upsert subscription: %w", err)
+		}
+
+		_, err = tx.From(s.table("websub_subscriptions")).Prepared(true).
+			Where(goqu.C("topic").Eq(sub.Topic), goqu.C("callback").Eq(sub.Callback)).
+			ScanStructContext(ctx, &stored)
+		if err != nil {
+			return fmt.Errorf("failed to load subscription: %w", err)
+		}
+
+		return nil
+	})
+
+	return stored, err
+}
+
+func (s *SQL) DeleteSubscription(ctx context.Context, topic, callback string) error {
+	_, err := s.goquDB.Delete(s.table("websub_subscriptions")).Prepared(true).
+		Where(goqu.C("topic").Eq(topic), goqu.C("callback").Eq(callback)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) ListSubscriptions(ctx context.Context, feed string) ([]Subscription, error) {
+	subs := []Subscription{}
+
+	err := s.goquDB.From(s.table("websub_subscriptions")).Prepared(true).
+		Where(goqu.C("feed").Eq(feed), goqu.C("expires_at").Gt(time.Now().UTC())).
+		Order(goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &subs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (s *SQL) ListExpiringSubscriptions(ctx context.Context, before time.Time) ([]Subscription, error) {
+	subs := []Subscription{}
+
+	err := s.goquDB.From(s.table("websub_subscriptions")).Prepared(true).
+		Where(
+			goqu.C("expires_at").Gt(time.Now().UTC()),
+			goqu.C("expires_at").Lt(before.UTC()),
+		).
+		Order(goqu.C("expires_at").Asc()).
+		ScanStructsContext(ctx, &subs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (s *SQL) DeleteExpiredSubscriptions(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.goquDB.Delete(s.table("websub_subscriptions")).Prepared(true).
+		Where(goqu.C("expires_at").Lt(before.UTC())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired subscriptions: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) EnqueueDeliveries(ctx context.Context, deliveries []NewDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+
+	var records []goqu.Record
+	for _, d := range deliveries {
+		records = append(records, goqu.Record{
+			"target":          d.Target,
+			"feed":            d.Feed,
+			"item_id":         d.ItemID,
+			"content_type":    d.ContentType,
+			"body":            d.Body,
+			"status":          DeliveryPending,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": now,
+			"created_at":      now,
+			"updated_at":      now,
+		})
+	}
+
+	_, err := s.goquDB.Insert(s.table("outbox")).Prepared(true).Rows(records).Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue deliveries: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	now := time.Now().UTC()
+
+	var claimed []Delivery
+
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		sel := tx.From(s.table("outbox")).Prepared(true).
+			Where(goqu.C("status").Eq(DeliveryPending), goqu.C("next_attempt_at").Lte(now)).
+			Order(goqu.C("next_attempt_at").Asc(), goqu.C("id").Asc()).
+			Limit(uint(limit))
+		// replicas skip deliveries being claimed by others, sqlite only has a single writer
+		if s.goquDB.Dialect() == "postgres" {
+			sel = sel.ForUpdate(exp.SkipLocked)
+		}
+
+		err := sel.ScanStructsContext(ctx, &claimed)
+		if err != nil {
+			return fmt.Errorf("failed to select deliveries: %w", err)
+		}
+
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		var ids []int64
+		for i := range claimed {
+			ids = append(ids, claimed[i].ID)
+			claimed[i].Attempts++
+			claimed[i].NextAttemptAt = now.Add(lease)
+			claimed[i].UpdatedAt = now
+		}
+
+		_, err = tx.Update(s.table("outbox")).Prepared(true).
+			Set(goqu.Record{
+				"attempts":        goqu.L("attempts + 1"),
+				"next_attempt_at": now.Add(lease),
+				"updated_at":      now,
+			}).
+			Where(goqu.C("id").In(ids)).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to claim deliveries: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// updateDelivery sets fields on a delivery, updated_at is always set
+func (s *SQL) updateDelivery(ctx context.Context, id int64, record goqu.Record) error {
+	record["updated_at"] = time.Now().UTC()
+
+	_, err := s.goquDB.Update(s.table("outbox")).Prepared(true).
+		Set(record).
+		Where(goqu.C("id").Eq(id)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) CompleteDelivery(ctx context.Context, id int64) error {
+	return s.updateDelivery(ctx, id, goqu.Record{"status": DeliveryDelivered, "last_error": ""})
+}
+
+func (s *SQL) RetryDelivery(ctx context.Context, id int64, reason string, at time.Time) error {
+	return s.updateDelivery(ctx, id, goqu.Record{"last_error": reason, "next_attempt_at": at.UTC()})
+}
+
+func (s *SQL) DeadLetterDelivery(ctx context.Context, id int64, reason string) error {
+	return s.updateDelivery(ctx, id, goqu.Record{"status": DeliveryDead, "last_error": reason})
+}
+
+func (s *SQL) RequeueDelivery(ctx context.Context, id int64) (bool, error) {
+	now := time.Now().UTC()
+
+	res, err := s.goquDB.Update(s.table("outbox")).Prepared(true).
+		Set(goqu.Record{
+			"status":          DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"updated_at":      now,
+		}).
+		Where(goqu.C("id").Eq(id), goqu.C("status").Eq(DeliveryDead)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue delivery: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue delivery: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (s *SQL) ListDeliveries(ctx context.Context, status string, limit int) ([]Delivery, error) {
+	sel := s.goquDB.From(s.table("outbox")).Prepared(true).
+		Where(goqu.C("status").Eq(status)).
+		Order(goqu.C("id").Desc())
+	if limit > 0 {
+		sel = sel.Limit(uint(limit))
+	}
+
+	deliveries := []Delivery{}
+	err := sel.ScanStructsContext(ctx, &deliveries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *SQL) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.goquDB.Delete(s.table("outbox")).Prepared(true).
+		Where(goqu.C("status").Eq(DeliveryDelivered), goqu.C("updated_at").Lt(before.UTC())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune deliveries: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) GetPollState(ctx context.Context, source string) (PollState, bool, error) {
+	var state PollState
+
+	found, err := s.goquDB.From(s.table("poll_sources")).Prepared(true).
+		Where(goqu.C("source").Eq(source)).
+		ScanStructContext(ctx, &state)
+	if err != nil {
+		return PollState{}, false, fmt.Errorf("failed to get poll state: %w", err)
+	}
+
+	return state, found, nil
+}
+
+func (s *SQL) SavePollState(ctx context.Context, state PollState) error {
+	record := goqu.Record{
+		"etag":          state.ETag,
+		"last_modified": state.LastModified,
+		"failures":      state.Failures,
+		"last_error":    state.LastError,
+		"next_poll_at":  state.NextPollAt.UTC(),
+	}
+	if state.LastPolledAt != nil {
+		record["last_polled_at"] = state.LastPolledAt.UTC()
+	}
+
+	insert := goqu.Record{"source": state.Source}
+	for k, v := range record {
+		insert[k] = v
+	}
+
+	_, err := s.goquDB.Insert(s.table("poll_sources")).Prepared(true).
+		Rows(insert).
+		OnConflict(goqu.DoUpdate("source", record)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save poll state: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) UnseenEntries(ctx context.Context, source string, guids []string) ([]string, error) {
+	if len(guids) == 0 {
+		return []string{}, nil
+	}
+
+	var seenGUIDs []string
+	err := s.goquDB.From(s.table("poll_seen_entries")).Prepared(true).
+		Select("guid").
+		Where(goqu.C("source").Eq(source), goqu.C("guid").In(guids)).
+		ScanValsContext(ctx, &seenGUIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load seen entries: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, guid := range seenGUIDs {
+		seen[guid] = true
+	}
+
+	unseen := []string{}
+	for _, guid := range guids {
+		if !seen[guid] {
+			unseen = append(unseen, guid)
+		}
+	}
+
+	return unseen, nil
+}
+
+func (s *SQL) MarkEntriesSeen(ctx context.Context, source string, guids []string, at time.Time) error {
+	if len(guids) == 0 {
+		return nil
+	}
+
+	var records []goqu.Record
+	for _, guid := range guids {
+		records = append(records, goqu.Record{"source": source, "guid": guid, "seen_at": at.UTC()})
+	}
+
+	_, err := s.goquDB.Insert(s.table("poll_seen_entries")).Prepared(true).
+		Rows(records).
+		OnConflict(goqu.DoUpdate("source, guid", goqu.Record{"seen_at": at.UTC()})).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to mark entries seen: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) PruneSeenEntries(ctx context.Context, before time.Time) error {
+	_, err := s.goquDB.Delete(s.table("poll_seen_entries")).Prepared(true).
+		Where(goqu.C("seen_at").Lt(before.UTC())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to prune seen entries: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) TouchSeenEntries(ctx context.Context, source string, at time.Time) error {
+	_, err := s.goquDB.Update(s.table("poll_seen_entries")).Prepared(true).
+		Set(goqu.Record{"seen_at": at.UTC()}).
+		Where(goqu.C("source").Eq(source)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to touch seen entries: %w", err)
+	}
+
+	return nil
+}
+
+// attachmentColumns are the columns loaded when listing attachments, data is only loaded for a single attachment
+var attachmentColumns = []interface{}{"id", "feed", "item_id", "filename", "content_type", "size", "created_at"}
+
+func (s *SQL) AddAttachments(ctx context.Context, feed string, itemID int64, attachments []NewAttachment) ([]Attachment, error) {
+	if len(attachments) == 0 {
+		return []Attachment{}, nil
+	}
+
+	now := time.Now().UTC()
+
+	var records []goqu.Record
+	for _, a := range attachments {
+		records = append(records, goqu.Record{
+			"feed":         feed,
+			"item_id":      itemID,
+			"filename":     a.Filename,
+			"content_type": a.ContentType,
+			"size":         len(a.Data),
+			"data":         a.Data,
+			"created_at":   now,
+		})
+	}
+
+	var added []Attachment
+
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		var before sql.NullInt64
+		_, err := tx.From(s.table("item_attachments")).Prepared(true).
+			Select(goqu.MAX("id")).
+			Where(goqu.C("item_id").Eq(itemID)).
+			ScanValContext(ctx, &before)
+		if err != nil {
+			return fmt.Errorf("failed to load attachments: %w", err)
+		}
+
+		_, err = tx.Insert(s.table("item_attachments")).Prepared(true).Rows(records).Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert attachments: %w", err)
+		}
+
+		err = tx.From(s.table("item_attachments")).Prepared(true).
+			Select(attachmentColumns...).
+			Where(goqu.C("item_id").Eq(itemID), goqu.C("id").Gt(before.Int64)).
+			Order(goqu.C("id").Asc()).
+			ScanStructsContext(ctx, &added)
+		if err != nil {
+			return fmt.Errorf("failed to load inserted attachments: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+func (s *SQL) InsertItemWithAttachments(
+	ctx context.Context, feed string, item NewItem, attachments []NewAttachment, opts InsertOptions,
+) (Item, error) {
+	var created []Item
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		var err error
+		created, err = s.insertItems(ctx, tx, feed, []NewItem{item}, opts)
+		if err != nil {
+			return err
+		}
+
+		if len(attachments) == 0 {
+			return nil
+		}
+
+		now := time.Now().UTC()
+
+		var records []goqu.Record
+		for _, a := range attachments {
+			records = append(records, goqu.Record{
+				"feed":         feed,
+				"item_id":      created[0].ID,
+				"filename":     a.Filename,
+				"content_type": a.ContentType,
+				"size":         len(a.Data),
+				"data":         a.Data,
+				"created_at":   now,
+			})
+		}
+
+		_, err = tx.Insert(s.table("item_attachments")).Prepared(true).Rows(records).Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert attachments: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Item{}, err
+	}
+
+	return created[0], nil
+}
+
+func (s *SQL) GetAttachment(ctx context.Context, feed string, itemID, id int64) (Attachment, bool, error) {
+	var attachment Attachment
+
+	found, err := s.goquDB.From(s.table("item_attachments")).Prepared(true).
+		Where(goqu.C("id").Eq(id), goqu.C("item_id").Eq(itemID), goqu.C("feed").Eq(feed)).
+		ScanStructContext(ctx, &attachment)
+	if err != nil {
+		return Attachment{}, false, fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	return attachment, found, nil
+}
+
+func (s *SQL) ListAttachments(ctx context.Context, feed string, itemIDs []int64) ([]Attachment, error) {
+	attachments := []Attachment{}
+	if len(itemIDs) == 0 {
+		return attachments, nil
+	}
+
+	err := s.goquDB.From(s.table("item_attachments")).Prepared(true).
+		Select(attachmentColumns...).
+		Where(goqu.C("feed").Eq(feed), goqu.C("item_id").In(itemIDs)).
+		Order(goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+func (s *SQL) GetDigestState(ctx context.Context, name string) (DigestState, bool, error) {
+	var state DigestState
+
+	found, err := s.goquDB.From(s.table("digests")).Prepared(true).
+		Where(goqu.C("name").Eq(name)).
+		ScanStructContext(ctx, &state)
+	if err != nil {
+		return DigestState{}, false, fmt.Errorf("failed to get digest state: %w", err)
+	}
+
+	return state, found, nil
+}
+
+func (s *SQL) SaveDigestState(ctx context.Context, state DigestState) error {
+	return s.saveDigestState(ctx, s.goquDB, state)
+}
+
+func (s *SQL) InsertDigestItem(ctx context.Context, feed string, item NewItem, state DigestState) (Item, error) {
+	var created []Item
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		var err error
+		created, err = s.insertItems(ctx, tx, feed, []NewItem{item}, InsertOptions{})
+		if err != nil {
+			return err
+		}
+
+		return s.saveDigestState(ctx, tx, state)
+	})
+	if err != nil {
+		return Item{}, err
+	}
+
+	return created[0], nil
+}
+
+func (s *SQL) saveDigestState(ctx context.Context, q queryer, state DigestState) error {
+	_, err := q.Insert(s.table("digests")).Prepared(true).
+		Rows(goqu.Record{
+			"name":              state.Name,
+			"last_item_id":      state.LastItemID,
+			"included_item_ids": state.IncludedIDs,
+			"last_digest_at":    state.LastDigestAt.UTC(),
+		}).
+		OnConflict(goqu.DoUpdate("name", goqu.Record{
+			"last_item_id":      state.LastItemID,
+			"included_item_ids": state.IncludedIDs,
+			"last_digest_at":    state.LastDigestAt.UTC(),
+		})).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save digest state: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQL) RecordJobRun(ctx context.Context, run JobRun) (JobRun, error) {
+	run.StartedAt = run.StartedAt.UTC()
+	run.FinishedAt = run.FinishedAt.UTC()
+	if run.Stats == nil {
+		run.Stats = JobStats{}
+	}
+
+	insert := s.goquDB.Insert(s.table("job_runs")).Prepared(true).
+		Rows(goqu.Record{
+			"job":         run.Job,
+			"started_at":  run.StartedAt,
+			"finished_at": run.FinishedAt,
+			"outcome":     run.Outcome,
+			"error":       run.Error,
+			"stats":       run.Stats,
+		})
+
+	// sqlite doesn't support returning in goqu's dialect, but reports the id of the inserted row instead
+	if s.goquDB.Dialect() == "postgres" {
+		_, err := insert.Returning("id").Executor().ScanValContext(ctx, &run.ID)
+		if err != nil {
+			return JobRun{}, fmt.Errorf("failed to insert job run: %w", err)
+		}
+
+		return run, nil
+	}
+
+	res, err := insert.Executor().ExecContext(ctx)
+	if err != nil {
+		return JobRun{}, fmt.Errorf("failed to insert job run: %w", err)
+	}
+
+	run.ID, err = res.LastInsertId()
+	if err != nil {
+		return JobRun{}, fmt.Errorf("failed to load job run id: %w", err)
+	}
+
+	return run, nil
+}
+
+func (s *SQL) ListJobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	sel := s.goquDB.From(s.table("job_runs")).Prepared(true).
+		Where(goqu.C("job").Eq(job)).
+		Order(goqu.C("started_at").Desc(), goqu.C("id").Desc())
+	if limit > 0 {
+		sel = sel.Limit(uint(limit))
+	}
+
+	runs := []JobRun{}
+	err := sel.ScanStructsContext(ctx, &runs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+func (s *SQL) PruneJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.goquDB.Delete(s.table("job_runs")).Prepared(true).
+		Where(goqu.C("started_at").Lt(before.UTC())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune job runs: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQL) GetHeartbeatState(ctx context.Context, name string) (HeartbeatState, bool, error) {
+	var state HeartbeatState
+
+	found, err := s.goquDB.From(s.table("heartbeat_states")).Prepared(true).
+		Where(goqu.C("name").Eq(name)).
+		ScanStructContext(ctx, &state)
+	if err != nil {
+		return HeartbeatState{}, false, fmt.Errorf("failed to get heartbeat state: %w", err)
+	}
+
+	return state, found, nil
+}
+
+func (s *SQL) SaveHeartbeatState(ctx context.Context, state HeartbeatState) error {
+	_, err := s.goquDB.Insert(s.table("heartbeat_states")).Prepared(true).
+		Rows(goqu.Record{
+			"name":       state.Name,
+			"status":     state.Status,
+			"changed_at": state.ChangedAt.UTC(),
+		}).
+		OnConflict(goqu.DoUpdate("name", goqu.Record{
+			"status":     state.Status,
+			"changed_at": state.ChangedAt.UTC(),
+		})).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save heartbeat state: %w", err)
+	}
+
+	return nil
+}
+
+// starredItemIDs selects the ids of items starred by any user, these are kept when cleaning feeds
+func (s *SQL) starredItemIDs() *goqu.SelectDataset {
+	return s.goquDB.From(s.table("item_states")).
+		Select("item_id").
+		Where(goqu.C("starred").Eq(true))
+}
+
+// readItemIDs selects the ids of items which the user has read or archived
+func (s *SQL) readItemIDs(user string) *goqu.SelectDataset {
+	return s.goquDB.From(s.table("item_states")).
+		Select("item_id").
+		Where(
+			goqu.C("username").Eq(user),
+			goqu.Or(goqu.C("read").Eq(true), goqu.C("archived").Eq(true)),
+		)
+}
+
+func (s *SQL) UpdateItemState(
+	ctx context.Context,
+	user, feed string,
+	id int64,
+	update ItemStateUpdate,
+) (ItemState, bool, error) {
+	var state ItemState
+	var found bool
+
+	err := s.withTx(ctx, func(tx *goqu.TxDatabase) error {
+		var itemID int64
+		var err error
+		found, err = tx.From(s.table("items")).Prepared(true).
+			Select("id").
+			Where(goqu.C("feed").Eq(feed), goqu.C("id").Eq(id)).
+			ScanValContext(ctx, &itemID)
+		if err != nil {
+			return fmt.Errorf("failed to get item: %w", err)
+		}
+		if !found {
+			return nil
+		}
+
+		_, err = tx.From(s.table("item_states")).Prepared(true).
+			Where(goqu.C("username").Eq(user), goqu.C("item_id").Eq(id)).
+			ScanStructContext(ctx, &state)
+		if err != nil {
+			return fmt.Errorf("failed to get item state: %w", err)
+		}
+
+		state = update.apply(state)
+		state.User = user
+		state.ItemID = id
+		state.Feed = feed
+		state.UpdatedAt = time.Now().UTC()
+
+		_, err = tx.Insert(s.table("item_states")).Prepared(true).
+			Rows(goqu.Record{
+				"username":   state.User,
+				"item_id":    state.ItemID,
+				"feed":       state.Feed,
+				"read":       state.Read,
+				"starred":    state.Starred,
+				"archived":   state.Archived,
+				"updated_at": state.UpdatedAt,
+			}).
+			OnConflict(goqu.DoUpdate("username, item_id", goqu.Record{
+				"read":       state.Read,
+				"starred":    state.Starred,
+				"archived":   state.Archived,
+				"updated_at": state.UpdatedAt,
+			})).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to save item state: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ItemState{}, false, err
+	}
+
+	return state, found, nil
+}
+
+func (s *SQL) ListItemStates(ctx context.Context, user, feed string, ids []int64) ([]ItemState, error) {
+	states := []ItemState{}
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	err := s.goquDB.From(s.table("item_states")).Prepared(true).
+		Where(goqu.C("username").Eq(user), goqu.C("feed").Eq(feed), goqu.C("item_id").In(ids)).
+		Order(goqu.C("item_id").Asc()).
+		ScanStructsContext(ctx, &states)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list item states: %w", err)
+	}
+
+	return states, nil
+}
+
+func (s *SQL) UnreadCounts(ctx context.Context, user string) ([]UnreadCount, error) {
+	now := time.Now().UTC()
+
+	counts := []UnreadCount{}
+	err := s.goquDB.From(s.table("items")).Prepared(true).
+		Select(goqu.C("feed"), goqu.COUNT("id").As("count")).
+		Where(
+			goqu.C("created_at").Lt(now),
+			goqu.Or(goqu.C("expires_at").IsNull(), goqu.C("expires_at").Gt(now)),
+			goqu.C("id").NotIn(s.readItemIDs(user)),
+		).
+		GroupBy("feed").
+		Order(goqu.C("feed").Asc()).
+		ScanStructsContext(ctx, &counts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread items: %w", err)
+	}
+
+	return counts, nil
+}
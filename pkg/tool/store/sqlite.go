@@ -0,0 +1,85 @@
+package store
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/golang-migrate/migrate/v4"
+	migrateSQLite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	// registers the pure Go sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/sqlite
+var sqliteMigrations embed.FS
+
+// OpenSQLite opens, and migrates, the SQLite database at path. Use ":memory:" for a temporary database.
+func OpenSQLite(path string) (*SQL, error) {
+	// times are written in a fixed format so that they sort correctly as text
+	db, err := sql.Open("sqlite", fmt.Sprintf(
+		"file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite",
+		path,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite only supports a single writer, using one connection avoids lock contention between transactions.
+	// This also means that in memory databases are shared by all queries.
+	db.SetMaxOpenConns(1)
+
+	s := &SQL{
+		db:      db,
+		goquDB:  goqu.New("sqlite3", db),
+		closeDB: true,
+	}
+
+	m, err := s.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		db.Close()
+		return nil, fmt.Errorf("failed to run sqlite migrations: %w", err)
+	}
+
+	return s, nil
+}
+
+// SQLiteMigrator returns a migrate instance for the SQLite database, it's used to run migrations by hand
+func (s *SQL) SQLiteMigrator() (*migrate.Migrate, error) {
+	if s.goquDB.Dialect() != "sqlite3" {
+		return nil, fmt.Errorf("store is not using sqlite")
+	}
+
+	return s.migrate()
+}
+
+// migrate returns a migrate instance for the SQLite database
+func (s *SQL) migrate() (*migrate.Migrate, error) {
+	driver, err := migrateSQLite.WithInstance(s.db, &migrateSQLite.Config{
+		MigrationsTable: migrationsTable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlite migration driver: %w", err)
+	}
+
+	source, err := iofs.New(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sqlite migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite", driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlite migrate instance: %w", err)
+	}
+
+	return m, nil
+}
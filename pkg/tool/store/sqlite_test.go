@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteMigrationVersion(t *testing.T) {
+	s, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+
+	require.NoError(t, s.Ping(ctx))
+
+	latest, err := LatestSQLiteMigration()
+	require.NoError(t, err)
+	assert.NotZero(t, latest)
+
+	version, dirty, err := s.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version, "opening the database should run every migration")
+	assert.False(t, dirty)
+}
@@ -0,0 +1,546 @@
+// Package store holds the persistence used by the webhook-rss tool. Handlers and jobs use the interfaces defined
+// here so that the tool can be run against Postgres, a single SQLite file, or in memory for tests.
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when inserting items would take a feed over its daily usage limit
+var ErrQuotaExceeded = errors.New("feed daily quota exceeded")
+
+// Store is implemented by each of the storage backends
+type Store interface {
+	ItemStore
+	SecretStore
+	SubscriptionStore
+	OutboxStore
+	PollStore
+	AttachmentStore
+	DigestStore
+	JobRunStore
+	HeartbeatStateStore
+	ItemStateStore
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// Item is an item stored in a feed
+type Item struct {
+	ID        int64     `db:"id"`
+	Feed      string    `db:"feed"`
+	GUID      string    `db:"guid"`
+	Title     string    `db:"title"`
+	Body      string    `db:"body"`
+	URL       string    `db:"url"`
+	Tags      Tags      `db:"tags"`
+	CreatedAt time.Time `db:"created_at"`
+	// ExpiresAt is when the item is removed from its feed, it's nil for items which don't expire
+	ExpiresAt *time.Time `db:"expires_at"`
+	Priority  Priority   `db:"priority"`
+	// Pinned items are listed first in feeds and aren't removed when feeds are trimmed
+	Pinned bool `db:"pinned"`
+	// Announced is false for scheduled items until they're published and claimed by ClaimPublishedItems
+	Announced bool `db:"announced"`
+}
+
+// Priority is the importance of an item
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// Priorities lists the valid priorities, least important first
+var Priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityUrgent}
+
+// ParsePriority returns the priority with the given name, ok is false if there is no such priority
+func ParsePriority(name string) (priority Priority, ok bool) {
+	for _, p := range Priorities {
+		if string(p) == name {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+// AtLeast returns true when p is as important as other, or more important
+func (p Priority) AtLeast(other Priority) bool {
+	return p.rank() >= other.rank()
+}
+
+func (p Priority) rank() int {
+	for i, priority := range Priorities {
+		if p == priority {
+			return i
+		}
+	}
+
+	// items stored before priorities were added are normal
+	return 1
+}
+
+// NewItem is an item to be inserted into a feed. If CreatedAt is zero, the current time is used. Items with a
+// CreatedAt in the future are scheduled, they're published at that time.
+type NewItem struct {
+	GUID      string
+	Title     string
+	Body      string
+	URL       string
+	Tags      Tags
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+	// Priority defaults to PriorityNormal when it's empty
+	Priority Priority
+	Pinned   bool
+}
+
+// priority returns the item's priority, using the default when it's not set
+func (i NewItem) priority() Priority {
+	if i.Priority == "" {
+		return PriorityNormal
+	}
+
+	return i.Priority
+}
+
+// Limits on the items in feeds. Items sent to the API over them are rejected, items from other sources are
+// truncated with Truncate.
+const (
+	MaxTitleBytes = 500
+	MaxBodyBytes  = 100000
+	MaxItemTags   = 20
+)
+
+// Truncate shortens s to at most n bytes, without splitting a character
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// size is the number of bytes counted against a feed's daily usage for the item
+func (i NewItem) size() int64 {
+	return int64(len(i.Title) + len(i.Body) + len(i.URL))
+}
+
+// InsertOptions configures limits applied when inserting items
+type InsertOptions struct {
+	// MaxFeedBytesPerDay limits the bytes accepted by a feed per UTC day, 0 disables the limit
+	MaxFeedBytesPerDay int64
+	// Now is the time the items are inserted at, which sets the day their bytes are counted against. Items without
+	// a date are created at this time. It defaults to the current time.
+	Now time.Time
+}
+
+// now returns the time the items are inserted at in UTC
+func (o InsertOptions) now() time.Time {
+	if o.Now.IsZero() {
+		return time.Now().UTC()
+	}
+	return o.Now.UTC()
+}
+
+// ListOptions configures which items are returned when listing a feed
+type ListOptions struct {
+	// Limit is the maximum number of items to return, 0 returns all items
+	Limit int
+	// Offset is the number of items to skip, it's used to page through a feed
+	Offset int
+	// PinnedFirst lists pinned items before the others, rather than in date order
+	PinnedFirst bool
+	// UnreadBy lists only the items which this user hasn't read or archived
+	UnreadBy string
+}
+
+// FeedStats summarises the items in a feed
+type FeedStats struct {
+	Feed     string    `db:"feed"`
+	Count    int64     `db:"count"`
+	LatestAt time.Time `db:"latest_at"`
+}
+
+// ItemStore stores the items in feeds
+type ItemStore interface {
+	// InsertItems inserts items into a feed and returns them with their assigned ids. If the items would take the
+	// feed over its daily limit, ErrQuotaExceeded is returned and no items are inserted.
+	InsertItems(ctx context.Context, feed string, items []NewItem, opts InsertOptions) ([]Item, error)
+	// ListItems returns the published items in a feed, newest first. Items dated in the future are not published
+	// and expired items are left out.
+	ListItems(ctx context.Context, feed string, opts ListOptions) ([]Item, error)
+	// ListScheduledItems returns the items in a feed which are dated in the future, soonest first
+	ListScheduledItems(ctx context.Context, feed string) ([]Item, error)
+	// LatestItem returns the newest published item in a feed, found is false when the feed has none. Scheduled and
+	// expired items are left out.
+	LatestItem(ctx context.Context, feed string) (item Item, found bool, err error)
+	// ListItemsAfter returns up to limit published items in a feed with an id greater than afterID, in the order they
+	// were inserted. Items dated in the future and expired items are left out.
+	ListItemsAfter(ctx context.Context, feed string, afterID int64, limit int) ([]Item, error)
+	// ListAllItemsAfter is like ListItemsAfter, but includes scheduled and expired items. It's used to export
+	// feeds, and by digests so they don't move past scheduled items.
+	ListAllItemsAfter(ctx context.Context, feed string, afterID int64, limit int) ([]Item, error)
+	// ClaimPublishedItems returns the scheduled items which have been published by the given time, so that they can
+	// be announced. Each item is only returned once, items which weren't scheduled when inserted are never returned.
+	ClaimPublishedItems(ctx context.Context, now time.Time) ([]Item, error)
+	// LatestItemID returns the id of the most recently inserted item in a feed, or 0 when the feed is empty
+	LatestItemID(ctx context.Context, feed string) (int64, error)
+	// ExistingGUIDs returns those of the given guids which are already used by items in a feed
+	ExistingGUIDs(ctx context.Context, feed string, guids []string) ([]string, error)
+	// DeleteItems removes items from a feed by id, returning the number removed
+	DeleteItems(ctx context.Context, feed string, ids []int64) (int64, error)
+	// DeleteFeed removes every item from a feed, including scheduled, expired, pinned and starred items, returning
+	// the number removed
+	DeleteFeed(ctx context.Context, feed string) (int64, error)
+	// SetItemPinned pins or unpins an item in a feed, found is false if there is no such item
+	SetItemPinned(ctx context.Context, feed string, id int64, pinned bool) (found bool, err error)
+
+	// FeedStats returns the item count and newest item time for each feed with items. Scheduled and expired items
+	// are counted, but not used as the newest item, so LatestAt is zero for feeds with only those.
+	FeedStats(ctx context.Context) ([]FeedStats, error)
+	// TrimFeeds removes overflow items from feeds with more than keep items, keeping the newest keep items across
+	// those feeds and returning the number removed. Pinned items and items starred by any user are kept, and aren't
+	// counted.
+	TrimFeeds(ctx context.Context, keep int) (int64, error)
+	// DeleteExpiredItems removes items which expired before the given time, returning the number removed. Items
+	// starred by any user are kept.
+	DeleteExpiredItems(ctx context.Context, before time.Time) (int64, error)
+	// PruneUsage removes daily usage records for days before the given time
+	PruneUsage(ctx context.Context, before time.Time) error
+}
+
+// Secret is a read secret issued for a feed. Only a hash of the secret is stored.
+type Secret struct {
+	ID         int64      `db:"id"`
+	Feed       string     `db:"feed"`
+	Label      string     `db:"label"`
+	SecretHash string     `db:"secret_hash"`
+	CreatedAt  time.Time  `db:"created_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// SecretStore stores the read secrets for private feeds
+type SecretStore interface {
+	// CreateSecret stores the hash of a newly issued secret for a feed
+	CreateSecret(ctx context.Context, feed, label, secretHash string) (Secret, error)
+	// ListSecrets returns all the secrets for a feed, including revoked ones
+	ListSecrets(ctx context.Context, feed string) ([]Secret, error)
+	// RevokeSecret revokes an active secret, found is false if there was no such active secret
+	RevokeSecret(ctx context.Context, feed string, id int64) (found bool, err error)
+	// ActiveSecretHashes returns the hashes of the feed's active secrets
+	ActiveSecretHashes(ctx context.Context, feed string) ([]string, error)
+	// FeedPrivate returns true when a feed needs a read secret. Feeds are made private when their first secret is
+	// created, and stay private when their secrets are revoked.
+	FeedPrivate(ctx context.Context, feed string) (bool, error)
+	// SetFeedPrivate makes a feed private or public
+	SetFeedPrivate(ctx context.Context, feed string, private bool) error
+}
+
+// Subscription is a verified WebSub subscription to a feed
+type Subscription struct {
+	ID       int64  `db:"id"`
+	Feed     string `db:"feed"`
+	Topic    string `db:"topic"`
+	Callback string `db:"callback"`
+	// Secret is used to sign content sent to the subscriber, it's optional
+	Secret       string    `db:"secret"`
+	LeaseSeconds int64     `db:"lease_seconds"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// SubscriptionStore stores WebSub subscriptions
+type SubscriptionStore interface {
+	// UpsertSubscription creates a subscription, or updates the existing one for the same topic and callback
+	UpsertSubscription(ctx context.Context, sub Subscription) (Subscription, error)
+	// DeleteSubscription removes the subscription for a topic and callback, if there is one
+	DeleteSubscription(ctx context.Context, topic, callback string) error
+	// ListSubscriptions returns the unexpired subscriptions to a feed
+	ListSubscriptions(ctx context.Context, feed string) ([]Subscription, error)
+	// ListExpiringSubscriptions returns the unexpired subscriptions which expire before the given time
+	ListExpiringSubscriptions(ctx context.Context, before time.Time) ([]Subscription, error)
+	// DeleteExpiredSubscriptions removes subscriptions which expired before the given time
+	DeleteExpiredSubscriptions(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Delivery states in the outbox
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead is used for deliveries which failed too many times, they're kept for inspection and can be retried
+	DeliveryDead = "dead"
+)
+
+// Delivery is an item waiting in the outbox to be sent to a fan-out target, or one which has been sent
+type Delivery struct {
+	ID     int64  `db:"id"`
+	Target string `db:"target"`
+	Feed   string `db:"feed"`
+	ItemID int64  `db:"item_id"`
+
+	// ContentType and Body are the request rendered for the target when the delivery was created
+	ContentType string `db:"content_type"`
+	Body        string `db:"body"`
+
+	Status        string    `db:"status"`
+	Attempts      int       `db:"attempts"`
+	LastError     string    `db:"last_error"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+// NewDelivery is a delivery to add to the outbox
+type NewDelivery struct {
+	Target      string
+	Feed        string
+	ItemID      int64
+	ContentType string
+	Body        string
+}
+
+// OutboxStore holds deliveries of items to fan-out targets
+type OutboxStore interface {
+	// EnqueueDeliveries adds pending deliveries to the outbox, to be attempted immediately
+	EnqueueDeliveries(ctx context.Context, deliveries []NewDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries which are due, counting an attempt for each. Their next
+	// attempt is delayed by lease, so they aren't claimed again while they're being delivered.
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	// CompleteDelivery marks a delivery as delivered
+	CompleteDelivery(ctx context.Context, id int64) error
+	// RetryDelivery records a failed attempt and schedules the next attempt
+	RetryDelivery(ctx context.Context, id int64, reason string, at time.Time) error
+	// DeadLetterDelivery records a failed attempt and stops further attempts
+	DeadLetterDelivery(ctx context.Context, id int64, reason string) error
+	// RequeueDelivery makes a dead delivery pending again, found is false when there's no dead delivery with the id
+	RequeueDelivery(ctx context.Context, id int64) (found bool, err error)
+	// ListDeliveries returns the newest deliveries with the given status
+	ListDeliveries(ctx context.Context, status string, limit int) ([]Delivery, error)
+	// PruneDeliveries removes delivered items last updated before the given time
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// PollState is the state kept between polls of an external feed
+type PollState struct {
+	Source string `db:"source"`
+
+	// ETag and LastModified are sent in conditional requests for the source
+	ETag         string `db:"etag"`
+	LastModified string `db:"last_modified"`
+
+	// Failures is the number of consecutive failed polls, it's used to back off
+	Failures     int        `db:"failures"`
+	LastError    string     `db:"last_error"`
+	LastPolledAt *time.Time `db:"last_polled_at"`
+	NextPollAt   time.Time  `db:"next_poll_at"`
+}
+
+// PollStore stores the state of polled external feeds, and the entries which have been seen in them
+type PollStore interface {
+	// GetPollState returns the state for a source, found is false if it has never been polled
+	GetPollState(ctx context.Context, source string) (state PollState, found bool, err error)
+	// SavePollState creates or replaces the state for a source
+	SavePollState(ctx context.Context, state PollState) error
+	// UnseenEntries returns the guids which haven't been seen in the source before
+	UnseenEntries(ctx context.Context, source string, guids []string) ([]string, error)
+	// MarkEntriesSeen records the guids as seen in the source at the given time
+	MarkEntriesSeen(ctx context.Context, source string, guids []string, at time.Time) error
+	// TouchSeenEntries updates the time all of a source's entries were last seen, it's used when the source is
+	// unchanged
+	TouchSeenEntries(ctx context.Context, source string, at time.Time) error
+	// PruneSeenEntries removes entries which were last seen before the given time
+	PruneSeenEntries(ctx context.Context, before time.Time) error
+}
+
+// Attachment is a file attached to an item, such as an email attachment. It's linked from the item as an enclosure.
+type Attachment struct {
+	ID          int64     `db:"id"`
+	Feed        string    `db:"feed"`
+	ItemID      int64     `db:"item_id"`
+	Filename    string    `db:"filename"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	CreatedAt   time.Time `db:"created_at"`
+
+	// Data is only loaded by GetAttachment
+	Data []byte `db:"data"`
+}
+
+// NewAttachment is a file to attach to an item
+type NewAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// AttachmentStore stores files attached to items. Attachments are removed with their items.
+type AttachmentStore interface {
+	// AddAttachments stores files attached to an item and returns them, without their data
+	AddAttachments(ctx context.Context, feed string, itemID int64, attachments []NewAttachment) ([]Attachment, error)
+	// InsertItemWithAttachments inserts an item along with the files attached to it, neither is stored if the other
+	// fails
+	InsertItemWithAttachments(
+		ctx context.Context, feed string, item NewItem, attachments []NewAttachment, opts InsertOptions,
+	) (Item, error)
+	// GetAttachment returns an attachment with its data, found is false if there's no such attachment on the item
+	GetAttachment(ctx context.Context, feed string, itemID, id int64) (attachment Attachment, found bool, err error)
+	// ListAttachments returns the attachments on the given items, without their data, in the order they were added
+	ListAttachments(ctx context.Context, feed string, itemIDs []int64) ([]Attachment, error)
+}
+
+// DigestState records how far through its source feed a digest has got
+type DigestState struct {
+	Name string `db:"name"`
+	// LastItemID is the id of the last source item included in a digest, before any item which is still scheduled
+	LastItemID int64 `db:"last_item_id"`
+	// IncludedIDs are the source items after LastItemID which have already been included in a digest. These were
+	// published while an earlier item was still scheduled.
+	IncludedIDs ItemIDs `db:"included_item_ids"`
+	// LastDigestAt is the time the last digest was created
+	LastDigestAt time.Time `db:"last_digest_at"`
+}
+
+// DigestStore stores the state of digests
+type DigestStore interface {
+	// GetDigestState returns the state of a digest, found is false if it has never run
+	GetDigestState(ctx context.Context, name string) (state DigestState, found bool, err error)
+	// SaveDigestState creates or replaces the state of a digest
+	SaveDigestState(ctx context.Context, state DigestState) error
+	// InsertDigestItem inserts a digest into a feed and saves the digest's state, neither is stored if the other
+	// fails
+	InsertDigestItem(ctx context.Context, feed string, item NewItem, state DigestState) (Item, error)
+}
+
+// Job run outcomes
+const (
+	JobRunSuccess = "success"
+	JobRunFailure = "failure"
+	JobRunTimeout = "timeout"
+)
+
+// JobRun is a recorded run of a job
+type JobRun struct {
+	ID         int64     `db:"id"`
+	Job        string    `db:"job"`
+	StartedAt  time.Time `db:"started_at"`
+	FinishedAt time.Time `db:"finished_at"`
+	// Outcome is one of JobRunSuccess, JobRunFailure or JobRunTimeout
+	Outcome string `db:"outcome"`
+	// Error is the error returned by the run, it's empty when the run succeeded
+	Error string `db:"error"`
+	// Stats are the counters reported by the job during the run
+	Stats JobStats `db:"stats"`
+}
+
+// JobRunStore stores the history of job runs
+type JobRunStore interface {
+	// RecordJobRun stores a job run and returns it with its assigned id
+	RecordJobRun(ctx context.Context, run JobRun) (JobRun, error)
+	// ListJobRuns returns the most recent runs of a job, newest first. A limit of 0 returns all runs.
+	ListJobRuns(ctx context.Context, job string, limit int) ([]JobRun, error)
+	// PruneJobRuns removes runs which started before the given time
+	PruneJobRuns(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Heartbeat statuses
+const (
+	HeartbeatUp   = "up"
+	HeartbeatDown = "down"
+)
+
+// HeartbeatState records the last known status of a heartbeat, so that changes can be reported
+type HeartbeatState struct {
+	Name string `db:"name"`
+	// Status is HeartbeatUp or HeartbeatDown
+	Status    string    `db:"status"`
+	ChangedAt time.Time `db:"changed_at"`
+}
+
+// HeartbeatStateStore stores the status of heartbeats
+type HeartbeatStateStore interface {
+	// GetHeartbeatState returns the state of a heartbeat, found is false if it has never been checked
+	GetHeartbeatState(ctx context.Context, name string) (state HeartbeatState, found bool, err error)
+	// SaveHeartbeatState creates or replaces the state of a heartbeat
+	SaveHeartbeatState(ctx context.Context, state HeartbeatState) error
+}
+
+// ItemState is a user's state for an item
+type ItemState struct {
+	User      string    `db:"username"`
+	ItemID    int64     `db:"item_id"`
+	Feed      string    `db:"feed"`
+	Read      bool      `db:"read"`
+	Starred   bool      `db:"starred"`
+	Archived  bool      `db:"archived"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ItemStateUpdate changes a user's state for an item, nil fields are left unchanged
+type ItemStateUpdate struct {
+	Read     *bool
+	Starred  *bool
+	Archived *bool
+}
+
+// apply returns the state with the update's changes
+func (u ItemStateUpdate) apply(state ItemState) ItemState {
+	if u.Read != nil {
+		state.Read = *u.Read
+	}
+	if u.Starred != nil {
+		state.Starred = *u.Starred
+	}
+	if u.Archived != nil {
+		state.Archived = *u.Archived
+	}
+
+	return state
+}
+
+// UnreadCount is the number of items in a feed which a user hasn't read or archived
+type UnreadCount struct {
+	Feed  string `db:"feed"`
+	Count int64  `db:"count"`
+}
+
+// ItemStateStore stores each user's read, starred and archived state for items
+type ItemStateStore interface {
+	// UpdateItemState changes a user's state for an item in a feed and returns the new state, found is false if
+	// there is no such item
+	UpdateItemState(
+		ctx context.Context,
+		user, feed string,
+		id int64,
+		update ItemStateUpdate,
+	) (state ItemState, found bool, err error)
+	// ListItemStates returns a user's states for the given items in a feed, items the user has never changed are
+	// left out
+	ListItemStates(ctx context.Context, user, feed string, ids []int64) ([]ItemState, error)
+	// UnreadCounts returns the number of published items in each feed which the user hasn't read or archived.
+	// Feeds without unread items are left out.
+	UnreadCounts(ctx context.Context, user string) ([]UnreadCount, error)
+}
+
+// utcOrNil returns t in UTC, or nil when t is nil
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+	return &utc
+}
+
+// usageDay returns the UTC day against which usage at t is counted
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
@@ -0,0 +1,1104 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStores returns each of the stores which can be tested without a database server. The Postgres store is
+// covered by the tool's database test suite.
+func testStores(t *testing.T) map[string]Store {
+	sqlite, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlite.Close() })
+
+	return map[string]Store{
+		"memory": NewMemory(),
+		"sqlite": sqlite,
+	}
+}
+
+func TestItemStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			past := time.Date(2022, 10, 12, 15, 4, 5, 0, time.UTC)
+
+			created, err := s.InsertItems(ctx, "example", []NewItem{
+				{GUID: "a", Title: "first", CreatedAt: past},
+				{GUID: "b", Title: "second", Body: "body", URL: "https://example.com"},
+				{GUID: "c", Title: "scheduled", CreatedAt: time.Now().Add(time.Hour)},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			require.Len(t, created, 3)
+			assert.Equal(t, "example", created[0].Feed)
+			assert.Equal(t, "a", created[0].GUID)
+			assert.True(t, past.Equal(created[0].CreatedAt))
+
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "a", Title: "duplicate"}}, InsertOptions{})
+			assert.Error(t, err, "guids should be unique within a feed")
+
+			items, err := s.ListItems(ctx, "example", ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, items, 2, "future items should not be listed")
+			assert.Equal(t, "second", items[0].Title)
+			assert.Equal(t, "body", items[0].Body)
+			assert.Equal(t, "https://example.com", items[0].URL)
+			assert.Equal(t, "first", items[1].Title)
+
+			items, err = s.ListItems(ctx, "example", ListOptions{Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+
+			items, err = s.ListItems(ctx, "example", ListOptions{Limit: 1, Offset: 1})
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+			assert.Equal(t, "first", items[0].Title)
+
+			items, err = s.ListItems(ctx, "example", ListOptions{Offset: 2})
+			require.NoError(t, err)
+			assert.Empty(t, items)
+
+			latest, found, err := s.LatestItem(ctx, "example")
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, "second", latest.Title, "scheduled items shouldn't be the latest")
+
+			_, found, err = s.LatestItem(ctx, "missing")
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			expired := time.Now().Add(-time.Minute)
+			_, err = s.InsertItems(ctx, "unpublished", []NewItem{
+				{GUID: "a", Title: "expired", CreatedAt: past, ExpiresAt: &expired},
+				{GUID: "b", Title: "scheduled", CreatedAt: time.Now().Add(time.Hour)},
+			}, InsertOptions{})
+			require.NoError(t, err)
+
+			_, found, err = s.LatestItem(ctx, "unpublished")
+			require.NoError(t, err)
+			assert.False(t, found, "scheduled and expired items shouldn't be the latest")
+
+			stats, err := s.FeedStats(ctx)
+			require.NoError(t, err)
+			require.Len(t, stats, 2)
+			assert.Equal(t, "example", stats[0].Feed)
+			assert.Equal(t, int64(3), stats[0].Count)
+			assert.True(t, latest.CreatedAt.Equal(stats[0].LatestAt))
+			assert.Equal(t, "unpublished", stats[1].Feed)
+			assert.Equal(t, int64(2), stats[1].Count)
+			assert.True(t, stats[1].LatestAt.IsZero(), "feeds with only unpublished items have no latest time")
+
+			deleted, err := s.DeleteItems(ctx, "example", []int64{created[2].ID})
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+
+			deleted, err = s.DeleteItems(ctx, "other", []int64{created[0].ID})
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), deleted, "items should only be deleted from the given feed")
+
+			_, err = s.SetItemPinned(ctx, "example", created[0].ID, true)
+			require.NoError(t, err)
+
+			deleted, err = s.DeleteFeed(ctx, "unpublished")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), deleted, "scheduled and expired items should be deleted with their feed")
+
+			deleted, err = s.DeleteFeed(ctx, "example")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), deleted, "pinned items should be deleted with their feed")
+
+			stats, err = s.FeedStats(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, stats)
+		})
+	}
+}
+
+func TestItemStoreTrimFeeds(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			base := time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC)
+
+			for feed, count := range map[string]int{"big": 5, "small": 2, "newer": 4} {
+				start := base
+				if feed == "newer" {
+					start = base.Add(time.Hour)
+				}
+
+				var items []NewItem
+				for i := 0; i < count; i++ {
+					items = append(items, NewItem{
+						GUID:      fmt.Sprint(i),
+						Title:     fmt.Sprintf("item %d", i),
+						CreatedAt: start.Add(time.Duration(i) * time.Minute),
+					})
+				}
+
+				_, err := s.InsertItems(ctx, feed, items, InsertOptions{})
+				require.NoError(t, err)
+			}
+
+			removed, err := s.TrimFeeds(ctx, 3)
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), removed)
+
+			// the newest items are kept across all the feeds over the limit
+			items, err := s.ListItems(ctx, "newer", ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, items, 3)
+			assert.Equal(t, "item 3", items[0].Title)
+			assert.Equal(t, "item 1", items[2].Title)
+
+			items, err = s.ListItems(ctx, "big", ListOptions{})
+			require.NoError(t, err)
+			assert.Empty(t, items)
+
+			items, err = s.ListItems(ctx, "small", ListOptions{})
+			require.NoError(t, err)
+			assert.Len(t, items, 2, "feeds under the limit should be left alone")
+		})
+	}
+}
+
+func TestItemStoreQuota(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			opts := InsertOptions{MaxFeedBytesPerDay: 10}
+
+			_, err := s.InsertItems(ctx, "example", []NewItem{{GUID: "a", Title: "12345"}}, opts)
+			require.NoError(t, err)
+
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "b", Title: "123456"}}, opts)
+			require.ErrorIs(t, err, ErrQuotaExceeded)
+
+			// rejected items aren't counted
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "c", Title: "12345"}}, opts)
+			require.NoError(t, err)
+
+			// other feeds have their own quota
+			_, err = s.InsertItems(ctx, "other", []NewItem{{GUID: "a", Title: "12345"}}, opts)
+			require.NoError(t, err)
+
+			err = s.PruneUsage(ctx, time.Now().Add(48*time.Hour))
+			require.NoError(t, err)
+
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "d", Title: "12345"}}, opts)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestItemStoreQuotaDayBoundary(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			endOfDay := time.Date(2022, 10, 12, 23, 59, 59, 0, time.UTC)
+			opts := InsertOptions{MaxFeedBytesPerDay: 10, Now: endOfDay}
+
+			_, err := s.InsertItems(ctx, "example", []NewItem{{GUID: "a", Title: "1234567890"}}, opts)
+			require.NoError(t, err, "items up to the limit should be accepted")
+
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "b", Title: "1"}}, opts)
+			require.ErrorIs(t, err, ErrQuotaExceeded)
+
+			// usage is counted per UTC day, so the same time in another zone is still the same day
+			opts.Now = endOfDay.In(time.FixedZone("UTC+2", 2*60*60))
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "b", Title: "1"}}, opts)
+			require.ErrorIs(t, err, ErrQuotaExceeded)
+
+			opts.Now = endOfDay.Add(time.Second)
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "b", Title: "1234567890"}}, opts)
+			require.NoError(t, err, "the quota should reset on the next day")
+
+			_, err = s.InsertItems(ctx, "example", []NewItem{{GUID: "c", Title: "1"}}, opts)
+			require.ErrorIs(t, err, ErrQuotaExceeded)
+
+			items, err := s.ListItems(ctx, "example", ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+			assert.True(t, endOfDay.Add(time.Second).Equal(items[0].CreatedAt), "items should be created at the insert time")
+		})
+	}
+}
+
+func TestItemStoreScheduledAndExpiring(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			now := time.Now()
+			expired := now.Add(-time.Minute)
+			expires := now.Add(time.Hour)
+
+			created, err := s.InsertItems(ctx, "maintenance", []NewItem{
+				{GUID: "a", Title: "published"},
+				{GUID: "b", Title: "expired", ExpiresAt: &expired},
+				{GUID: "c", Title: "expiring", ExpiresAt: &expires},
+				{GUID: "d", Title: "later", CreatedAt: now.Add(2 * time.Hour)},
+				{GUID: "e", Title: "sooner", CreatedAt: now.Add(time.Hour), ExpiresAt: &expires},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			require.NotNil(t, created[2].ExpiresAt)
+			assert.WithinDuration(t, expires, *created[2].ExpiresAt, time.Second)
+			assert.Nil(t, created[0].ExpiresAt)
+
+			items, err := s.ListItems(ctx, "maintenance", ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, items, 2, "scheduled and expired items should not be listed")
+			assert.ElementsMatch(t, []string{"published", "expiring"}, []string{items[0].Title, items[1].Title})
+
+			scheduled, err := s.ListScheduledItems(ctx, "maintenance")
+			require.NoError(t, err)
+			require.Len(t, scheduled, 2)
+			assert.Equal(t, "sooner", scheduled[0].Title)
+			assert.Equal(t, "later", scheduled[1].Title)
+
+			deleted, err := s.DeleteExpiredItems(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+
+			deleted, err = s.DeleteExpiredItems(ctx, now.Add(2*time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), deleted)
+
+			stats, err := s.FeedStats(ctx)
+			require.NoError(t, err)
+			require.Len(t, stats, 1)
+			assert.Equal(t, int64(2), stats[0].Count)
+		})
+	}
+}
+
+func TestItemStorePinnedAndPriority(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			base := time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC)
+
+			created, err := s.InsertItems(ctx, "deploys", []NewItem{
+				{GUID: "a", Title: "freeze in effect", CreatedAt: base, Priority: PriorityUrgent, Pinned: true},
+				{GUID: "b", Title: "deploy 1", CreatedAt: base.Add(time.Minute)},
+				{GUID: "c", Title: "deploy 2", CreatedAt: base.Add(2 * time.Minute), Priority: PriorityLow},
+				{GUID: "d", Title: "deploy 3", CreatedAt: base.Add(3 * time.Minute)},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, PriorityUrgent, created[0].Priority)
+			assert.True(t, created[0].Pinned)
+			assert.Equal(t, PriorityNormal, created[1].Priority, "priority should default to normal")
+			assert.False(t, created[1].Pinned)
+
+			items, err := s.ListItems(ctx, "deploys", ListOptions{Limit: 2})
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+			assert.Equal(t, "deploy 3", items[0].Title, "pinned items should be in date order by default")
+
+			items, err = s.ListItems(ctx, "deploys", ListOptions{Limit: 2, PinnedFirst: true})
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+			assert.Equal(t, "freeze in effect", items[0].Title)
+			assert.Equal(t, "deploy 3", items[1].Title)
+
+			removed, err := s.TrimFeeds(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), removed, "pinned items should be kept and not counted")
+
+			items, err = s.ListItems(ctx, "deploys", ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, items, 3)
+			assert.Equal(t, "freeze in effect", items[2].Title)
+
+			found, err := s.SetItemPinned(ctx, "other", created[0].ID, false)
+			require.NoError(t, err)
+			assert.False(t, found, "items should only be updated in their own feed")
+
+			found, err = s.SetItemPinned(ctx, "deploys", created[0].ID, false)
+			require.NoError(t, err)
+			assert.True(t, found)
+
+			removed, err = s.TrimFeeds(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), removed, "unpinned items should be trimmed")
+		})
+	}
+}
+
+func TestItemStateStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			base := time.Date(2022, 10, 12, 0, 0, 0, 0, time.UTC)
+			yes, no := true, false
+
+			created, err := s.InsertItems(ctx, "incidents", []NewItem{
+				{GUID: "a", Title: "incident 1", CreatedAt: base},
+				{GUID: "b", Title: "incident 2", CreatedAt: base.Add(time.Minute)},
+				{GUID: "c", Title: "incident 3", CreatedAt: base.Add(2 * time.Minute)},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			_, err = s.InsertItems(ctx, "deploys", []NewItem{{GUID: "a", Title: "deploy"}}, InsertOptions{})
+			require.NoError(t, err)
+
+			_, found, err := s.UpdateItemState(ctx, "alice", "deploys", created[0].ID, ItemStateUpdate{Read: &yes})
+			require.NoError(t, err)
+			assert.False(t, found, "states should only be set for items in the feed")
+
+			state, found, err := s.UpdateItemState(ctx, "alice", "incidents", created[0].ID, ItemStateUpdate{
+				Read:    &yes,
+				Starred: &yes,
+			})
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.True(t, state.Read)
+			assert.True(t, state.Starred)
+
+			state, _, err = s.UpdateItemState(ctx, "alice", "incidents", created[0].ID, ItemStateUpdate{Read: &no})
+			require.NoError(t, err)
+			assert.False(t, state.Read)
+			assert.True(t, state.Starred, "fields not in the update should be unchanged")
+
+			_, _, err = s.UpdateItemState(ctx, "alice", "incidents", created[1].ID, ItemStateUpdate{Read: &yes})
+			require.NoError(t, err)
+			_, _, err = s.UpdateItemState(ctx, "bob", "incidents", created[2].ID, ItemStateUpdate{Archived: &yes})
+			require.NoError(t, err)
+
+			states, err := s.ListItemStates(ctx, "alice", "incidents", []int64{created[0].ID, created[1].ID, created[2].ID})
+			require.NoError(t, err)
+			require.Len(t, states, 2)
+			assert.Equal(t, created[0].ID, states[0].ItemID)
+			assert.Equal(t, "alice", states[0].User)
+
+			items, err := s.ListItems(ctx, "incidents", ListOptions{UnreadBy: "alice"})
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+			assert.Equal(t, "incident 3", items[0].Title)
+			assert.Equal(t, "incident 1", items[1].Title)
+
+			items, err = s.ListItems(ctx, "incidents", ListOptions{UnreadBy: "bob"})
+			require.NoError(t, err)
+			assert.Len(t, items, 2, "archived items should be left out")
+
+			counts, err := s.UnreadCounts(ctx, "alice")
+			require.NoError(t, err)
+			assert.Equal(t, []UnreadCount{{Feed: "deploys", Count: 1}, {Feed: "incidents", Count: 2}}, counts)
+
+			removed, err := s.TrimFeeds(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), removed, "starred items should be kept and not counted")
+
+			items, err = s.ListItems(ctx, "incidents", ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+			assert.Equal(t, "incident 3", items[0].Title)
+			assert.Equal(t, "incident 1", items[1].Title)
+
+			states, err = s.ListItemStates(ctx, "alice", "incidents", []int64{created[1].ID})
+			require.NoError(t, err)
+			assert.Empty(t, states, "states should be removed with their items")
+
+			expired := time.Now().Add(-time.Minute)
+			acknowledged, err := s.InsertItems(ctx, "incidents", []NewItem{
+				{GUID: "d", Title: "acknowledged", ExpiresAt: &expired},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			_, _, err = s.UpdateItemState(ctx, "bob", "incidents", acknowledged[0].ID, ItemStateUpdate{Starred: &yes})
+			require.NoError(t, err)
+
+			deleted, err := s.DeleteExpiredItems(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), deleted, "starred items shouldn't be removed when they expire")
+		})
+	}
+}
+
+func TestPriority(t *testing.T) {
+	p, ok := ParsePriority("high")
+	assert.True(t, ok)
+	assert.Equal(t, PriorityHigh, p)
+
+	_, ok = ParsePriority("critical")
+	assert.False(t, ok)
+
+	assert.True(t, PriorityUrgent.AtLeast(PriorityHigh))
+	assert.True(t, PriorityHigh.AtLeast(PriorityHigh))
+	assert.False(t, PriorityNormal.AtLeast(PriorityHigh))
+}
+
+func TestSecretStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			hashes, err := s.ActiveSecretHashes(ctx, "example")
+			require.NoError(t, err)
+			assert.Empty(t, hashes)
+
+			secret, err := s.CreateSecret(ctx, "example", "reader", "hash1")
+			require.NoError(t, err)
+			assert.Equal(t, "reader", secret.Label)
+			assert.Nil(t, secret.RevokedAt)
+
+			_, err = s.CreateSecret(ctx, "example", "other", "hash2")
+			require.NoError(t, err)
+
+			hashes, err = s.ActiveSecretHashes(ctx, "example")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"hash1", "hash2"}, hashes)
+
+			found, err := s.RevokeSecret(ctx, "other", secret.ID)
+			require.NoError(t, err)
+			assert.False(t, found, "secrets should only be revoked for their own feed")
+
+			found, err = s.RevokeSecret(ctx, "example", secret.ID)
+			require.NoError(t, err)
+			assert.True(t, found)
+
+			found, err = s.RevokeSecret(ctx, "example", secret.ID)
+			require.NoError(t, err)
+			assert.False(t, found, "secrets can only be revoked once")
+
+			hashes, err = s.ActiveSecretHashes(ctx, "example")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"hash2"}, hashes)
+
+			secrets, err := s.ListSecrets(ctx, "example")
+			require.NoError(t, err)
+			require.Len(t, secrets, 2)
+			assert.NotNil(t, secrets[0].RevokedAt)
+		})
+	}
+}
+
+func TestSecretStoreFeedPrivate(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			private, err := s.FeedPrivate(ctx, "example")
+			require.NoError(t, err)
+			assert.False(t, private, "feeds are public by default")
+
+			secret, err := s.CreateSecret(ctx, "example", "reader", "hash1")
+			require.NoError(t, err)
+			_, err = s.CreateSecret(ctx, "example", "other", "hash2")
+			require.NoError(t, err, "issuing more secrets should leave the feed private")
+
+			private, err = s.FeedPrivate(ctx, "example")
+			require.NoError(t, err)
+			assert.True(t, private)
+
+			for _, id := range []int64{secret.ID, secret.ID + 1} {
+				_, err = s.RevokeSecret(ctx, "example", id)
+				require.NoError(t, err)
+			}
+			private, err = s.FeedPrivate(ctx, "example")
+			require.NoError(t, err)
+			assert.True(t, private, "feeds should stay private when their secrets are revoked")
+
+			require.NoError(t, s.SetFeedPrivate(ctx, "example", false))
+			private, err = s.FeedPrivate(ctx, "example")
+			require.NoError(t, err)
+			assert.False(t, private)
+
+			require.NoError(t, s.SetFeedPrivate(ctx, "other", true))
+			require.NoError(t, s.SetFeedPrivate(ctx, "other", true))
+			private, err = s.FeedPrivate(ctx, "other")
+			require.NoError(t, err)
+			assert.True(t, private)
+		})
+	}
+}
+
+func TestSubscriptionStoreStarredItems(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			sub, err := s.UpsertSubscription(ctx, Subscription{
+				Feed:         "example",
+				Topic:        "https://example.com/feeds/example.rss",
+				Callback:     "https://subscriber.example.com/expired",
+				LeaseSeconds: 60,
+				ExpiresAt:    time.Now().Add(-time.Minute),
+			})
+			require.NoError(t, err)
+
+			created, err := s.InsertItems(ctx, "example", []NewItem{{GUID: "a", Title: "starred"}}, InsertOptions{})
+			require.NoError(t, err)
+			require.Equal(t, sub.ID, created[0].ID, "the item should share the subscription's id")
+
+			starred := true
+			_, _, err = s.UpdateItemState(ctx, "alice", "example", created[0].ID, ItemStateUpdate{Starred: &starred})
+			require.NoError(t, err)
+
+			deleted, err := s.DeleteExpiredSubscriptions(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted, "starred items shouldn't keep subscriptions with the same id")
+		})
+	}
+}
+
+func TestSubscriptionStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			sub, err := s.UpsertSubscription(ctx, Subscription{
+				Feed:         "example",
+				Topic:        "https://example.com/feeds/example.rss",
+				Callback:     "https://subscriber.example.com/a",
+				LeaseSeconds: 60,
+				ExpiresAt:    time.Now().Add(time.Minute),
+			})
+			require.NoError(t, err)
+			assert.NotZero(t, sub.ID)
+
+			renewed, err := s.UpsertSubscription(ctx, Subscription{
+				Feed:         "example",
+				Topic:        "https://example.com/feeds/example.rss",
+				Callback:     "https://subscriber.example.com/a",
+				Secret:       "secret",
+				LeaseSeconds: 3600,
+				ExpiresAt:    time.Now().Add(time.Hour),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, sub.ID, renewed.ID, "subscriptions should be unique by topic and callback")
+			assert.Equal(t, "secret", renewed.Secret)
+			assert.Equal(t, int64(3600), renewed.LeaseSeconds)
+
+			_, err = s.UpsertSubscription(ctx, Subscription{
+				Feed:         "example",
+				Topic:        "https://example.com/feeds/example.rss",
+				Callback:     "https://subscriber.example.com/expired",
+				LeaseSeconds: 60,
+				ExpiresAt:    time.Now().Add(-time.Minute),
+			})
+			require.NoError(t, err)
+
+			subs, err := s.ListSubscriptions(ctx, "example")
+			require.NoError(t, err)
+			require.Len(t, subs, 1, "expired subscriptions should not be listed")
+			assert.Equal(t, "https://subscriber.example.com/a", subs[0].Callback)
+
+			expiring, err := s.ListExpiringSubscriptions(ctx, time.Now().Add(2*time.Hour))
+			require.NoError(t, err)
+			assert.Len(t, expiring, 1)
+
+			expiring, err = s.ListExpiringSubscriptions(ctx, time.Now().Add(time.Minute))
+			require.NoError(t, err)
+			assert.Len(t, expiring, 0)
+
+			deleted, err := s.DeleteExpiredSubscriptions(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+
+			err = s.DeleteSubscription(ctx, "https://example.com/feeds/example.rss", "https://subscriber.example.com/a")
+			require.NoError(t, err)
+
+			subs, err = s.ListSubscriptions(ctx, "example")
+			require.NoError(t, err)
+			assert.Empty(t, subs)
+		})
+	}
+}
+
+func TestItemStoreClaimPublishedItems(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			now := time.Now()
+			expired := now.Add(-time.Minute)
+
+			_, err := s.InsertItems(ctx, "example", []NewItem{
+				{GUID: "a", Title: "published"},
+				{GUID: "b", Title: "sooner", CreatedAt: now.Add(time.Hour)},
+				{GUID: "c", Title: "later", CreatedAt: now.Add(2 * time.Hour)},
+				{GUID: "d", Title: "backdated", CreatedAt: now.Add(-time.Hour)},
+				{GUID: "e", Title: "expired", ExpiresAt: &expired},
+			}, InsertOptions{})
+			require.NoError(t, err)
+
+			items, err := s.ListItemsAfter(ctx, "example", 0, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 2, "scheduled and expired items should be left out")
+			assert.Equal(t, "published", items[0].Title)
+			assert.Equal(t, "backdated", items[1].Title)
+
+			claimed, err := s.ClaimPublishedItems(ctx, now)
+			require.NoError(t, err)
+			assert.Empty(t, claimed, "items which weren't scheduled should never be claimed")
+
+			claimed, err = s.ClaimPublishedItems(ctx, now.Add(90*time.Minute))
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			assert.Equal(t, "sooner", claimed[0].Title)
+
+			claimed, err = s.ClaimPublishedItems(ctx, now.Add(3*time.Hour))
+			require.NoError(t, err)
+			require.Len(t, claimed, 1, "items should only be claimed once")
+			assert.Equal(t, "later", claimed[0].Title)
+		})
+	}
+}
+
+func TestItemStoreListItemsAfter(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			id, err := s.LatestItemID(ctx, "example")
+			require.NoError(t, err)
+			assert.Zero(t, id)
+
+			created, err := s.InsertItems(ctx, "example", []NewItem{
+				{GUID: "a", Title: "first"},
+				{GUID: "b", Title: "second", CreatedAt: time.Now().Add(time.Hour)},
+				{GUID: "c", Title: "third", CreatedAt: time.Now().Add(-time.Hour)},
+			}, InsertOptions{})
+			require.NoError(t, err)
+
+			_, err = s.InsertItems(ctx, "other", []NewItem{{GUID: "d", Title: "other"}}, InsertOptions{})
+			require.NoError(t, err)
+
+			id, err = s.LatestItemID(ctx, "example")
+			require.NoError(t, err)
+			assert.Equal(t, created[2].ID, id)
+
+			items, err := s.ListItemsAfter(ctx, "example", created[0].ID, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 1, "scheduled items should be left out")
+			assert.Equal(t, "third", items[0].Title)
+
+			items, err = s.ListAllItemsAfter(ctx, "example", created[0].ID, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 2, "all items should be listed by id, regardless of date")
+			assert.Equal(t, "second", items[0].Title)
+			assert.Equal(t, "third", items[1].Title)
+
+			items, err = s.ListItemsAfter(ctx, "example", 0, 1)
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+			assert.Equal(t, "first", items[0].Title)
+		})
+	}
+}
+
+func TestItemStoreExistingGUIDs(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := s.InsertItems(ctx, "example", []NewItem{
+				{GUID: "a", Title: "first"},
+				{GUID: "b", Title: "second"},
+			}, InsertOptions{})
+			require.NoError(t, err)
+
+			_, err = s.InsertItems(ctx, "other", []NewItem{{GUID: "c", Title: "other"}}, InsertOptions{})
+			require.NoError(t, err)
+
+			existing, err := s.ExistingGUIDs(ctx, "example", []string{"b", "c", "d"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b"}, existing, "guids should only match items in the same feed")
+
+			existing, err = s.ExistingGUIDs(ctx, "example", nil)
+			require.NoError(t, err)
+			assert.Empty(t, existing)
+		})
+	}
+}
+
+func TestItemStoreTags(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			created, err := s.InsertItems(ctx, "example", []NewItem{
+				{GUID: "a", Title: "tagged", Tags: Tags{"critical", "backups"}},
+				{GUID: "b", Title: "untagged"},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, Tags{"critical", "backups"}, created[0].Tags)
+			assert.Empty(t, created[1].Tags)
+
+			items, err := s.ListItemsAfter(ctx, "example", 0, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 2)
+			assert.Equal(t, Tags{"critical", "backups"}, items[0].Tags)
+			assert.True(t, items[0].Tags.Has("backups"))
+			assert.False(t, items[1].Tags.Has("backups"))
+		})
+	}
+}
+
+func TestOutboxStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			err := s.EnqueueDeliveries(ctx, []NewDelivery{
+				{Target: "slack", Feed: "example", ItemID: 1, ContentType: "application/json", Body: `{"text":"a"}`},
+				{Target: "slack", Feed: "example", ItemID: 2, ContentType: "application/json", Body: `{"text":"b"}`},
+			})
+			require.NoError(t, err)
+
+			claimed, err := s.ClaimDeliveries(ctx, 1, time.Minute)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			assert.Equal(t, int64(1), claimed[0].ItemID)
+			assert.Equal(t, 1, claimed[0].Attempts)
+			assert.Equal(t, `{"text":"a"}`, claimed[0].Body)
+
+			claimed, err = s.ClaimDeliveries(ctx, 10, time.Minute)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1, "claimed deliveries should not be claimed again")
+			assert.Equal(t, int64(2), claimed[0].ItemID)
+
+			first, second := claimed[0].ID-1, claimed[0].ID
+
+			require.NoError(t, s.CompleteDelivery(ctx, first))
+			require.NoError(t, s.RetryDelivery(ctx, second, "timeout", time.Now().Add(-time.Second)))
+
+			claimed, err = s.ClaimDeliveries(ctx, 10, time.Minute)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1, "retried deliveries should be claimed once due")
+			assert.Equal(t, second, claimed[0].ID)
+			assert.Equal(t, 2, claimed[0].Attempts)
+
+			require.NoError(t, s.DeadLetterDelivery(ctx, second, "gave up"))
+
+			dead, err := s.ListDeliveries(ctx, DeliveryDead, 10)
+			require.NoError(t, err)
+			require.Len(t, dead, 1)
+			assert.Equal(t, "gave up", dead[0].LastError)
+
+			delivered, err := s.ListDeliveries(ctx, DeliveryDelivered, 10)
+			require.NoError(t, err)
+			require.Len(t, delivered, 1)
+
+			found, err := s.RequeueDelivery(ctx, first)
+			require.NoError(t, err)
+			assert.False(t, found, "only dead deliveries can be requeued")
+
+			found, err = s.RequeueDelivery(ctx, second)
+			require.NoError(t, err)
+			assert.True(t, found)
+
+			claimed, err = s.ClaimDeliveries(ctx, 10, time.Minute)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			assert.Equal(t, 1, claimed[0].Attempts, "requeued deliveries should start again")
+
+			pruned, err := s.PruneDeliveries(ctx, time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), pruned)
+		})
+	}
+}
+
+func TestPollStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, found, err := s.GetPollState(ctx, "source")
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			now := time.Now()
+			err = s.SavePollState(ctx, PollState{Source: "source", ETag: `"v1"`, NextPollAt: now.Add(time.Minute)})
+			require.NoError(t, err)
+
+			err = s.SavePollState(ctx, PollState{
+				Source:       "source",
+				ETag:         `"v2"`,
+				Failures:     2,
+				LastError:    "timeout",
+				LastPolledAt: &now,
+				NextPollAt:   now.Add(time.Hour),
+			})
+			require.NoError(t, err)
+
+			state, found, err := s.GetPollState(ctx, "source")
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, `"v2"`, state.ETag)
+			assert.Equal(t, 2, state.Failures)
+			assert.Equal(t, "timeout", state.LastError)
+			require.NotNil(t, state.LastPolledAt)
+			assert.WithinDuration(t, now.Add(time.Hour), state.NextPollAt, time.Second)
+
+			err = s.MarkEntriesSeen(ctx, "source", []string{"a", "b"}, now.Add(-time.Hour))
+			require.NoError(t, err)
+			err = s.MarkEntriesSeen(ctx, "source", []string{"b"}, now)
+			require.NoError(t, err)
+
+			unseen, err := s.UnseenEntries(ctx, "source", []string{"a", "b", "c"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"c"}, unseen)
+
+			unseen, err = s.UnseenEntries(ctx, "other", []string{"a"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a"}, unseen, "entries are seen per source")
+
+			err = s.PruneSeenEntries(ctx, now.Add(-time.Minute))
+			require.NoError(t, err)
+
+			unseen, err = s.UnseenEntries(ctx, "source", []string{"a", "b"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a"}, unseen)
+
+			err = s.TouchSeenEntries(ctx, "source", now.Add(time.Hour))
+			require.NoError(t, err)
+			err = s.PruneSeenEntries(ctx, now.Add(time.Minute))
+			require.NoError(t, err)
+
+			unseen, err = s.UnseenEntries(ctx, "source", []string{"b"})
+			require.NoError(t, err)
+			assert.Empty(t, unseen, "touched entries should not be pruned")
+		})
+	}
+}
+
+func TestAttachmentStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			created, err := s.InsertItems(ctx, "example", []NewItem{
+				{GUID: "a", Title: "with attachments"},
+				{GUID: "b", Title: "trimmed"},
+			}, InsertOptions{})
+			require.NoError(t, err)
+
+			added, err := s.AddAttachments(ctx, "example", created[0].ID, []NewAttachment{
+				{Filename: "notes.txt", ContentType: "text/plain", Data: []byte("hello")},
+				{Filename: "image.png", ContentType: "image/png", Data: []byte{0x89, 0x50, 0x4e, 0x47}},
+			})
+			require.NoError(t, err)
+			require.Len(t, added, 2)
+			assert.Equal(t, "notes.txt", added[0].Filename)
+			assert.Equal(t, int64(5), added[0].Size)
+			assert.Nil(t, added[0].Data)
+
+			_, err = s.AddAttachments(ctx, "example", created[1].ID, []NewAttachment{
+				{Filename: "other.txt", ContentType: "text/plain", Data: []byte("other")},
+			})
+			require.NoError(t, err)
+
+			attachment, found, err := s.GetAttachment(ctx, "example", created[0].ID, added[1].ID)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, "image/png", attachment.ContentType)
+			assert.Equal(t, []byte{0x89, 0x50, 0x4e, 0x47}, attachment.Data)
+
+			_, found, err = s.GetAttachment(ctx, "other", created[0].ID, added[1].ID)
+			require.NoError(t, err)
+			assert.False(t, found, "attachments should only be found in their feed")
+
+			attachments, err := s.ListAttachments(ctx, "example", []int64{created[0].ID, created[1].ID})
+			require.NoError(t, err)
+			require.Len(t, attachments, 3)
+			assert.Equal(t, "other.txt", attachments[2].Filename)
+			assert.Nil(t, attachments[0].Data)
+
+			_, err = s.DeleteItems(ctx, "example", []int64{created[1].ID})
+			require.NoError(t, err)
+
+			attachments, err = s.ListAttachments(ctx, "example", []int64{created[0].ID, created[1].ID})
+			require.NoError(t, err)
+			assert.Len(t, attachments, 2, "attachments should be removed with their item")
+
+			_, err = s.TrimFeeds(ctx, 0)
+			require.NoError(t, err)
+
+			attachments, err = s.ListAttachments(ctx, "example", []int64{created[0].ID})
+			require.NoError(t, err)
+			assert.Empty(t, attachments, "attachments should be removed with trimmed items")
+
+			item, err := s.InsertItemWithAttachments(ctx, "mail", NewItem{GUID: "message", Title: "report"}, []NewAttachment{
+				{Filename: "report.csv", ContentType: "text/csv", Data: []byte("a,b")},
+			}, InsertOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "report", item.Title)
+
+			attachments, err = s.ListAttachments(ctx, "mail", []int64{item.ID})
+			require.NoError(t, err)
+			require.Len(t, attachments, 1)
+			assert.Equal(t, "report.csv", attachments[0].Filename)
+
+			// neither the item nor its attachments are stored when the item can't be inserted
+			_, err = s.InsertItemWithAttachments(ctx, "mail", NewItem{GUID: "message", Title: "again"}, []NewAttachment{
+				{Filename: "again.csv", ContentType: "text/csv", Data: []byte("c,d")},
+			}, InsertOptions{})
+			require.Error(t, err)
+
+			items, err := s.ListItems(ctx, "mail", ListOptions{})
+			require.NoError(t, err)
+			assert.Len(t, items, 1)
+			attachments, err = s.ListAttachments(ctx, "mail", []int64{item.ID, item.ID + 1})
+			require.NoError(t, err)
+			assert.Len(t, attachments, 1)
+		})
+	}
+}
+
+func TestDigestStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, found, err := s.GetDigestState(ctx, "daily")
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			now := time.Now()
+			err = s.SaveDigestState(ctx, DigestState{Name: "daily", LastItemID: 3, LastDigestAt: now.Add(-time.Hour)})
+			require.NoError(t, err)
+			err = s.SaveDigestState(ctx, DigestState{
+				Name:         "daily",
+				LastItemID:   7,
+				IncludedIDs:  ItemIDs{9, 10},
+				LastDigestAt: now,
+			})
+			require.NoError(t, err)
+
+			state, found, err := s.GetDigestState(ctx, "daily")
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, int64(7), state.LastItemID)
+			assert.Equal(t, ItemIDs{9, 10}, state.IncludedIDs)
+			assert.WithinDuration(t, now, state.LastDigestAt, time.Second)
+
+			item, err := s.InsertDigestItem(ctx, "digest", NewItem{GUID: "digest-1", Title: "first"}, DigestState{
+				Name:         "daily",
+				LastItemID:   12,
+				LastDigestAt: now,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "digest-1", item.GUID)
+			assert.NotZero(t, item.ID)
+
+			state, _, err = s.GetDigestState(ctx, "daily")
+			require.NoError(t, err)
+			assert.Equal(t, int64(12), state.LastItemID)
+			assert.Empty(t, state.IncludedIDs)
+
+			// the state isn't saved when the digest can't be inserted
+			_, err = s.InsertDigestItem(ctx, "digest", NewItem{GUID: "digest-1", Title: "again"}, DigestState{
+				Name:         "daily",
+				LastItemID:   15,
+				LastDigestAt: now,
+			})
+			require.Error(t, err)
+
+			state, _, err = s.GetDigestState(ctx, "daily")
+			require.NoError(t, err)
+			assert.Equal(t, int64(12), state.LastItemID)
+
+			items, err := s.ListItems(ctx, "digest", ListOptions{})
+			require.NoError(t, err)
+			assert.Len(t, items, 1)
+		})
+	}
+}
+
+func TestJobRunStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			runs, err := s.ListJobRuns(ctx, "clean", 0)
+			require.NoError(t, err)
+			assert.Empty(t, runs)
+
+			now := time.Now()
+			for i := 3; i > 0; i-- {
+				started := now.Add(-time.Duration(i) * time.Hour)
+				_, err := s.RecordJobRun(ctx, JobRun{
+					Job:        "clean",
+					StartedAt:  started,
+					FinishedAt: started.Add(time.Second),
+					Outcome:    JobRunSuccess,
+					Stats:      JobStats{"items_deleted": int64(i)},
+				})
+				require.NoError(t, err)
+			}
+
+			failed, err := s.RecordJobRun(ctx, JobRun{
+				Job:        "feed-check",
+				StartedAt:  now,
+				FinishedAt: now,
+				Outcome:    JobRunFailure,
+				Error:      "failed to send alert",
+			})
+			require.NoError(t, err)
+			assert.NotZero(t, failed.ID)
+
+			runs, err = s.ListJobRuns(ctx, "clean", 2)
+			require.NoError(t, err)
+			require.Len(t, runs, 2)
+			assert.Equal(t, int64(1), runs[0].Stats["items_deleted"], "newest runs should be listed first")
+			assert.WithinDuration(t, now.Add(-time.Hour), runs[0].StartedAt, time.Second)
+			assert.Equal(t, int64(2), runs[1].Stats["items_deleted"])
+
+			runs, err = s.ListJobRuns(ctx, "feed-check", 0)
+			require.NoError(t, err)
+			require.Len(t, runs, 1)
+			assert.Equal(t, failed.ID, runs[0].ID)
+			assert.Equal(t, JobRunFailure, runs[0].Outcome)
+			assert.Equal(t, "failed to send alert", runs[0].Error)
+			assert.Equal(t, JobStats{}, runs[0].Stats)
+
+			pruned, err := s.PruneJobRuns(ctx, now.Add(-90*time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), pruned)
+
+			runs, err = s.ListJobRuns(ctx, "clean", 0)
+			require.NoError(t, err)
+			assert.Len(t, runs, 1)
+		})
+	}
+}
+
+func TestHeartbeatStateStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, found, err := s.GetHeartbeatState(ctx, "backups")
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			now := time.Now()
+			err = s.SaveHeartbeatState(ctx, HeartbeatState{Name: "backups", Status: HeartbeatUp, ChangedAt: now.Add(-time.Hour)})
+			require.NoError(t, err)
+			err = s.SaveHeartbeatState(ctx, HeartbeatState{Name: "backups", Status: HeartbeatDown, ChangedAt: now})
+			require.NoError(t, err)
+
+			state, found, err := s.GetHeartbeatState(ctx, "backups")
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, HeartbeatDown, state.Status)
+			assert.WithinDuration(t, now, state.ChangedAt, time.Second)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 10))
+	assert.Equal(t, "trunc", Truncate("truncated", 5))
+	assert.Equal(t, "a", Truncate("a✓", 3), "characters shouldn't be split")
+}
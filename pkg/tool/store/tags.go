@@ -0,0 +1,47 @@
+package store
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Tags are labels attached to an item. They're stored as a comma separated list, so tags must not contain commas.
+type Tags []string
+
+// Has returns true when any of the given tags is present
+func (t Tags) Has(tags ...string) bool {
+	for _, a := range t {
+		for _, b := range tags {
+			if a == b {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (t Tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func (t *Tags) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("unsupported type for tags: %T", src)
+	}
+
+	*t = Tags{}
+	if s != "" {
+		*t = strings.Split(s, ",")
+	}
+
+	return nil
+}
@@ -1,16 +1,28 @@
 package tool
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
+	"log"
+	"net"
 
 	"github.com/Jeffail/gabs/v2"
 	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/emersion/go-smtp"
 	"github.com/gorilla/mux"
 
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/archive"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/digest"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/events"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/fanout"
 	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/handlers"
 	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/metrics"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/poll"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/websub"
 )
 
 //go:embed migrations
@@ -21,6 +33,17 @@ var webhookRSSToolMigrations embed.FS
 type WebhookRSS struct {
 	config *gabs.Container
 	db     *sql.DB
+	store  store.Store
+
+	// these are created on first use, as they're shared by handlers and jobs
+	hub            *websub.Hub
+	broadcaster    *events.Broadcaster
+	postgresEvents bool
+	hooks          []func(ctx context.Context, feed string, items []store.Item)
+	publishedHooks []func(ctx context.Context, feed string, items []store.Item)
+	mailServer     *smtp.Server
+	metrics        *metrics.Metrics
+	tracker        *jobs.Tracker
 }
 
 func (d *WebhookRSS) Name() string {
@@ -29,9 +52,10 @@ func (d *WebhookRSS) Name() string {
 
 func (d *WebhookRSS) FeatureSet() apis.FeatureSet {
 	return apis.FeatureSet{
-		Config:   true,
-		HTTP:     true,
-		Database: true,
+		Config: true,
+		HTTP:   true,
+		// the toolbelt database is only needed when using the postgres storage backend
+		Database: d.storageBackend() == "postgres",
 		Jobs:     true,
 	}
 }
@@ -44,6 +68,28 @@ func (d *WebhookRSS) HTTPHost() string { return "" }
 func (d *WebhookRSS) SetConfig(config map[string]any) error {
 	d.config = gabs.Wrap(config)
 
+	switch backend := d.storageBackend(); backend {
+	case "postgres":
+		// the store is created when the toolbelt sets the database
+	case "sqlite":
+		path, err := d.optionalString("storage.sqlite.path", "")
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("missing required config path: storage.sqlite.path")
+		}
+
+		d.store, err = store.OpenSQLite(path)
+		if err != nil {
+			return fmt.Errorf("failed to open sqlite store: %w", err)
+		}
+	case "memory":
+		d.store = store.NewMemory()
+	default:
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
+
 	return nil
 }
 
@@ -53,30 +99,352 @@ func (d *WebhookRSS) DatabaseMigrations() (*embed.FS, string, error) {
 
 func (d *WebhookRSS) DatabaseSet(db *sql.DB) {
 	d.db = db
+	d.store = store.NewPostgres(db)
 }
 
 func (d *WebhookRSS) HTTPAttach(router *mux.Router) error {
-	if d.db == nil {
-		return fmt.Errorf("database not set")
+	if d.store == nil {
+		return fmt.Errorf("storage not set")
+	}
+
+	feedLocations, err := d.feedLocations()
+	if err != nil {
+		return fmt.Errorf("failed to load feed config: %w", err)
+	}
+
+	maxRequestBytes, err := d.optionalInt("limits.max_request_bytes", 5*1024*1024)
+	if err != nil {
+		return err
+	}
+	maxItemsPerRequest, err := d.optionalInt("limits.max_items_per_request", 1000)
+	if err != nil {
+		return err
+	}
+	maxFeedBytesPerDay, err := d.optionalInt("limits.max_feed_bytes_per_day", 0)
+	if err != nil {
+		return err
+	}
+
+	rateLimitOptions, err := d.rateLimitOptions()
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit config: %w", err)
+	}
+
+	baseURL, err := d.baseURL()
+	if err != nil {
+		return err
+	}
+
+	hub, err := d.webSubHub()
+	if err != nil {
+		return fmt.Errorf("failed to load websub config: %w", err)
+	}
+
+	itemCreateOptions := handlers.ItemCreateOptions{
+		FeedLocations:      feedLocations,
+		MaxRequestBytes:    maxRequestBytes,
+		MaxItemsPerRequest: int(maxItemsPerRequest),
+		MaxFeedBytesPerDay: maxFeedBytesPerDay,
+	}
+	users, err := d.users()
+	if err != nil {
+		return fmt.Errorf("failed to load users config: %w", err)
+	}
+
+	feedGetOptions := handlers.FeedGetOptions{BaseURL: baseURL, Users: users}
+
+	broadcaster, err := d.eventBroadcaster()
+	if err != nil {
+		return fmt.Errorf("failed to load events config: %w", err)
+	}
+
+	itemCreateOptions.OnCreated, err = d.itemCreatedHooks()
+	if err != nil {
+		return err
+	}
+
+	toolMetrics := d.toolMetrics()
+	itemCreateOptions.OnRejected = toolMetrics.RequestRejected
+	rateLimitOptions.OnRejected = toolMetrics.RequestRejected
+
+	metricsEnabled, err := d.optionalBool("metrics.enabled", true)
+	if err != nil {
+		return err
+	}
+	if metricsEnabled {
+		metricsToken, err := d.optionalString("metrics.token", "")
+		if err != nil {
+			return err
+		}
+
+		router.Use(toolMetrics.Middleware)
+
+		// handler for prometheus to scrape, it's public unless a token is set
+		metricsHandler := toolMetrics.Handler().ServeHTTP
+		if metricsToken != "" {
+			metricsHandler = handlers.WithBearerToken(metricsToken, metricsHandler)
+		}
+		router.HandleFunc("/metrics", metricsHandler).Methods("GET")
+	}
+
+	if hub != nil {
+		feedGetOptions.HubURL = hub.HubURL
+
+		// handler for websub subscription requests
+		router.HandleFunc(
+			"/hub",
+			handlers.BuildHubHandler(hub, d.store, handlers.HubOptions{BaseURL: baseURL}),
+		).Methods("POST")
 	}
 
 	// handler for the creation of new items in feeds
 	router.HandleFunc(
 		"/feeds/{feed}/items",
-		handlers.BuildItemCreateHandler(d.db),
+		handlers.WithRateLimits(
+			rateLimitOptions,
+			handlers.BuildItemCreateHandler(d.store, itemCreateOptions),
+		),
 	).Methods("POST")
 
+	feedInfo, err := d.feedInfo()
+	if err != nil {
+		return fmt.Errorf("failed to load feed config: %w", err)
+	}
+	feedIndexOptions := handlers.FeedIndexOptions{BaseURL: baseURL, Feeds: feedInfo}
+
+	// handlers listing the public feeds for people and for feed readers
+	router.HandleFunc(
+		"/",
+		handlers.BuildIndexHandler(d.store, d.store, feedIndexOptions),
+	).Methods("GET")
+	router.HandleFunc(
+		"/feeds.opml",
+		handlers.BuildOPMLHandler(d.store, d.store, feedIndexOptions),
+	).Methods("GET")
+
 	// handler used to serve rss clients
 	router.HandleFunc(
 		"/feeds/{feed}.rss",
-		handlers.BuildFeedGetHandler(d.db),
+		toolMetrics.InstrumentFeedGet("rss", handlers.BuildFeedGetHandler(d.store, d.store, feedGetOptions)),
+	).Methods("GET")
+	// private feeds can also be read with the secret in the path, for readers which strip query strings
+	router.HandleFunc(
+		"/feeds/{feed}/key/{key}.rss",
+		toolMetrics.InstrumentFeedGet("rss", handlers.BuildFeedGetHandler(d.store, d.store, feedGetOptions)),
+	).Methods("GET")
+
+	// handler rendering feeds as HTML for people without a feed reader, it's registered after the rss handlers as
+	// its path would also match them
+	router.HandleFunc(
+		"/feeds/{feed}",
+		toolMetrics.InstrumentFeedGet(
+			"html",
+			handlers.BuildFeedViewHandler(d.store, d.store, handlers.FeedViewOptions{
+				BaseURL: baseURL,
+				Feeds:   feedInfo,
+				Users:   users,
+			}),
+		),
+	).Methods("GET")
+
+	// handler serving files attached to items, e.g. from email
+	router.HandleFunc(
+		"/feeds/{feed}/items/{item}/attachments/{attachment}",
+		handlers.BuildAttachmentGetHandler(d.store, d.store),
 	).Methods("GET")
 
+	// handler streaming new items to clients as server-sent events
+	router.HandleFunc(
+		"/feeds/{feed}/events",
+		toolMetrics.InstrumentFeedGet(
+			"events",
+			handlers.BuildFeedEventsHandler(d.store, d.store, broadcaster, handlers.FeedEventsOptions{}),
+		),
+	).Methods("GET")
+
+	// handlers for probes and uptime monitors
+	router.HandleFunc("/healthz", handlers.BuildHealthzHandler()).Methods("GET")
+	router.HandleFunc("/readyz", handlers.BuildReadyzHandler(d.readinessChecks())).Methods("GET")
+
+	// stale feeds are only reported when the feed-check job is configured, deployments which only serve requests
+	// may leave out the jobs config
+	var feedCheck *jobs.FeedCheck
+	if d.config.ExistsP("jobs.feed-check") {
+		feedCheck, err = d.feedCheckJob()
+		if err != nil {
+			return err
+		}
+	}
+	statusToken, err := d.optionalString("status.token", "")
+	if err != nil {
+		return err
+	}
+	heartbeats, err := d.heartbeats()
+	if err != nil {
+		return fmt.Errorf("failed to load heartbeat config: %w", err)
+	}
+	heartbeatMonitor, err := d.heartbeatMonitor()
+	if err != nil {
+		return fmt.Errorf("failed to load heartbeat config: %w", err)
+	}
+	// handlers for cron jobs and scheduled tasks to report their runs to heartbeats
+	pingHandler := handlers.BuildPingHandler(d.store, handlers.PingOptions{
+		Heartbeats:         heartbeats,
+		Monitor:            heartbeatMonitor,
+		MaxFeedBytesPerDay: maxFeedBytesPerDay,
+		RateLimits:         rateLimitOptions,
+		OnCreated:          itemCreateOptions.OnCreated,
+		OnRejected:         toolMetrics.RequestRejected,
+	})
+	router.HandleFunc("/ping/{check}", pingHandler).Methods("GET", "POST")
+	router.HandleFunc("/ping/{check}/{signal:start|fail}", pingHandler).Methods("GET", "POST")
+
+	statusHandler := handlers.BuildStatusHandler(d.store, handlers.StatusOptions{
+		Jobs:       d.jobTracker().Statuses,
+		FeedCheck:  feedCheck,
+		Heartbeats: heartbeats,
+	})
+	if statusToken != "" {
+		statusHandler = handlers.WithBearerToken(statusToken, statusHandler)
+	}
+	router.HandleFunc("/status", statusHandler).Methods("GET")
+
+	err = d.startMailServer(store.InsertOptions{MaxFeedBytesPerDay: maxFeedBytesPerDay})
+	if err != nil {
+		return fmt.Errorf("failed to start smtp server: %w", err)
+	}
+
+	// the user API keeps each user's read, starred and archived state for items
+	if len(users) > 0 {
+		router.HandleFunc(
+			"/api/v1/unread",
+			handlers.WithUser(users, handlers.BuildUnreadCountsHandler(d.store, d.store)),
+		).Methods("GET")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/items",
+			handlers.WithUser(users, handlers.BuildUserItemsHandler(d.store, d.store)),
+		).Methods("GET")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/items/{id}/state",
+			handlers.WithUser(users, handlers.BuildItemStateHandler(d.store, d.store)),
+		).Methods("PUT")
+	}
+
+	adminToken, err := d.optionalString("admin.token", "")
+	if err != nil {
+		return err
+	}
+
+	// the admin API is only available when a token has been configured
+	if adminToken != "" {
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/secrets",
+			handlers.WithBearerToken(adminToken, handlers.BuildSecretCreateHandler(d.store)),
+		).Methods("POST")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/secrets",
+			handlers.WithBearerToken(adminToken, handlers.BuildSecretListHandler(d.store)),
+		).Methods("GET")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/secrets/{id}",
+			handlers.WithBearerToken(adminToken, handlers.BuildSecretRevokeHandler(d.store)),
+		).Methods("DELETE")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/private",
+			handlers.WithBearerToken(adminToken, handlers.BuildFeedPrivateHandler(d.store, true)),
+		).Methods("PUT")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/private",
+			handlers.WithBearerToken(adminToken, handlers.BuildFeedPrivateHandler(d.store, false)),
+		).Methods("DELETE")
+
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/scheduled",
+			handlers.WithBearerToken(adminToken, handlers.BuildScheduledListHandler(d.store)),
+		).Methods("GET")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/scheduled/{id}",
+			handlers.WithBearerToken(adminToken, handlers.BuildScheduledCancelHandler(d.store)),
+		).Methods("DELETE")
+
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/items/{id}/pin",
+			handlers.WithBearerToken(adminToken, handlers.BuildItemPinHandler(d.store, true)),
+		).Methods("PUT")
+		router.HandleFunc(
+			"/api/v1/feeds/{feed}/items/{id}/pin",
+			handlers.WithBearerToken(adminToken, handlers.BuildItemPinHandler(d.store, false)),
+		).Methods("DELETE")
+
+		router.HandleFunc(
+			"/api/v1/jobs",
+			handlers.WithBearerToken(adminToken, handlers.BuildJobRunsHandler(d.store, d.jobTracker().Statuses)),
+		).Methods("GET")
+
+		router.HandleFunc(
+			"/api/v1/outbox",
+			handlers.WithBearerToken(adminToken, handlers.BuildOutboxListHandler(d.store)),
+		).Methods("GET")
+		router.HandleFunc(
+			"/api/v1/outbox/{id}/retry",
+			handlers.WithBearerToken(adminToken, handlers.BuildOutboxRetryHandler(d.store)),
+		).Methods("POST")
+
+		maxImportBytes, err := d.optionalInt("limits.max_import_bytes", 100*1024*1024)
+		if err != nil {
+			return err
+		}
+
+		router.HandleFunc(
+			"/api/v1/export",
+			handlers.WithBearerToken(adminToken, archive.BuildExportHandler(d.store)),
+		).Methods("GET")
+		router.HandleFunc(
+			"/api/v1/import",
+			handlers.WithBearerToken(adminToken, archive.BuildImportHandler(d.store, maxImportBytes)),
+		).Methods("POST")
+	}
+
+	return nil
+}
+
+// startMailServer starts the SMTP server accepting items by email when it's enabled, it runs alongside the HTTP
+// handlers
+func (d *WebhookRSS) startMailServer(opts store.InsertOptions) error {
+	if d.mailServer != nil {
+		return nil
+	}
+
+	server, err := d.newMailServer(opts)
+	if err != nil || server == nil {
+		return err
+	}
+
+	// the listener is opened here so that configuration errors, such as the address being in use, are returned
+	l, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := server.Serve(l)
+		if err != nil {
+			log.Printf("smtp server stopped: %s", err)
+		}
+	}()
+
+	d.mailServer = server
+
 	return nil
 }
 
 func (d *WebhookRSS) Jobs() ([]apis.Job, error) {
 	var j []apis.Job
+
+	if d.store == nil {
+		return j, fmt.Errorf("storage not set")
+	}
+
 	var path string
 	var ok bool
 
@@ -98,15 +466,13 @@ func (d *WebhookRSS) Jobs() ([]apis.Job, error) {
 	if !ok {
 		return j, fmt.Errorf("missing required config path: %s", path)
 	}
-	path = "jobs.deadman-check.pushover_token"
-	deadmanCheckPushoverToken, ok := d.config.Path(path).Data().(string)
-	if !ok {
-		return j, fmt.Errorf("missing required config path: %s", path)
+	heartbeats, err := d.heartbeatsWithNotifiers()
+	if err != nil {
+		return j, fmt.Errorf("failed to load heartbeat config: %w", err)
 	}
-	path = "jobs.deadman-check.pushover_app"
-	deadmanCheckPushoverApp, ok := d.config.Path(path).Data().(string)
-	if !ok {
-		return j, fmt.Errorf("missing required config path: %s", path)
+	heartbeatMonitor, err := d.heartbeatMonitor()
+	if err != nil {
+		return j, fmt.Errorf("failed to load heartbeat config: %w", err)
 	}
 
 	// load clean config
@@ -128,50 +494,198 @@ func (d *WebhookRSS) Jobs() ([]apis.Job, error) {
 		return j, fmt.Errorf("missing required config path: %s", path)
 	}
 
+	jobRunRetention, err := d.jobRunRetention()
+	if err != nil {
+		return j, err
+	}
+	jobPoster, err := d.jobPoster()
+	if err != nil {
+		return j, err
+	}
+
 	// load feed check config
-	path = "jobs.feed-check.schedule"
-	feedCheckSchedule, ok := d.config.Path(path).Data().(string)
-	if !ok {
-		return j, fmt.Errorf("missing required config path: %s", path)
+	feedCheck, err := d.feedCheckJob()
+	if err != nil {
+		return j, err
 	}
-	path = "jobs.feed-check.endpoint"
-	feedCheckEndpoint, ok := d.config.Path(path).Data().(string)
-	if !ok {
-		return j, fmt.Errorf("missing required config path: %s", path)
+
+	hub, err := d.webSubHub()
+	if err != nil {
+		return j, fmt.Errorf("failed to load websub config: %w", err)
 	}
-	path = "jobs.feed-check.feeds"
-	feedCheckData, ok := d.config.Path(path).Data().([]interface{})
-	if !ok {
-		return j, fmt.Errorf("missing required config path: %s", path)
+
+	// the max ages are exported so that feed staleness can be graphed against them
+	feedMaxAges, err := feedCheck.MaxAges()
+	if err != nil {
+		return j, fmt.Errorf("failed to load feed check config: %w", err)
+	}
+	for feed, maxAge := range feedMaxAges {
+		d.toolMetrics().SetFeedMaxAge(feed, maxAge)
 	}
 
-	return []apis.Job{
+	j = []apis.Job{
 		&jobs.DeadMan{
 			Endpoint:         deadmanEndpoint,
 			ScheduleOverride: deadmanSchedule,
+			Poster:           jobPoster,
 		},
 		&jobs.DeadmanCheck{
-			DB:               d.db,
+			Items:            d.store,
 			ScheduleOverride: deadmanCheckSchedule,
-			PushoverApp:      deadmanCheckPushoverApp,
-			PushoverToken:    deadmanCheckPushoverToken,
+			Heartbeats:       heartbeats,
+			Monitor:          heartbeatMonitor,
 		},
 		&jobs.Clean{
+			Items:            d.store,
 			DB:               d.db,
 			ScheduleOverride: cleanSchedule,
+			JobRuns:          d.store,
+			JobRunRetention:  jobRunRetention,
 		},
 		&jobs.CleanCheck{
-			DB:               d.db,
+			Items:            d.store,
 			ScheduleOverride: cleanCheckSchedule,
 			Endpoint:         cleanCheckEndpoint,
+			Poster:           jobPoster,
 		},
-		&jobs.FeedCheck{
-			DB:               d.db,
-			ScheduleOverride: feedCheckSchedule,
-			Endpoint:         feedCheckEndpoint,
-			Feeds:            feedCheckData,
-		},
+		feedCheck,
+	}
+
+	// scheduled items are announced to event streams, fanout targets and subscribers by the publish job
+	publishSchedule, err := d.optionalString("jobs.publish.schedule", "")
+	if err != nil {
+		return j, err
+	}
+	publishedHooks, err := d.itemPublishedHooks()
+	if err != nil {
+		return j, err
+	}
+	j = append(j, &jobs.Publish{
+		Items:            d.store,
+		OnPublished:      publishedHooks,
+		ScheduleOverride: publishSchedule,
+	})
+
+	fanoutTargets, err := d.fanoutTargets()
+	if err != nil {
+		return j, fmt.Errorf("failed to load fanout config: %w", err)
+	}
+	if len(fanoutTargets) > 0 {
+		fanoutSchedule, err := d.optionalString("jobs.fanout.schedule", "")
+		if err != nil {
+			return j, err
+		}
+		maxAttempts, err := d.optionalInt("fanout.max_attempts", 8)
+		if err != nil {
+			return j, err
+		}
+
+		j = append(j, &jobs.Fanout{
+			Outbox: d.store,
+			Deliverer: &fanout.Deliverer{
+				Targets:     fanoutTargets,
+				Outbox:      d.store,
+				MaxAttempts: int(maxAttempts),
+			},
+			ScheduleOverride: fanoutSchedule,
+		})
+	}
+
+	pollSources, err := d.pollSources()
+	if err != nil {
+		return j, fmt.Errorf("failed to load poll config: %w", err)
+	}
+	if len(pollSources) > 0 {
+		pollSchedule, err := d.optionalString("jobs.poll.schedule", "")
+		if err != nil {
+			return j, err
+		}
+		hooks, err := d.itemCreatedHooks()
+		if err != nil {
+			return j, err
+		}
+
+		j = append(j, &jobs.Poll{
+			State: d.store,
+			Poller: &poll.Poller{
+				Sources:   pollSources,
+				Items:     d.store,
+				State:     d.store,
+				OnCreated: hooks,
+			},
+			ScheduleOverride: pollSchedule,
+		})
+	}
+
+	digestJobs, err := d.digestJobs()
+	if err != nil {
+		return j, fmt.Errorf("failed to load digest config: %w", err)
+	}
+	if len(digestJobs) > 0 {
+		hooks, err := d.itemCreatedHooks()
+		if err != nil {
+			return j, err
+		}
+
+		digester := &digest.Digester{Items: d.store, State: d.store, OnCreated: hooks}
+		for _, job := range digestJobs {
+			job.Digester = digester
+			j = append(j, job)
+		}
+	}
+
+	if hub != nil {
+		webSubLeasesSchedule, err := d.optionalString("jobs.websub-leases.schedule", "")
+		if err != nil {
+			return j, err
+		}
+
+		j = append(j, &jobs.WebSubLeases{
+			Subscriptions:    d.store,
+			Hub:              hub,
+			ScheduleOverride: webSubLeasesSchedule,
+		})
+	}
+
+	// each job's runs are recorded for metrics, the status endpoint and the job history
+	for i := range j {
+		j[i] = d.toolMetrics().InstrumentJob(d.jobTracker().Track(j[i]))
+	}
+
+	return j, nil
+}
+
+// feedCheckJob loads the feed-check job config, it's also used to report stale feeds in the status endpoint
+func (d *WebhookRSS) feedCheckJob() (*jobs.FeedCheck, error) {
+	path := "jobs.feed-check.schedule"
+	feedCheckSchedule, ok := d.config.Path(path).Data().(string)
+	if !ok {
+		return nil, fmt.Errorf("missing required config path: %s", path)
+	}
+	path = "jobs.feed-check.endpoint"
+	feedCheckEndpoint, ok := d.config.Path(path).Data().(string)
+	if !ok {
+		return nil, fmt.Errorf("missing required config path: %s", path)
+	}
+	path = "jobs.feed-check.feeds"
+	feedCheckData, ok := d.config.Path(path).Data().([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing required config path: %s", path)
+	}
+
+	jobPoster, err := d.jobPoster()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobs.FeedCheck{
+		Items:            d.store,
+		ScheduleOverride: feedCheckSchedule,
+		Endpoint:         feedCheckEndpoint,
+		Feeds:            feedCheckData,
+		Poster:           jobPoster,
 	}, nil
 }
+
 func (d *WebhookRSS) ExternalJobsFuncSet(f func(job apis.ExternalJob) error) {
 }
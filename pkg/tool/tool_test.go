@@ -19,10 +19,18 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/charlieegan3/tool-webhook-rss/pkg/apis"
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/ratelimit"
 )
 
 var toolTestConfig = map[string]interface{}{
 	"webhook-rss": map[string]interface{}{
+		"admin": map[string]interface{}{
+			"token": "admin-token",
+		},
+		"limits": map[string]interface{}{
+			"max_request_bytes":     1024 * 1024,
+			"max_items_per_request": 10,
+		},
 		"jobs": map[string]interface{}{
 			"deadman": map[string]interface{}{
 				"schedule": "* * * * * *",
@@ -152,7 +160,7 @@ func (s *ToolWebhookRSSSuite) TestHTTP() {
 			client := &http.Client{}
 			resp, err := client.Do(req)
 			require.NoError(t, err)
-			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
 		}
 	}
 
@@ -181,7 +189,225 @@ func (s *ToolWebhookRSSSuite) TestHTTP() {
 	assert.Contains(t, string(body), "<title>item2</title>")
 	assert.Contains(t, string(body), "<title>item3</title>")
 
+	// items with dates in any supported format are accepted, unparseable dates are rejected unless
+	// the sender opts in to falling back to the current time.
+	for _, tc := range []struct {
+		query    string
+		date     string
+		expected int
+	}{
+		{date: "2022-10-12T15:04:05Z", expected: http.StatusCreated},
+		{date: "1665587045123", expected: http.StatusCreated},
+		{date: "yesterday", expected: http.StatusBadRequest},
+		{query: "date_fallback=now", date: "yesterday", expected: http.StatusCreated},
+	} {
+		jsonData, err := json.Marshal(apis.PayloadNewItem{Title: "dated", Date: tc.date})
+		require.NoError(t, err)
+
+		req := &http.Request{
+			Method: "POST",
+			URL: &url.URL{
+				Scheme:   "http",
+				Host:     "localhost:9032",
+				Path:     "/webhook-rss/feeds/dates/items",
+				RawQuery: tc.query,
+			},
+			Body: io.NopCloser(bytes.NewBuffer(jsonData)),
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, resp.StatusCode, "unexpected status for date %q", tc.date)
+	}
+
+	// batches with invalid items are rejected with the failing items listed, unless partial mode is used
+	batch := []apis.PayloadNewItem{
+		{Title: "valid"},
+		{Title: ""},
+		{Title: "also valid"},
+	}
+	jsonData, err := json.Marshal(batch)
+	require.NoError(t, err)
+
+	req = &http.Request{
+		Method: "POST",
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   "localhost:9032",
+			Path:   "/webhook-rss/feeds/batches/items",
+		},
+		Body: io.NopCloser(bytes.NewBuffer(jsonData)),
+	}
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var errorsResponse apis.ResponseErrors
+	err = json.NewDecoder(resp.Body).Decode(&errorsResponse)
+	require.NoError(t, err)
+	require.Len(t, errorsResponse.Errors, 1)
+	require.NotNil(t, errorsResponse.Errors[0].Index)
+	assert.Equal(t, 1, *errorsResponse.Errors[0].Index)
+	assert.Equal(t, "title", errorsResponse.Errors[0].Field)
+
+	req = &http.Request{
+		Method: "POST",
+		URL: &url.URL{
+			Scheme:   "http",
+			Host:     "localhost:9032",
+			Path:     "/webhook-rss/feeds/batches/items",
+			RawQuery: "partial=true",
+		},
+		Body: io.NopCloser(bytes.NewBuffer(jsonData)),
+	}
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var createdResponse apis.ResponseItemsCreated
+	err = json.NewDecoder(resp.Body).Decode(&createdResponse)
+	require.NoError(t, err)
+	require.Len(t, createdResponse.Items, 2)
+	require.Len(t, createdResponse.Errors, 1)
+	for _, item := range createdResponse.Items {
+		assert.NotZero(t, item.ID)
+		assert.NotEmpty(t, item.GUID)
+	}
+
+	// requests with too many items are rejected
+	var tooMany []apis.PayloadNewItem
+	for i := 0; i < 11; i++ {
+		tooMany = append(tooMany, apis.PayloadNewItem{Title: fmt.Sprintf("item %d", i)})
+	}
+	jsonData, err = json.Marshal(tooMany)
+	require.NoError(t, err)
+
+	req = &http.Request{
+		Method: "POST",
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   "localhost:9032",
+			Path:   "/webhook-rss/feeds/limits/items",
+		},
+		Body: io.NopCloser(bytes.NewBuffer(jsonData)),
+	}
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// feeds with a read secret are only visible to readers presenting it
+	req, err = http.NewRequest("POST", "http://localhost:9032/webhook-rss/api/v1/feeds/feed2/secrets", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var secret apis.ResponseSecret
+	err = json.NewDecoder(resp.Body).Decode(&secret)
+	require.NoError(t, err)
+	require.NotEmpty(t, secret.Secret)
+
+	resp, err = client.Get("http://localhost:9032/webhook-rss/feeds/feed2.rss")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = client.Get("http://localhost:9032/webhook-rss/feeds/feed2.rss?key=" + secret.Secret)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), secret.Secret)
+
+	resp, err = client.Get("http://localhost:9032/webhook-rss/feeds/feed2/key/" + secret.Secret + ".rss")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest("GET", "http://localhost:9032/webhook-rss/feeds/feed2.rss", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("reader", secret.Secret)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest(
+		"DELETE",
+		fmt.Sprintf("http://localhost:9032/webhook-rss/api/v1/feeds/feed2/secrets/%d", secret.ID),
+		nil,
+	)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// the feed stays private once all its secrets are revoked
+	resp, err = client.Get("http://localhost:9032/webhook-rss/feeds/feed2.rss")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = client.Get("http://localhost:9032/webhook-rss/feeds/feed2.rss?key=" + secret.Secret)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// until it's made public
+	req, err = http.NewRequest("DELETE", "http://localhost:9032/webhook-rss/api/v1/feeds/feed2/private", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = client.Get("http://localhost:9032/webhook-rss/feeds/feed2.rss")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
 	// check that the down migrations also work
 	err = tb.DatabaseDownMigrate(webhookRSSTool)
 	require.NoError(t, err)
 }
+
+func (s *ToolWebhookRSSSuite) TestPostgresRateLimiter() {
+	t := s.T()
+
+	// adding the tool runs the migrations which create the buckets table
+	tb := tool.NewBelt()
+	tb.SetConfig(toolTestConfig)
+	tb.SetDatabase(s.DB)
+	err := tb.AddTool(&WebhookRSS{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	limiter := ratelimit.NewPostgres(s.DB, ratelimit.Rate{PerMinute: 1, Burst: 2})
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+
+	allowed, _, err := limiter.Peek(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, allowed, "new buckets start full")
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err = limiter.Allow(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d should be allowed", i)
+	}
+
+	allowed, wait, err := limiter.Peek(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.InDelta(t, time.Minute.Seconds(), wait.Seconds(), 1)
+
+	allowed, wait, err = limiter.Allow(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.InDelta(t, time.Minute.Seconds(), wait.Seconds(), 1)
+
+	// limits are shared by limiters using the same database, as they are between replicas
+	other := ratelimit.NewPostgres(s.DB, ratelimit.Rate{PerMinute: 1, Burst: 2})
+	allowed, _, err = other.Allow(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, _, err = other.Allow(ctx, key+":other")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
@@ -0,0 +1,424 @@
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+const (
+	ModeSubscribe   = "subscribe"
+	ModeUnsubscribe = "unsubscribe"
+)
+
+// Renderer returns the current content of a feed, this is sent to subscribers when the feed is updated
+type Renderer func(ctx context.Context, feed string) (string, error)
+
+// Hub is a WebSub hub for the tool's own feeds. Subscription requests are verified with the subscriber before being
+// stored, and subscribers are sent the full feed when it's updated.
+type Hub struct {
+	Subscriptions store.SubscriptionStore
+	Render        Renderer
+	// Authorize is optional, when set subscriptions it rejects are skipped when publishing
+	Authorize func(ctx context.Context, sub store.Subscription) (bool, error)
+
+	// HubURL is the public URL of the hub, it's sent to subscribers in Link headers
+	HubURL string
+	// SelfURL returns the canonical topic URL for a feed
+	SelfURL func(feed string) string
+
+	// DefaultLeaseSeconds is used when subscribers don't request a lease
+	DefaultLeaseSeconds int64
+	// MaxLeaseSeconds caps the lease subscribers may request
+	MaxLeaseSeconds int64
+
+	// AllowPrivateCallbacks permits callbacks on loopback, private and link-local addresses, these are refused by
+	// default so that subscribers can't use the hub to reach services on its network
+	AllowPrivateCallbacks bool
+
+	// VerifyWorkers is the number of verifications run at once, defaults to 4
+	VerifyWorkers int
+	// VerifyQueueSize is the number of verifications which may wait for a worker, defaults to 100
+	VerifyQueueSize int
+
+	// PublishWorkers is the number of subscribers a feed is sent to at once, defaults to 4
+	PublishWorkers int
+	// DeliveryAttempts is the number of times sending a feed to a subscriber is attempted, defaults to 3
+	DeliveryAttempts int
+
+	// Client is used for requests to subscribers. When it's not set, a client from NewClient is built on first use.
+	Client *http.Client
+
+	verifyOnce sync.Once
+	verifyCh   chan verification
+
+	clientOnce    sync.Once
+	defaultClient *http.Client
+
+	// retryDelay is the delay before the second delivery attempt, it doubles for each attempt after that
+	retryDelay time.Duration
+}
+
+type verification struct {
+	mode string
+	sub  store.Subscription
+}
+
+// ErrPrivateCallback is returned for callbacks on loopback, private or link-local addresses
+var ErrPrivateCallback = errors.New("callback address is loopback, private or link-local")
+
+// LeaseSeconds returns the lease granted for a requested lease, 0 requests the default
+func (h *Hub) LeaseSeconds(requested int64) int64 {
+	if requested <= 0 {
+		return h.DefaultLeaseSeconds
+	}
+	if h.MaxLeaseSeconds > 0 && requested > h.MaxLeaseSeconds {
+		return h.MaxLeaseSeconds
+	}
+	return requested
+}
+
+// CheckCallback returns ErrPrivateCallback if a callback's host resolves to an address which isn't allowed. The
+// address is checked again when connecting, in case the host resolves differently then.
+func (h *Hub) CheckCallback(ctx context.Context, callback *url.URL) error {
+	if h.AllowPrivateCallbacks {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, callback.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve callback host: %w", err)
+	}
+
+	for _, addr := range addrs {
+		if privateIP(addr.IP) {
+			return ErrPrivateCallback
+		}
+	}
+
+	return nil
+}
+
+// VerifyLater queues a verification to be run in the background by one of the hub's workers. False is returned
+// when the queue is full.
+func (h *Hub) VerifyLater(mode string, sub store.Subscription) bool {
+	h.verifyOnce.Do(h.startVerifyWorkers)
+
+	select {
+	case h.verifyCh <- verification{mode: mode, sub: sub}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Hub) startVerifyWorkers() {
+	workers := h.VerifyWorkers
+	if workers <= 0 {
+		workers = 4
+	}
+	queueSize := h.VerifyQueueSize
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	h.verifyCh = make(chan verification, queueSize)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for v := range h.verifyCh {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				err := h.Verify(ctx, v.mode, v.sub)
+				cancel()
+				if err != nil {
+					log.Printf("failed to verify %s of %s to %s: %s", v.mode, v.sub.Callback, v.sub.Topic, err)
+				}
+			}
+		}()
+	}
+}
+
+// Verify confirms the intent of a subscriber by echoing a challenge through the callback. Verified subscriptions
+// are stored, or removed for unsubscribe requests.
+func (h *Hub) Verify(ctx context.Context, mode string, sub store.Subscription) error {
+	// access may have been revoked since the subscription was requested
+	if mode == ModeSubscribe && h.Authorize != nil {
+		allowed, err := h.Authorize(ctx, sub)
+		if err != nil {
+			return fmt.Errorf("failed to authorize subscription: %w", err)
+		}
+		if !allowed {
+			return fmt.Errorf("topic %s doesn't grant access to feed %s", sub.Topic, sub.Feed)
+		}
+	}
+
+	challenge, err := newChallenge()
+	if err != nil {
+		return fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	callback, err := url.Parse(sub.Callback)
+	if err != nil {
+		return fmt.Errorf("failed to parse callback: %w", err)
+	}
+
+	query := callback.Query()
+	query.Set("hub.mode", mode)
+	query.Set("hub.topic", sub.Topic)
+	query.Set("hub.challenge", challenge)
+	if mode == ModeSubscribe {
+		query.Set("hub.lease_seconds", fmt.Sprint(sub.LeaseSeconds))
+	}
+	callback.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, callback.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build verification request: %w", err)
+	}
+
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send verification request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return fmt.Errorf("failed to read verification response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || string(body) != challenge {
+		return fmt.Errorf("subscriber did not confirm %s for %s", mode, sub.Callback)
+	}
+
+	if mode == ModeUnsubscribe {
+		return h.Subscriptions.DeleteSubscription(ctx, sub.Topic, sub.Callback)
+	}
+
+	sub.ExpiresAt = time.Now().Add(time.Duration(sub.LeaseSeconds) * time.Second)
+
+	_, err = h.Subscriptions.UpsertSubscription(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("failed to store subscription: %w", err)
+	}
+
+	return nil
+}
+
+// Publish sends the current content of a feed to each of its subscribers
+func (h *Hub) Publish(ctx context.Context, feed string) error {
+	subs, err := h.Subscriptions.ListSubscriptions(ctx, feed)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	if len(subs) == 0 {
+		return nil
+	}
+
+	content, err := h.Render(ctx, feed)
+	if err != nil {
+		return fmt.Errorf("failed to render feed: %w", err)
+	}
+
+	var allowedSubs []store.Subscription
+	for _, sub := range subs {
+		if h.Authorize != nil {
+			allowed, err := h.Authorize(ctx, sub)
+			if err != nil {
+				return fmt.Errorf("failed to authorize subscription: %w", err)
+			}
+			if !allowed {
+				continue
+			}
+		}
+		allowedSubs = append(allowedSubs, sub)
+	}
+
+	workers := h.PublishWorkers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	// subscribers are sent the feed concurrently, so that slow subscribers don't hold up the others
+	subCh := make(chan store.Subscription)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(allowedSubs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sub := range subCh {
+				err := h.deliverWithRetries(ctx, sub, feed, []byte(content))
+				if err != nil {
+					log.Printf("failed to deliver feed %s to %s: %s", feed, sub.Callback, err)
+				}
+			}
+		}()
+	}
+
+	for _, sub := range allowedSubs {
+		subCh <- sub
+	}
+	close(subCh)
+	wg.Wait()
+
+	return nil
+}
+
+// Notify publishes a feed in the background, it's used after new items are created
+func (h *Hub) Notify(feed string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := h.Publish(ctx, feed)
+		if err != nil {
+			log.Printf("failed to publish feed %s: %s", feed, err)
+		}
+	}()
+}
+
+// deliverWithRetries attempts a delivery until it succeeds, the attempts are used up or ctx is done. Failures which
+// won't change on a retry, such as the subscriber rejecting the request, aren't retried.
+func (h *Hub) deliverWithRetries(ctx context.Context, sub store.Subscription, feed string, content []byte) error {
+	attempts := h.DeliveryAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	delay := h.retryDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = h.deliver(ctx, sub, feed, content)
+		if err == nil || attempt >= attempts || errors.Is(err, errPermanent) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// errPermanent wraps delivery failures which aren't retried
+var errPermanent = errors.New("permanent failure")
+
+func (h *Hub) deliver(ctx context.Context, sub store.Subscription, feed string, content []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Callback, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/atom+xml")
+	req.Header.Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, h.HubURL))
+	req.Header.Add("Link", fmt.Sprintf(`<%s>; rel="self"`, h.SelfURL(feed)))
+
+	if sub.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(sub.Secret))
+		mac.Write(content)
+		req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// subscribers may end their subscription by responding with gone
+	if resp.StatusCode == http.StatusGone {
+		return h.Subscriptions.DeleteSubscription(ctx, sub.Topic, sub.Callback)
+	}
+
+	// other client errors are the subscriber rejecting the request, rather than it being unavailable
+	if resp.StatusCode >= 400 && resp.StatusCode <= 499 && resp.StatusCode != http.StatusRequestTimeout &&
+		resp.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("%w: unexpected response status: %d", errPermanent, resp.StatusCode)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (h *Hub) client() *http.Client {
+	if h.Client != nil {
+		return h.Client
+	}
+
+	h.clientOnce.Do(func() {
+		h.defaultClient = NewClient(h.AllowPrivateCallbacks)
+	})
+
+	return h.defaultClient
+}
+
+// NewClient returns a client for requests to subscribers. Unless allowPrivateCallbacks is set, it refuses to connect
+// to loopback, private and link-local addresses.
+func NewClient(allowPrivateCallbacks bool) *http.Client {
+	if allowPrivateCallbacks {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+
+	// the address is checked as each connection is made, so callbacks can't be redirected or re-resolved to
+	// private addresses after they've been checked. Proxies aren't used, as it's the callback's address which
+	// must be checked.
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+				return ErrPrivateCallback
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// privateIP returns true for addresses which callbacks may not use
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified()
+}
+
+func newChallenge() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,366 @@
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/tool-webhook-rss/pkg/tool/store"
+)
+
+// subscriber is a test subscriber which confirms verifications and records deliveries
+type subscriber struct {
+	mu         sync.Mutex
+	deliveries []*http.Request
+	bodies     []string
+
+	// confirm is false to respond to verifications without the challenge
+	confirm bool
+	// status is the response status for deliveries
+	status int
+}
+
+func newSubscriber(t *testing.T) (*subscriber, *httptest.Server) {
+	sub := &subscriber{confirm: true, status: http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub.mu.Lock()
+		defer sub.mu.Unlock()
+
+		if r.Method == http.MethodGet {
+			if sub.confirm {
+				w.Write([]byte(r.URL.Query().Get("hub.challenge")))
+			}
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		sub.deliveries = append(sub.deliveries, r)
+		sub.bodies = append(sub.bodies, string(body))
+		w.WriteHeader(sub.status)
+	}))
+	t.Cleanup(server.Close)
+
+	return sub, server
+}
+
+func newTestHub(s store.SubscriptionStore) *Hub {
+	return &Hub{
+		Subscriptions: s,
+		Render: func(ctx context.Context, feed string) (string, error) {
+			return "<feed>" + feed + "</feed>", nil
+		},
+		HubURL:              "https://example.com/hub",
+		SelfURL:             func(feed string) string { return "https://example.com/feeds/" + feed + ".rss" },
+		DefaultLeaseSeconds: 3600,
+		MaxLeaseSeconds:     7200,
+		// the test subscribers are served on loopback
+		AllowPrivateCallbacks: true,
+	}
+}
+
+func TestHubLeaseSeconds(t *testing.T) {
+	hub := &Hub{DefaultLeaseSeconds: 3600, MaxLeaseSeconds: 7200}
+
+	assert.Equal(t, int64(3600), hub.LeaseSeconds(0))
+	assert.Equal(t, int64(60), hub.LeaseSeconds(60))
+	assert.Equal(t, int64(7200), hub.LeaseSeconds(100000))
+}
+
+func TestHubVerify(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	hub := newTestHub(s)
+
+	subscriber, server := newSubscriber(t)
+
+	sub := store.Subscription{
+		Feed:         "example",
+		Topic:        "https://example.com/feeds/example.rss",
+		Callback:     server.URL + "/callback?existing=param",
+		LeaseSeconds: 3600,
+	}
+
+	require.NoError(t, hub.Verify(ctx, ModeSubscribe, sub))
+
+	subs, err := s.ListSubscriptions(ctx, "example")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), subs[0].ExpiresAt, time.Minute)
+
+	require.NoError(t, hub.Verify(ctx, ModeUnsubscribe, sub))
+	subs, err = s.ListSubscriptions(ctx, "example")
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+
+	// subscriptions are only stored when the subscriber confirms them
+	subscriber.mu.Lock()
+	subscriber.confirm = false
+	subscriber.mu.Unlock()
+
+	assert.Error(t, hub.Verify(ctx, ModeSubscribe, sub))
+	subs, err = s.ListSubscriptions(ctx, "example")
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestHubVerifyAuthorize(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	hub := newTestHub(s)
+	hub.Authorize = func(ctx context.Context, sub store.Subscription) (bool, error) {
+		return false, nil
+	}
+
+	_, server := newSubscriber(t)
+
+	err := hub.Verify(ctx, ModeSubscribe, store.Subscription{
+		Feed:     "private",
+		Topic:    "https://example.com/feeds/private/key/revoked.rss",
+		Callback: server.URL,
+	})
+	assert.Error(t, err, "topics which no longer grant access shouldn't be verified")
+
+	subs, err := s.ListSubscriptions(ctx, "private")
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestHubPublish(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	hub := newTestHub(s)
+
+	subscriber, server := newSubscriber(t)
+	_, otherServer := newSubscriber(t)
+
+	for _, callback := range []string{server.URL, otherServer.URL + "/denied"} {
+		_, err := s.UpsertSubscription(ctx, store.Subscription{
+			Feed:         "example",
+			Topic:        "https://example.com/feeds/example.rss",
+			Callback:     callback,
+			Secret:       "signing-secret",
+			LeaseSeconds: 3600,
+			ExpiresAt:    time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	hub.Authorize = func(ctx context.Context, sub store.Subscription) (bool, error) {
+		return sub.Callback == server.URL, nil
+	}
+
+	require.NoError(t, hub.Publish(ctx, "example"))
+
+	subscriber.mu.Lock()
+	require.Len(t, subscriber.deliveries, 1, "subscriptions which aren't authorized should be skipped")
+	delivery, body := subscriber.deliveries[0], subscriber.bodies[0]
+	subscriber.mu.Unlock()
+
+	assert.Equal(t, "<feed>example</feed>", body)
+	assert.Equal(t, "application/atom+xml", delivery.Header.Get("Content-Type"))
+	assert.Equal(t, []string{
+		`<https://example.com/hub>; rel="hub"`,
+		`<https://example.com/feeds/example.rss>; rel="self"`,
+	}, delivery.Header.Values("Link"))
+
+	mac := hmac.New(sha256.New, []byte("signing-secret"))
+	mac.Write([]byte(body))
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), delivery.Header.Get("X-Hub-Signature"))
+
+	// subscribers can end their subscription by responding with gone
+	subscriber.mu.Lock()
+	subscriber.status = http.StatusGone
+	subscriber.mu.Unlock()
+
+	require.NoError(t, hub.Publish(ctx, "example"))
+
+	subs, err := s.ListSubscriptions(ctx, "example")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, otherServer.URL+"/denied", subs[0].Callback)
+}
+
+func TestHubPublishRetries(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	hub := newTestHub(s)
+	hub.retryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.URL.Path]++
+		attempt := attempts[r.URL.Path]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/unavailable":
+			if attempt == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	for _, path := range []string{"/unavailable", "/down", "/rejected"} {
+		_, err := s.UpsertSubscription(ctx, store.Subscription{
+			Feed:         "example",
+			Topic:        "https://example.com/feeds/example.rss",
+			Callback:     server.URL + path,
+			LeaseSeconds: 3600,
+			ExpiresAt:    time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, hub.Publish(ctx, "example"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{
+		"/unavailable": 2,
+		"/down":        3,
+		// subscribers rejecting the request aren't retried
+		"/rejected": 1,
+	}, attempts)
+}
+
+func TestHubPublishConcurrently(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	hub := newTestHub(s)
+
+	// the slow subscriber only responds once the other has been sent the feed, which would time out if
+	// subscribers were sent the feed one at a time
+	fastDone := make(chan struct{})
+	var slowWaited bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast" {
+			close(fastDone)
+			return
+		}
+
+		select {
+		case <-fastDone:
+			slowWaited = true
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	for _, path := range []string{"/slow", "/fast"} {
+		_, err := s.UpsertSubscription(ctx, store.Subscription{
+			Feed:         "example",
+			Topic:        "https://example.com/feeds/example.rss",
+			Callback:     server.URL + path,
+			LeaseSeconds: 3600,
+			ExpiresAt:    time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, hub.Publish(ctx, "example"))
+	assert.True(t, slowWaited, "the fast subscriber should have been sent the feed while the slow one was waiting")
+}
+
+func TestHubPrivateCallbacks(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	hub := newTestHub(s)
+	hub.AllowPrivateCallbacks = false
+
+	for _, callback := range []string{
+		"http://127.0.0.1/callback",
+		"http://localhost/callback",
+		"http://10.1.2.3/callback",
+		"http://172.16.0.1/callback",
+		"http://192.168.0.1/callback",
+		"http://169.254.169.254/callback",
+		"http://0.0.0.0/callback",
+		"http://[::1]/callback",
+		"http://[fd00::1]/callback",
+		"http://[fe80::1]/callback",
+	} {
+		u, err := url.Parse(callback)
+		require.NoError(t, err)
+		assert.ErrorIs(t, hub.CheckCallback(ctx, u), ErrPrivateCallback, callback)
+	}
+
+	u, err := url.Parse("http://203.0.113.10/callback")
+	require.NoError(t, err)
+	assert.NoError(t, hub.CheckCallback(ctx, u))
+
+	// connections are checked too, in case a host resolves to a different address when it's used
+	_, server := newSubscriber(t)
+	err = hub.Verify(ctx, ModeSubscribe, store.Subscription{
+		Feed:     "example",
+		Topic:    "https://example.com/feeds/example.rss",
+		Callback: server.URL,
+	})
+	assert.True(t, errors.Is(err, ErrPrivateCallback), "unexpected error: %v", err)
+
+	hub.AllowPrivateCallbacks = true
+	u, err = url.Parse(server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, hub.CheckCallback(ctx, u))
+}
+
+func TestHubVerifyLater(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	// verifications are held until released, so the queue fills up
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(r.URL.Query().Get("hub.challenge")))
+	}))
+	defer server.Close()
+
+	hub := newTestHub(s)
+	hub.VerifyWorkers = 1
+	hub.VerifyQueueSize = 1
+
+	sub := func(feed string) store.Subscription {
+		return store.Subscription{
+			Feed:         feed,
+			Topic:        "https://example.com/feeds/" + feed + ".rss",
+			Callback:     server.URL,
+			LeaseSeconds: 3600,
+		}
+	}
+
+	require.True(t, hub.VerifyLater(ModeSubscribe, sub("first")))
+	// wait for the worker to take the first verification from the queue
+	assert.Eventually(t, func() bool { return len(hub.verifyCh) == 0 }, time.Second, time.Millisecond)
+
+	assert.True(t, hub.VerifyLater(ModeSubscribe, sub("second")))
+	assert.False(t, hub.VerifyLater(ModeSubscribe, sub("third")), "the queue should be full")
+
+	close(release)
+
+	for _, feed := range []string{"first", "second"} {
+		feed := feed
+		assert.Eventually(t, func() bool {
+			subs, err := s.ListSubscriptions(ctx, feed)
+			return err == nil && len(subs) == 1
+		}, time.Second, 10*time.Millisecond, feed)
+	}
+}